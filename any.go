@@ -0,0 +1,253 @@
+package clog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Any 按v的类型调用对应的类型方法添加到事件上下文, 已知类型不经过反射,
+// 其余类型等同于调用Interface.
+//
+//	Log.Info().Any("n", 1).Any("tags", []string{"a"}).Any("age", clog.Ptr(age)).Cease()
+func (e *Event) Any(key string, v interface{}) *Event {
+	if e == nil {
+		return e
+	}
+	switch v := v.(type) {
+	case nil:
+		e.buf = trs.AppendNil(trs.AppendKey(e.buf, key))
+		return e
+	case string:
+		return e.Str(key, v)
+	case []string:
+		return e.Strs(key, v)
+	case bool:
+		return e.Bool(key, v)
+	case []bool:
+		return e.Bools(key, v)
+	case int:
+		return e.Int(key, v)
+	case []int:
+		return e.Ints(key, v)
+	case int8:
+		return e.Int8(key, v)
+	case []int8:
+		return e.Ints8(key, v)
+	case int16:
+		return e.Int16(key, v)
+	case []int16:
+		return e.Ints16(key, v)
+	case int32:
+		return e.Int32(key, v)
+	case []int32:
+		return e.Ints32(key, v)
+	case int64:
+		return e.Int64(key, v)
+	case []int64:
+		return e.Ints64(key, v)
+	case uint:
+		return e.Uint(key, v)
+	case []uint:
+		return e.Uints(key, v)
+	case uint8:
+		return e.Uint8(key, v)
+	case uint16:
+		return e.Uint16(key, v)
+	case []uint16:
+		return e.Uints16(key, v)
+	case uint32:
+		return e.Uint32(key, v)
+	case []uint32:
+		return e.Uints32(key, v)
+	case uint64:
+		return e.Uint64(key, v)
+	case []uint64:
+		return e.Uints64(key, v)
+	case float32:
+		return e.Float32(key, v)
+	case []float32:
+		return e.Floats32(key, v)
+	case float64:
+		return e.Float64(key, v)
+	case []float64:
+		return e.Floats64(key, v)
+	case time.Time:
+		return e.Time(key, v)
+	case []time.Time:
+		return e.Times(key, v)
+	case time.Duration:
+		return e.TimeDur(key, v)
+	case []time.Duration:
+		return e.TimeDurs(key, v)
+	case json.RawMessage:
+		return e.RawJSON(key, v)
+	case []byte:
+		return e.Bytes(key, v)
+	case net.IP:
+		return e.IPAddr(key, v)
+	case net.IPNet:
+		return e.IPPrefix(key, v)
+	case net.HardwareAddr:
+		return e.MACAddr(key, v)
+	case error:
+		return e.AnErr(key, v)
+	case []error:
+		return e.Errs(key, v)
+	case LogObjectMarshaler:
+		return e.Object(key, v)
+	case LogArrayMarshaler:
+		return e.Array(key, v)
+	case fmt.Stringer:
+		return e.Stringer(key, v)
+	}
+	return e.Interface(key, v)
+}
+
+// Any 按v的类型调用对应的类型方法添加到数组, 已知类型不经过反射, 其余类型等同于调用Interface.
+func (a *Array) Any(v interface{}) *Array {
+	switch v := v.(type) {
+	case nil:
+		a.buf = trs.AppendNil(trs.AppendArrayDelim(a.buf))
+		return a
+	case string:
+		return a.Str(v)
+	case bool:
+		return a.Bool(v)
+	case int:
+		return a.Int(v)
+	case int8:
+		return a.Int8(v)
+	case int16:
+		return a.Int16(v)
+	case int32:
+		return a.Int32(v)
+	case int64:
+		return a.Int64(v)
+	case uint:
+		return a.Uint(v)
+	case uint8:
+		return a.Uint8(v)
+	case uint16:
+		return a.Uint16(v)
+	case uint32:
+		return a.Uint32(v)
+	case uint64:
+		return a.Uint64(v)
+	case float32:
+		return a.Float32(v)
+	case float64:
+		return a.Float64(v)
+	case time.Time:
+		return a.Time(v)
+	case time.Duration:
+		return a.Dur(v)
+	case json.RawMessage:
+		return a.RawJSON(v)
+	case []byte:
+		return a.Bytes(v)
+	case net.IP:
+		return a.IPAddr(v)
+	case net.IPNet:
+		return a.IPPrefix(v)
+	case net.HardwareAddr:
+		return a.MACAddr(v)
+	case error:
+		return a.Err(v)
+	case LogObjectMarshaler:
+		return a.Object(v)
+	case LogArrayMarshaler:
+		sub := Arr()
+		v.MarshalArray(sub)
+		a.buf = sub.write(trs.AppendArrayDelim(a.buf))
+		return a
+	case fmt.Stringer:
+		return a.Str(v.String())
+	}
+	return a.Interface(v)
+}
+
+// Ptr 返回指针指向的值,指针为nil时返回nil(输出null). 配合Any使用:
+//
+//	Log.Info().Any("age", clog.Ptr(req.Age)).Cease()
+func Ptr[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+type slice[T any] []T
+
+// Slice 将任意类型的切片包装为 LogArrayMarshaler, 元素按Any编码. 配合Event.Array,Event.Any或Array.Any使用:
+//
+//	Log.Info().Array("ids", clog.Slice(ids)).Cease()
+func Slice[T any](s []T) LogArrayMarshaler {
+	return slice[T](s)
+}
+
+func (s slice[T]) MarshalArray(a *Array) {
+	for _, v := range s {
+		a.Any(v)
+	}
+}
+
+// MapKey Map支持的key类型.
+type MapKey interface {
+	~string | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type mapObject[K MapKey, V any] map[K]V
+
+// Map 将map包装为 LogObjectMarshaler, 按key排序输出, value按Any编码. 配合Event.Object,Event.Any或Array.Any使用:
+//
+//	Log.Info().Object("scores", clog.Map(scores)).Cease()
+func Map[K MapKey, V any](m map[K]V) LogObjectMarshaler {
+	return mapObject[K, V](m)
+}
+
+func (m mapObject[K, V]) MarshalObject(e *Event) {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]V, len(m))
+	for k, v := range m {
+		s := mapKeyString(k)
+		keys = append(keys, s)
+		values[s] = v
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.Any(k, values[k])
+	}
+}
+
+func mapKeyString[K MapKey](k K) string {
+	switch v := any(k).(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	}
+	// 底层类型为上述类型的自定义类型
+	return fmt.Sprint(k)
+}
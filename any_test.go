@@ -0,0 +1,57 @@
+package clog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+type level string
+
+func TestEventAny(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).Logger()
+	age := 18
+	var nilAge *int
+	log.Log().
+		Any("nil", nil).
+		Any("s", "a").
+		Any("ints", []int{1, 2}).
+		Any("f", 1.5).
+		Any("d", 2*time.Millisecond).
+		Any("err", errors.New("boom")).
+		Any("raw", []byte("hi")).
+		Any("age", Ptr(&age)).
+		Any("nilAge", Ptr(nilAge)).
+		Any("slice", Slice([]interface{}{1, "x", nil})).
+		Any("map", Map(map[level]int{"b": 2, "a": 1})).
+		Any("obj", Map(map[int]*int{2: nil, 1: &age})).
+		Any("struct", struct{ A int }{1}).
+		Msg("")
+	want := `{"nil":null,"s":"a","ints":[1,2],"f":1.5,"d":2,"err":"boom","raw":"hi","age":18,"nilAge":null,` +
+		`"slice":[1,"x",null],"map":{"a":1,"b":2},"obj":{"1":18,"2":null},"struct":{"A":1}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestArrayAny(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).Logger()
+	log.Log().Array("a", Arr().Any(1).Any("b").Any(nil).Any(Slice([]float64{0.5})).Any(Map(map[string]bool{"ok": true}))).Msg("")
+	want := `{"a":[1,"b",null,[0.5],{"ok":true}]}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestSliceNil(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).Logger()
+	var ids []string
+	log.Log().Array("ids", Slice(ids)).Msg("")
+	if got, want := out.String(), `{"ids":[]}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
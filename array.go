@@ -17,11 +17,12 @@ var arrayPool = &sync.Pool{
 // Array 用于预填充数组.
 type Array struct {
 	buf []byte
+	cfg *Config
 }
 
 func putArray(a *Array) {
 	if cap(a.buf) > maxCap {
-		return
+		a.buf = make([]byte, 0, initCap)
 	}
 	arrayPool.Put(a)
 }
@@ -30,6 +31,7 @@ func putArray(a *Array) {
 func Arr() *Array {
 	a := arrayPool.Get().(*Array)
 	a.buf = a.buf[:0]
+	a.cfg = loadConfig()
 	return a
 }
 
@@ -52,6 +54,7 @@ func (a *Array) write(dst []byte) []byte {
 // interface and append it to the array.
 func (a *Array) Object(obj LogObjectMarshaler) *Array {
 	e := Dict()
+	e.cfg = a.cfg
 	obj.MarshalObject(e)
 	e.buf = trs.AppendEndMarker(e.buf)
 	a.buf = append(trs.AppendArrayDelim(a.buf), e.buf...)
@@ -91,9 +94,10 @@ func (a *Array) RawJSON(val []byte) *Array {
 
 // Err 添加序列化后的error数据到Array.
 func (a *Array) Err(err error) *Array {
-	switch m := errorMarshalFunc(err).(type) {
+	switch m := a.cfg.ErrorMarshalFunc(err).(type) {
 	case LogObjectMarshaler:
 		e := newEvent(nil, 0)
+		e.cfg = a.cfg
 		e.buf = e.buf[:0]
 		e.appendObject(m)
 		a.buf = append(trs.AppendArrayDelim(a.buf), e.buf...)
@@ -107,7 +111,7 @@ func (a *Array) Err(err error) *Array {
 	case string:
 		a.buf = trs.AppendString(trs.AppendArrayDelim(a.buf), m)
 	default:
-		a.buf = trs.AppendInterface(trs.AppendArrayDelim(a.buf), m)
+		a.buf = trs.appendInterface(trs.AppendArrayDelim(a.buf), m, a.cfg)
 	}
 
 	return a
@@ -191,15 +195,15 @@ func (a *Array) Float64(f float64) *Array {
 	return a
 }
 
-// Time 添加time类型数据到Array,如需更改默认时间格式通过clog.Set.TimeFormat(timeLayout).
+// Time 添加time类型数据到Array,时间格式为所属Logger配置的TimeFormat.
 func (a *Array) Time(t time.Time) *Array {
-	a.buf = trs.AppendTime(trs.AppendArrayDelim(a.buf), t, timeLayoutFormat)
+	a.buf = trs.AppendTime(trs.AppendArrayDelim(a.buf), t, a.cfg.TimeFormat)
 	return a
 }
 
 // Dur 添加time.Duration类型数据到Array.
 func (a *Array) Dur(d time.Duration) *Array {
-	a.buf = trs.AppendDuration(trs.AppendArrayDelim(a.buf), d)
+	a.buf = trs.appendDuration(trs.AppendArrayDelim(a.buf), d, a.cfg)
 	return a
 }
 
@@ -208,7 +212,7 @@ func (a *Array) Interface(i interface{}) *Array {
 	if obj, ok := i.(LogObjectMarshaler); ok {
 		return a.Object(obj)
 	}
-	a.buf = trs.AppendInterface(trs.AppendArrayDelim(a.buf), i)
+	a.buf = trs.appendInterface(trs.AppendArrayDelim(a.buf), i, a.cfg)
 	return a
 }
 
@@ -0,0 +1,387 @@
+package clogconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/clognet"
+	"github.com/cuckooemm/clog/clogsyslog"
+	"github.com/cuckooemm/clog/internal/jsonfield"
+	"github.com/cuckooemm/clog/storage"
+)
+
+// LoggerFieldName 命名Logger输出的名称字段.
+const LoggerFieldName = "logger"
+
+var facilities = map[string]clogsyslog.Facility{
+	"kern": clogsyslog.Kern, "user": clogsyslog.User, "mail": clogsyslog.Mail, "daemon": clogsyslog.Daemon,
+	"auth": clogsyslog.Auth, "syslog": clogsyslog.Syslog, "lpr": clogsyslog.Lpr, "news": clogsyslog.News,
+	"uucp": clogsyslog.Uucp, "cron": clogsyslog.Cron, "authpriv": clogsyslog.AuthPriv, "ftp": clogsyslog.Ftp,
+	"local0": clogsyslog.Local0, "local1": clogsyslog.Local1, "local2": clogsyslog.Local2, "local3": clogsyslog.Local3,
+	"local4": clogsyslog.Local4, "local5": clogsyslog.Local5, "local6": clogsyslog.Local6, "local7": clogsyslog.Local7,
+}
+
+// Loggers 由Config构建的Logger与输出源, 可通过Apply Reload Watch在运行时更新. 使用完毕后调用Close关闭输出源.
+type Loggers struct {
+	mu     sync.Mutex
+	conf   Config // 当前生效的配置
+	cfg    clog.Config
+	stamp  bool
+	level  *clog.LevelVar
+	levels map[string]*clog.LevelVar
+	w      *swapWriter
+	sample *sampler
+	sinks  []*sink
+	root   clog.Logger
+	named  map[string]*clog.Logger
+}
+
+// sink 已创建的输出源.
+type sink struct {
+	conf  Sink
+	w     io.Writer
+	unreg func()
+}
+
+// Build 校验配置并构建输出源与Logger. 构建失败时关闭已创建的输出源.
+//
+//	c, err := clogconfig.Load("clog.json")
+//	if err != nil {
+//		panic(err)
+//	}
+//	logs, err := c.Build()
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer logs.Close()
+//	log := logs.Logger()
+//	dbLog := logs.Named("db")
+func (c *Config) Build() (*Loggers, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	ls := &Loggers{
+		conf:   c.clone(),
+		cfg:    c.clogConfig(),
+		stamp:  c.Timestamp,
+		level:  clog.NewLevelVar(c.levelOf("")),
+		levels: make(map[string]*clog.LevelVar),
+		w:      new(swapWriter),
+		sample: newSampler(c.Sampling),
+		named:  make(map[string]*clog.Logger),
+	}
+	sinks, router, err := ls.buildSinks(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	ls.sinks, ls.w.w = sinks, router
+	ls.root = ls.newLogger(ls.level)
+	return ls, nil
+}
+
+// levelOf 返回命名Logger的等级, name为空或未配置时返回全局等级.
+func (c *Config) levelOf(name string) clog.Level {
+	root, _ := parseLevel(c.Level, clog.InfoLevel)
+	if lvl, ok := c.Loggers[name]; ok && len(name) > 0 {
+		l, _ := parseLevel(lvl, root)
+		return l
+	}
+	return root
+}
+
+// clone 返回不与c共享map与slice的副本.
+func (c *Config) clone() Config {
+	n := *c
+	if c.Loggers != nil {
+		n.Loggers = make(map[string]string, len(c.Loggers))
+		for k, v := range c.Loggers {
+			n.Loggers[k] = v
+		}
+	}
+	n.Sinks = append([]Sink(nil), c.Sinks...)
+	return n
+}
+
+// buildSinks 按c创建输出源与路由, 与old中配置相同的输出源被复用. 失败时关闭本次新建的输出源.
+func (ls *Loggers) buildSinks(c *Config, old []*sink) ([]*sink, clog.LevelWriter, error) {
+	confs := c.Sinks
+	if len(confs) == 0 {
+		confs = []Sink{{Type: SinkStderr}}
+	}
+	reused := make([]bool, len(old))
+	sinks := make([]*sink, 0, len(confs))
+	router := clog.NewLevelRouter()
+	for i := range confs {
+		s := &confs[i]
+		var cur *sink
+		for j, o := range old {
+			if !reused[j] && o.conf.writerKey() == s.writerKey() {
+				reused[j], cur = true, o
+				break
+			}
+		}
+		if cur == nil {
+			w, err := s.build(fmt.Sprintf("sinks[%d]", i), ls.cfg)
+			if err != nil {
+				for _, n := range sinks {
+					if !n.reusedFrom(old) {
+						n.close()
+					}
+				}
+				return nil, nil, err
+			}
+			cur = &sink{w: w, unreg: func() {}}
+			// 标准输出不参与clog.Close
+			if _, ok := w.(*os.File); !ok {
+				cur.unreg = clog.Register(w)
+			}
+		}
+		cur.conf = *s
+		sinks = append(sinks, cur)
+		var w io.Writer = cur.w
+		enc := s.Encoder
+		if len(enc) == 0 {
+			enc = c.Encoder
+		}
+		if strings.EqualFold(enc, EncoderConsole) {
+			w = &consoleWriter{w: w, cfg: ls.cfg}
+		}
+		min, _ := parseLevel(s.MinLevel, clog.TraceLevel)
+		max, _ := parseLevel(s.MaxLevel, clog.NoLevel)
+		router.Route(min, max, w)
+	}
+	return sinks, router.Finish(), nil
+}
+
+// clogConfig 返回以默认配置为基础, 覆盖了字段名与时间格式的clog.Config.
+func (c *Config) clogConfig() clog.Config {
+	cfg := clog.DefaultConfig()
+	set := func(dst *string, v string) {
+		if len(v) > 0 {
+			*dst = v
+		}
+	}
+	set(&cfg.TimeFormat, c.TimeFormat)
+	set(&cfg.LevelFieldName, c.Fields.Level)
+	set(&cfg.TimestampFieldName, c.Fields.Time)
+	set(&cfg.MessageFieldName, c.Fields.Message)
+	set(&cfg.ErrorFieldName, c.Fields.Error)
+	set(&cfg.ErrorStackFieldName, c.Fields.Stack)
+	set(&cfg.CallerFieldName, c.Fields.Caller)
+	return cfg
+}
+
+// build 创建输出源, cfg为解析日志使用的字段名. storage等构建时的panic转换为错误.
+func (s *Sink) build(key string, cfg clog.Config) (w io.Writer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{Key: key, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+	timeout, _ := parseDuration(s.Timeout)
+	switch s.Type {
+	case SinkStdout:
+		return os.Stdout, nil
+	case SinkStderr:
+		return os.Stderr, nil
+	case SinkSizeFile:
+		o := storage.NewSizeSplitFile(s.Path).MaxSize(s.MaxSize).MaxLine(s.MaxLine).
+			Backups(s.Backups).SaveTime(s.SaveTime).Compress(s.Compress)
+		return o.Finish(), nil
+	case SinkTimeFile:
+		interval, _ := parseDuration(s.Interval)
+		o := storage.NewTimeSplitFile(s.Path, interval).Backups(s.Backups).SaveTime(s.SaveTime).Compress(s.Compress)
+		return o.Finish(), nil
+	case SinkSyslog:
+		o := clogsyslog.New(s.Network, s.Addr).Timeout(timeout).Config(cfg)
+		if f, ok := facilities[strings.ToLower(s.Facility)]; ok {
+			o.Facility(f)
+		}
+		if len(s.AppName) > 0 {
+			o.AppName(s.AppName)
+		}
+		if s.RFC3164 {
+			o.Format(clogsyslog.RFC3164)
+		}
+		return o.Finish(), nil
+	case SinkNet:
+		o := clognet.New(s.Network, s.Addr).Buffer(s.Buffer).Timeout(timeout)
+		if len(s.Spill) > 0 {
+			o.Spill(s.Spill, s.SpillSize)
+		}
+		return o.Finish(), nil
+	}
+	return nil, &Error{Key: key + ".type", Err: fmt.Errorf("unknown sink type %q", s.Type)}
+}
+
+// writerKey 返回创建输出源时使用的配置, 等级区间与编码格式仅影响路由, 修改时无需重建输出源.
+func (s Sink) writerKey() Sink {
+	s.MinLevel, s.MaxLevel, s.Encoder = "", "", ""
+	return s
+}
+
+func (s *sink) reusedFrom(old []*sink) bool {
+	for _, o := range old {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
+
+// close 刷新并关闭输出源, 标准输出不关闭.
+func (s *sink) close() error {
+	s.unreg()
+	var errs clog.MultiError
+	if f, ok := s.w.(clog.Flusher); ok {
+		if err := f.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, ok := s.w.(*os.File); !ok {
+		switch c := s.w.(type) {
+		case io.Closer:
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		case interface{ Close() }:
+			c.Close()
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (ls *Loggers) newLogger(lvl *clog.LevelVar) clog.Logger {
+	o := clog.NewOption().WithWriter(ls.w).WithLevelVar(lvl).WithConfig(ls.cfg).WithHook(ls.sample)
+	if ls.stamp {
+		o.WithTimestamp()
+	}
+	return o.Logger()
+}
+
+// Logger 返回使用全局等级的Logger.
+func (ls *Loggers) Logger() *clog.Logger {
+	return &ls.root
+}
+
+// Named 返回名为name的Logger, 等级为loggers中的配置, 未配置时使用全局等级, 输出中附加 "logger":name 字段.
+// 同名Logger只创建一次.
+func (ls *Loggers) Named(name string) *clog.Logger {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, ok := ls.named[name]; ok {
+		return l
+	}
+	lvl := clog.NewLevelVar(ls.conf.levelOf(name))
+	l := ls.newLogger(lvl)
+	l.AppendStrPrefix(LoggerFieldName, name)
+	ls.levels[name] = lvl
+	ls.named[name] = &l
+	return &l
+}
+
+// Close 刷新并关闭文件, syslog与网络输出源, 返回遇到的错误.
+func (ls *Loggers) Close() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var errs clog.MultiError
+	for _, s := range ls.sinks {
+		if err := s.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	ls.sinks = nil
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// swapWriter 可原子替换的输出源. 替换时等待正在写入旧输出源的日志完成.
+type swapWriter struct {
+	mu sync.RWMutex
+	w  clog.LevelWriter
+}
+
+func (s *swapWriter) Write(p []byte) (int, error) {
+	return s.WriteLevel(clog.NoLevel, p)
+}
+
+func (s *swapWriter) WriteLevel(l clog.Level, p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.w.WriteLevel(l, p)
+}
+
+func (s *swapWriter) swap(w clog.LevelWriter) {
+	s.mu.Lock()
+	s.w = w
+	s.mu.Unlock()
+}
+
+// consoleWriter 将JSON日志事件转换为 time LEVEL caller message key=value 形式的单行文本.
+type consoleWriter struct {
+	w   io.Writer
+	cfg clog.Config
+}
+
+func (c *consoleWriter) Write(p []byte) (int, error) {
+	return c.WriteLevel(clog.NoLevel, p)
+}
+
+func (c *consoleWriter) WriteLevel(_ clog.Level, p []byte) (int, error) {
+	var head [4]string
+	var rest bytes.Buffer
+	err := jsonfield.Range(p, func(key string, val json.RawMessage) bool {
+		switch key {
+		case c.cfg.TimestampFieldName:
+			head[0] = jsonfield.String(val)
+		case c.cfg.LevelFieldName:
+			head[1] = strings.ToUpper(jsonfield.String(val))
+		case c.cfg.CallerFieldName:
+			head[2] = jsonfield.String(val)
+		case c.cfg.MessageFieldName:
+			head[3] = jsonfield.String(val)
+		default:
+			rest.WriteByte(' ')
+			rest.WriteString(key)
+			rest.WriteByte('=')
+			if s := jsonfield.String(val); jsonfield.IsScalar(val) && !strings.ContainsAny(s, " \t\n\"=") {
+				rest.WriteString(s)
+			} else {
+				rest.Write(val)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return c.w.Write(p)
+	}
+	buf := make([]byte, 0, len(p))
+	for _, s := range head {
+		if len(s) > 0 {
+			if len(buf) > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = append(buf, s...)
+		}
+	}
+	tail := rest.Bytes()
+	if len(buf) == 0 && len(tail) > 0 {
+		tail = tail[1:]
+	}
+	buf = append(append(buf, tail...), '\n')
+	if _, err = c.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
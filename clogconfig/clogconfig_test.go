@@ -0,0 +1,363 @@
+package clogconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	doc := `{
+		"level": "info",
+		"fields": {"message": "msg", "level": "lvl"},
+		"loggers": {"db": "warn", "debug": "debug"},
+		"sinks": [
+			{"type": "size_file", "path": "` + filepath.Join(dir, "all.log") + `", "max_size": 10, "backups": 3},
+			{"type": "time_file", "path": "` + filepath.Join(dir, "err.log") + `", "interval": "24h", "min_level": "error"},
+			{"type": "size_file", "path": "` + filepath.Join(dir, "console.log") + `", "encoder": "console", "max_level": "info"}
+		]
+	}`
+	c, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.Logger().Debug().Msg("dropped")
+	logs.Logger().Info().Str("k", "v").Msg("hello")
+	logs.Logger().Error().Msg("failed")
+	logs.Named("db").Info().Msg("dropped")
+	logs.Named("db").Warn().Msg("slow")
+	logs.Named("debug").Debug().Msg("verbose")
+	if logs.Named("db") != logs.Named("db") {
+		t.Error("Named should return the same Logger")
+	}
+	if err = logs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`{"lvl":"info","k":"v","msg":"hello"}`,
+		`{"lvl":"error","msg":"failed"}`,
+		`{"logger":"db","lvl":"warn","msg":"slow"}`,
+		`{"logger":"debug","lvl":"debug","msg":"verbose"}`,
+	}
+	if got := readLines(t, filepath.Join(dir, "all.log")); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("invalid all.log:\ngot:  %q\nwant: %q", got, want)
+	}
+	if got := readLines(t, filepath.Join(dir, "err.log")); len(got) != 1 || got[0] != want[1] {
+		t.Errorf("invalid err.log: %q", got)
+	}
+	wantConsole := []string{"INFO hello k=v", "DEBUG verbose logger=debug"}
+	if got := readLines(t, filepath.Join(dir, "console.log")); strings.Join(got, "\n") != strings.Join(wantConsole, "\n") {
+		t.Errorf("invalid console.log:\ngot:  %q\nwant: %q", got, wantConsole)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		doc  string
+		keys []string
+	}{
+		{`{"level": "loud"}`, []string{"level"}},
+		{`{"encoder": "xml"}`, []string{"encoder"}},
+		{`{"loggers": {"db": "x"}}`, []string{"loggers.db"}},
+		{`{"lvl": "info"}`, []string{"lvl"}},
+		{`{"sinks": [{"type": "stdout"}, {"type": "file"}]}`, []string{"sinks[1].type"}},
+		{`{"sinks": [{"type": "size_file"}]}`, []string{"sinks[0].path"}},
+		{`{"sinks": [{"type": "time_file", "path": "a.log", "interval": "1s"}]}`, []string{"sinks[0].interval"}},
+		{`{"sinks": [{"type": "net", "network": "tcp", "addr": "x", "timeout": "soon"}]}`, []string{"sinks[0].timeout"}},
+		{`{"sinks": [{"type": "stdout", "min_level": "error", "max_level": "info"}]}`, []string{"sinks[0].max_level"}},
+		{`{"sinks": [{"type": "syslog", "facility": "local9", "min_level": "x"}]}`, []string{"sinks[0].min_level", "sinks[0].facility"}},
+		{`{"sampling": {"initial": -1, "tick": "often"}}`, []string{"sampling.initial", "sampling.tick"}},
+	} {
+		_, err := Parse([]byte(tt.doc))
+		if err == nil {
+			t.Errorf("%s: expected error", tt.doc)
+			continue
+		}
+		var errs []error
+		if m, ok := err.(clog.MultiError); ok {
+			errs = m
+		} else {
+			errs = []error{err}
+		}
+		var keys []string
+		for _, err := range errs {
+			var ce *Error
+			if !errors.As(err, &ce) {
+				t.Fatalf("%s: error %v is not *Error", tt.doc, err)
+			}
+			keys = append(keys, ce.Key)
+		}
+		if strings.Join(keys, ",") != strings.Join(tt.keys, ",") {
+			t.Errorf("%s: got keys %v, want %v (%v)", tt.doc, keys, tt.keys, err)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	_, err := Parse([]byte(`{"sinks": [{"type": "stdout", "max_size": "1"}]}`))
+	var ce *Error
+	// 旧版本encoding/json的路径不包含数组下标
+	if !errors.As(err, &ce) || !strings.HasPrefix(ce.Key, "sinks") || !strings.HasSuffix(ce.Key, ".max_size") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv(EnvLevel, "debug")
+	t.Setenv(EnvFormat, "console")
+	c, err := Parse([]byte(`{"level": "error", "encoder": "json"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "debug" || c.Encoder != "console" {
+		t.Errorf("env not applied: %+v", c)
+	}
+
+	t.Setenv(EnvLevel, "verbose")
+	if _, err = FromEnv(); err == nil || !strings.Contains(err.Error(), "level") {
+		t.Errorf("expected level error, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clog.conf")
+	if err := os.WriteFile(path, []byte("level=warn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for unregistered extension")
+	}
+
+	t.Cleanup(func() {
+		decodersMu.Lock()
+		delete(decoders, ".conf")
+		decodersMu.Unlock()
+	})
+	RegisterDecoder(".conf", func(data []byte, v interface{}) error {
+		kv := strings.SplitN(string(data), "=", 2)
+		return json.Unmarshal([]byte(`{"`+kv[0]+`":"`+kv[1]+`"}`), v)
+	})
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "warn" {
+		t.Errorf("invalid level: %q", c.Level)
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log"), filepath.Join(dir, "c.log")
+	conf := &Config{
+		Level:   "info",
+		Loggers: map[string]string{"db": "warn"},
+		Sinks: []Sink{
+			{Type: SinkSizeFile, Path: a},
+			{Type: SinkSizeFile, Path: c},
+		},
+	}
+	logs, err := conf.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	db := logs.Named("db")
+	logs.Logger().Debug().Msg("dropped")
+	db.Info().Msg("dropped")
+	logs.Logger().Info().Msg("one")
+	oldA, oldC := logs.sinks[0].w, logs.sinks[1].w
+
+	changes, err := logs.Apply(&Config{
+		Level:   "debug",
+		Loggers: map[string]string{"db": "error", "http": "warn"},
+		Sinks: []Sink{
+			{Type: SinkSizeFile, Path: a, MinLevel: "info"},
+			{Type: SinkSizeFile, Path: b, MaxLine: 100},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`level: "info" -> "debug"`,
+		`loggers.db: "warn" -> "error"`,
+		`loggers.http: added "warn"`,
+		`sinks[0].min_level: "" -> "info"`,
+		`sinks[1].path: "` + c + `" -> "` + b + `"`,
+		`sinks[1].max_line: 0 -> 100`,
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("invalid changes:\ngot:  %q\nwant: %q", changes, want)
+	}
+	if logs.sinks[0].w != oldA {
+		t.Error("unchanged sink should be reused")
+	}
+	if _, err = oldC.Write([]byte("x\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("removed sink should be closed, got %v", err)
+	}
+	if logs.Logger().GetLevel() != clog.DebugLevel || db.GetLevel() != clog.ErrorLevel {
+		t.Errorf("levels not applied: root=%v db=%v", logs.Logger().GetLevel(), db.GetLevel())
+	}
+	logs.Logger().Debug().Msg("two")
+	db.Warn().Msg("dropped")
+	db.Error().Msg("three")
+	if err = logs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLines(t, a); strings.Join(got, "\n") != `{"level":"info","message":"one"}`+"\n"+`{"logger":"db","level":"error","message":"three"}` {
+		t.Errorf("invalid a.log: %q", got)
+	}
+	if got := readLines(t, b); len(got) != 2 {
+		t.Errorf("invalid b.log: %q", got)
+	}
+	if got := readLines(t, c); len(got) != 1 {
+		t.Errorf("invalid c.log: %q", got)
+	}
+
+	if _, err = logs.Apply(&Config{Sinks: []Sink{{Type: SinkNet}}}); err == nil {
+		t.Error("expected validation error")
+	}
+	if changes, _ = logs.Apply(&Config{
+		Level:   "debug",
+		Loggers: map[string]string{"db": "error", "http": "warn"},
+		Sinks:   logs.Config().Sinks,
+	}); len(changes) != 0 {
+		t.Errorf("unexpected changes: %q", changes)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	conf := &Config{
+		Sampling: Sampling{Initial: 2, Thereafter: 3, Tick: "1h"},
+		Sinks:    []Sink{{Type: SinkSizeFile, Path: path}},
+	}
+	logs, err := conf.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	for i := 0; i < 10; i++ {
+		logs.Logger().Info().Int("i", i).Msg("a")
+	}
+	logs.Named("db").Warn().Msg("a")
+	changes, err := logs.Apply(&Config{Sinks: conf.Sinks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sampling.initial: 2 -> 0\nsampling.thereafter: 3 -> 0\nsampling.tick: \"1h\" -> \"\""; strings.Join(changes, "\n") != want {
+		t.Errorf("invalid changes: %q", changes)
+	}
+	logs.Logger().Info().Int("i", 10).Msg("a")
+	if err = logs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"level":"info","i":0,"message":"a"}`,
+		`{"level":"info","i":1,"message":"a"}`,
+		`{"level":"info","i":4,"message":"a"}`,
+		`{"level":"info","i":7,"message":"a"}`,
+		`{"logger":"db","level":"warn","message":"a"}`,
+		`{"level":"info","i":10,"message":"a"}`,
+	}
+	if got := readLines(t, path); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("invalid sampled output:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestApplyConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	conf := &Config{Sinks: []Sink{{Type: SinkSizeFile, Path: path}}}
+	logs, err := conf.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			logs.Logger().Info().Int("i", i).Msg("")
+		}
+	}()
+	// 反复重建同一路径的输出源, 不应丢失日志
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+		default:
+			if _, err = logs.Apply(&Config{Sinks: []Sink{{Type: SinkSizeFile, Path: path, MaxLine: i % 2 * n * 2}}}); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		break
+	}
+	if err = logs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLines(t, path); len(got) != n {
+		t.Errorf("lost events: got %d lines, want %d", len(got), n)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clog.json")
+	out := filepath.Join(filepath.Dir(path), "out.log")
+	write := func(level string) {
+		doc := `{"level": "` + level + `", "sinks": [{"type": "size_file", "path": "` + out + `"}]}`
+		if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("warn")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	stop := logs.Watch(path, 5*time.Millisecond)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	write("info")
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.Logger().GetLevel() != clog.InfoLevel {
+		if time.Now().After(deadline) {
+			t.Fatal("config not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	logs.Close()
+	got := readLines(t, out)
+	if len(got) != 1 || !strings.Contains(got[0], `"changes":["level: \"warn\" -> \"info\""]`) {
+		t.Errorf("invalid reload log: %q", got)
+	}
+}
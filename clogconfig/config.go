@@ -0,0 +1,367 @@
+// Package clogconfig 根据配置文档构建Logger与输出源, 支持JSON, 通过RegisterDecoder注册的YAML TOML等格式,
+// 以及 CLOG_LEVEL CLOG_FORMAT 环境变量覆盖.
+//
+//	{
+//	  "level": "info",
+//	  "encoder": "json",
+//	  "time_format": "2006-01-02 15:04:05",
+//	  "timestamp": true,
+//	  "fields": {"message": "msg"},
+//	  "loggers": {"db": "warn"},
+//	  "sinks": [
+//	    {"type": "stdout", "max_level": "warn"},
+//	    {"type": "size_file", "path": "log/app.log", "max_size": 100, "backups": 10, "save_time": 7, "compress": 1},
+//	    {"type": "time_file", "path": "log/err.log", "interval": "24h", "min_level": "error"},
+//	    {"type": "syslog", "network": "udp", "addr": "127.0.0.1:514", "facility": "local0"},
+//	    {"type": "net", "network": "tcp", "addr": "127.0.0.1:9000", "buffer": 8388608}
+//	  ]
+//	}
+package clogconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+// 环境变量, 设置后覆盖配置文档中的对应项.
+const (
+	EnvLevel  = "CLOG_LEVEL"  // 覆盖level
+	EnvFormat = "CLOG_FORMAT" // 覆盖encoder
+)
+
+// 编码格式
+const (
+	EncoderJSON    = "json"
+	EncoderConsole = "console" // 便于终端阅读的单行文本: time LEVEL caller message key=value
+)
+
+// 输出源类型
+const (
+	SinkStdout   = "stdout"
+	SinkStderr   = "stderr"
+	SinkSizeFile = "size_file" // storage.NewSizeSplitFile
+	SinkTimeFile = "time_file" // storage.NewTimeSplitFile
+	SinkSyslog   = "syslog"    // clogsyslog.New
+	SinkNet      = "net"       // clognet.New
+)
+
+// Config 配置文档. 字段为空时使用clog的默认值.
+type Config struct {
+	Level      string            `json:"level,omitempty" yaml:"level" toml:"level"`                   // 全局等级, 默认info
+	Encoder    string            `json:"encoder,omitempty" yaml:"encoder" toml:"encoder"`             // json或console, 默认json
+	TimeFormat string            `json:"time_format,omitempty" yaml:"time_format" toml:"time_format"` // time字段格式
+	Timestamp  bool              `json:"timestamp,omitempty" yaml:"timestamp" toml:"timestamp"`       // 是否输出time字段
+	Fields     FieldNames        `json:"fields,omitempty" yaml:"fields" toml:"fields"`
+	Loggers    map[string]string `json:"loggers,omitempty" yaml:"loggers" toml:"loggers"`    // 命名Logger的等级
+	Sampling   Sampling          `json:"sampling,omitempty" yaml:"sampling" toml:"sampling"` // 按等级与消息采样, 默认不采样
+	Sinks      []Sink            `json:"sinks,omitempty" yaml:"sinks" toml:"sinks"`          // 为空时输出至stderr
+}
+
+// FieldNames 字段名.
+type FieldNames struct {
+	Level   string `json:"level,omitempty" yaml:"level" toml:"level"`
+	Time    string `json:"time,omitempty" yaml:"time" toml:"time"`
+	Message string `json:"message,omitempty" yaml:"message" toml:"message"`
+	Error   string `json:"error,omitempty" yaml:"error" toml:"error"`
+	Stack   string `json:"stack,omitempty" yaml:"stack" toml:"stack"`
+	Caller  string `json:"caller,omitempty" yaml:"caller" toml:"caller"`
+}
+
+// Sink 输出源配置, 仅写入等级在[MinLevel,MaxLevel]区间内的日志.
+type Sink struct {
+	Type     string `json:"type,omitempty" yaml:"type" toml:"type"`
+	Encoder  string `json:"encoder,omitempty" yaml:"encoder" toml:"encoder"`       // 为空时使用Config.Encoder
+	MinLevel string `json:"min_level,omitempty" yaml:"min_level" toml:"min_level"` // 默认trace
+	MaxLevel string `json:"max_level,omitempty" yaml:"max_level" toml:"max_level"` // 默认不限制, 包含Log()输出的无等级日志
+
+	// size_file time_file
+	Path     string `json:"path,omitempty" yaml:"path" toml:"path"`
+	MaxSize  int    `json:"max_size,omitempty" yaml:"max_size" toml:"max_size"`    // size_file 单位Mb
+	MaxLine  int    `json:"max_line,omitempty" yaml:"max_line" toml:"max_line"`    // size_file
+	Interval string `json:"interval,omitempty" yaml:"interval" toml:"interval"`    // time_file 切分间隔, 如 1h 24h
+	Backups  int    `json:"backups,omitempty" yaml:"backups" toml:"backups"`       // 最大保存文件数
+	SaveTime int    `json:"save_time,omitempty" yaml:"save_time" toml:"save_time"` // 保存天数
+	Compress int    `json:"compress,omitempty" yaml:"compress" toml:"compress"`    // 压缩n天前的文件
+
+	// syslog net
+	Network string `json:"network,omitempty" yaml:"network" toml:"network"`
+	Addr    string `json:"addr,omitempty" yaml:"addr" toml:"addr"`
+	Timeout string `json:"timeout,omitempty" yaml:"timeout" toml:"timeout"`
+
+	// syslog
+	Facility string `json:"facility,omitempty" yaml:"facility" toml:"facility"` // kern user ... local0-local7, 默认user
+	AppName  string `json:"app_name,omitempty" yaml:"app_name" toml:"app_name"`
+	RFC3164  bool   `json:"rfc3164,omitempty" yaml:"rfc3164" toml:"rfc3164"` // 默认RFC5424
+
+	// net
+	Buffer    int    `json:"buffer,omitempty" yaml:"buffer" toml:"buffer"`             // 内存缓冲字节数
+	Spill     string `json:"spill,omitempty" yaml:"spill" toml:"spill"`                // 磁盘溢出路径
+	SpillSize int    `json:"spill_size,omitempty" yaml:"spill_size" toml:"spill_size"` // 磁盘溢出上限, 单位Mb
+}
+
+// Error 配置错误, Key为出错项的路径, 如 sinks[1].min_level.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	if len(e.Key) == 0 {
+		return "clogconfig: " + e.Err.Error()
+	}
+	return "clogconfig: " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Decoder 将配置文档解析至v.
+type Decoder func(data []byte, v interface{}) error
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{".json": decodeJSON}
+)
+
+// RegisterDecoder 注册文件扩展名对应的解析方法, 供Load使用. 字段名见Config的yaml toml tag.
+//
+//	clogconfig.RegisterDecoder(".yaml", yaml.Unmarshal)
+//	clogconfig.RegisterDecoder(".toml", toml.Unmarshal)
+func RegisterDecoder(ext string, dec Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(ext)] = dec
+}
+
+// Load 按扩展名解析配置文件, 应用环境变量覆盖并校验.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	decodersMu.RLock()
+	dec, ok := decoders[ext]
+	decodersMu.RUnlock()
+	if !ok {
+		return nil, &Error{Err: fmt.Errorf("no decoder registered for %q", ext)}
+	}
+	return ParseWith(data, dec)
+}
+
+// Parse 解析JSON配置文档, 应用环境变量覆盖并校验. 不允许出现未知的key.
+func Parse(data []byte) (*Config, error) {
+	return ParseWith(data, decodeJSON)
+}
+
+// ParseWith 使用dec解析配置文档, 应用环境变量覆盖并校验.
+func ParseWith(data []byte, dec Decoder) (*Config, error) {
+	c := new(Config)
+	if err := dec(data, c); err != nil {
+		return nil, decodeError(err)
+	}
+	c.ApplyEnv()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromEnv 返回仅由环境变量设置的配置, 输出至stderr.
+func FromEnv() (*Config, error) {
+	c := new(Config)
+	c.ApplyEnv()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// decodeError 将JSON解析错误转换为带key的Error.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &Error{Key: fieldPath(typeErr.Field), Err: fmt.Errorf("cannot use %s as %s", typeErr.Value, typeErr.Type)}
+	}
+	// encoding/json 未导出未知字段错误的类型
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		key, _ := strconv.Unquote(strings.TrimPrefix(msg, "json: unknown field "))
+		return &Error{Key: key, Err: errors.New("unknown key")}
+	}
+	return &Error{Err: err}
+}
+
+// fieldPath 将 sinks.0.max_size 形式的路径转换为 sinks[0].max_size.
+func fieldPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, p := range parts {
+		if _, err := strconv.Atoi(p); err == nil && i > 0 {
+			b.WriteString("[" + p + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// ApplyEnv 使用 CLOG_LEVEL CLOG_FORMAT 覆盖level与encoder. 单独设置了encoder的输出源不受CLOG_FORMAT影响.
+func (c *Config) ApplyEnv() {
+	if v, ok := os.LookupEnv(EnvLevel); ok && len(v) > 0 {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv(EnvFormat); ok && len(v) > 0 {
+		c.Encoder = v
+	}
+}
+
+// Validate 校验配置, 返回 *Error, 存在多个错误时返回由 *Error 组成的 clog.MultiError.
+func (c *Config) Validate() error {
+	var errs clog.MultiError
+	add := func(key string, err error) {
+		errs = append(errs, &Error{Key: key, Err: err})
+	}
+	if _, err := parseLevel(c.Level, clog.InfoLevel); err != nil {
+		add("level", err)
+	}
+	if err := checkEncoder(c.Encoder); err != nil {
+		add("encoder", err)
+	}
+	for name, lvl := range c.Loggers {
+		if len(name) == 0 {
+			add("loggers", errors.New("empty logger name"))
+		}
+		if _, err := parseLevel(lvl, clog.InfoLevel); err != nil {
+			add("loggers."+name, err)
+		}
+	}
+	c.Sampling.validate(add)
+	for i := range c.Sinks {
+		c.Sinks[i].validate("sinks["+strconv.Itoa(i)+"]", add)
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
+
+func (s *Sink) validate(prefix string, add func(key string, err error)) {
+	min, err := parseLevel(s.MinLevel, clog.TraceLevel)
+	if err != nil {
+		add(prefix+".min_level", err)
+	}
+	max, err := parseLevel(s.MaxLevel, clog.NoLevel)
+	if err != nil {
+		add(prefix+".max_level", err)
+	} else if max < min {
+		add(prefix+".max_level", fmt.Errorf("%q is lower than min_level %q", s.MaxLevel, s.MinLevel))
+	}
+	if err = checkEncoder(s.Encoder); err != nil {
+		add(prefix+".encoder", err)
+	}
+	if _, err = parseDuration(s.Timeout); err != nil {
+		add(prefix+".timeout", err)
+	}
+	required := func(key, val string) {
+		if len(val) == 0 {
+			add(prefix+"."+key, errors.New("required for "+s.Type))
+		}
+	}
+	nonNegative := func(key string, val int) {
+		if val < 0 {
+			add(prefix+"."+key, errors.New("must not be negative"))
+		}
+	}
+	switch s.Type {
+	case SinkStdout, SinkStderr:
+	case SinkSizeFile, SinkTimeFile:
+		required("path", s.Path)
+		nonNegative("max_size", s.MaxSize)
+		nonNegative("max_line", s.MaxLine)
+		nonNegative("backups", s.Backups)
+		nonNegative("save_time", s.SaveTime)
+		nonNegative("compress", s.Compress)
+		if s.SaveTime > 0 && s.Compress > s.SaveTime {
+			add(prefix+".compress", errors.New("must not exceed save_time"))
+		}
+		if s.Type == SinkTimeFile {
+			required("interval", s.Interval)
+			if d, err := parseDuration(s.Interval); err != nil {
+				add(prefix+".interval", err)
+			} else if len(s.Interval) > 0 && d < time.Minute {
+				add(prefix+".interval", errors.New("must be at least 1m"))
+			}
+		}
+	case SinkSyslog:
+		if _, ok := facilities[strings.ToLower(s.Facility)]; !ok && len(s.Facility) > 0 {
+			add(prefix+".facility", fmt.Errorf("unknown facility %q", s.Facility))
+		}
+	case SinkNet:
+		required("network", s.Network)
+		required("addr", s.Addr)
+		nonNegative("buffer", s.Buffer)
+		nonNegative("spill_size", s.SpillSize)
+	case "":
+		add(prefix+".type", errors.New("required"))
+	default:
+		add(prefix+".type", fmt.Errorf("unknown sink type %q", s.Type))
+	}
+}
+
+func checkEncoder(enc string) error {
+	switch strings.ToLower(enc) {
+	case "", EncoderJSON, EncoderConsole:
+		return nil
+	}
+	return fmt.Errorf("unknown encoder %q", enc)
+}
+
+// parseLevel 忽略大小写解析等级名称, 为空时返回def.
+func parseLevel(s string, def clog.Level) (clog.Level, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	for l := clog.TraceLevel; l <= clog.Disabled; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return def, fmt.Errorf("unknown level %q", s)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
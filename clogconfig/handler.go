@@ -0,0 +1,49 @@
+package clogconfig
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type handler struct {
+	logs *Loggers
+	path string
+}
+
+// Handler 返回查看与重新加载配置的http.Handler.
+// GET 返回当前生效的配置, POST或PUT 重新加载path并返回变更项.
+//
+//	mux.Handle("/clog/config", clogconfig.Handler(logs, "clog.json"))
+//
+// curl http://host:port/clog/config 查看配置, curl -X POST http://host:port/clog/config 重新加载.
+func Handler(logs *Loggers, path string) http.Handler {
+	return &handler{logs: logs, path: path}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, h.logs.Config())
+	case http.MethodPost, http.MethodPut:
+		changes, err := h.logs.Reload(h.path)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if changes == nil {
+			changes = []string{}
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"changes": changes})
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
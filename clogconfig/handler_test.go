@@ -0,0 +1,66 @@
+package clogconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clog.json")
+	write := func(doc string) {
+		if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink := `"sinks": [{"type": "size_file", "path": "` + filepath.Join(dir, "app.log") + `"}]`
+	write(`{"level": "warn", ` + sink + `}`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := c.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	h := Handler(logs, path)
+	serve := func(method string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/clog/config", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, body
+	}
+
+	if code, body := serve(http.MethodGet); code != http.StatusOK || body["level"] != "warn" {
+		t.Errorf("GET: %d %v", code, body)
+	}
+
+	write(`{"level": "debug", ` + sink + `}`)
+	code, body := serve(http.MethodPost)
+	if changes, _ := body["changes"].([]interface{}); code != http.StatusOK || len(changes) != 1 || changes[0] != `level: "warn" -> "debug"` {
+		t.Errorf("POST: %d %v", code, body)
+	}
+	if code, body = serve(http.MethodPost); code != http.StatusOK || len(body["changes"].([]interface{})) != 0 {
+		t.Errorf("POST unchanged: %d %v", code, body)
+	}
+
+	write(`{"level": "loud"}`)
+	if code, body = serve(http.MethodPut); code != http.StatusBadRequest || !strings.Contains(body["error"].(string), "level") {
+		t.Errorf("PUT invalid: %d %v", code, body)
+	}
+	if code, _ = serve(http.MethodDelete); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: %d", code)
+	}
+	if got := logs.Config().Level; got != "debug" {
+		t.Errorf("active level = %q, want debug", got)
+	}
+}
@@ -0,0 +1,221 @@
+package clogconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+// Config 返回当前生效配置的副本.
+func (ls *Loggers) Config() Config {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.conf.clone()
+}
+
+// Apply 将c应用至已创建的Logger, 返回变更项. 校验或创建输出源失败时返回错误且不做任何修改.
+//
+// 等级 loggers与sampling立即生效; 输出源按配置增删, 配置未变的输出源被复用, 修改了路径或切分参数的输出源被重建;
+// 新输出源替换完成前写入的日志仍写入旧输出源, 被移除的输出源在其写入完成后刷新并关闭.
+// fields time_format timestamp 在Logger创建时确定, 修改需重新Build, 变更项中标记为 (requires rebuild).
+func (ls *Loggers) Apply(c *Config) ([]string, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	changes := diff(&ls.conf, c)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	sinks, router, err := ls.buildSinks(c, ls.sinks)
+	if err != nil {
+		return nil, err
+	}
+	ls.w.swap(router)
+	ls.level.Set(c.levelOf(""))
+	for name, lvl := range ls.levels {
+		lvl.Set(c.levelOf(name))
+	}
+	if c.Sampling != ls.conf.Sampling {
+		ls.sample.set(c.Sampling)
+	}
+	for _, s := range ls.sinks {
+		if !s.reusedFrom(sinks) {
+			if err = s.close(); err != nil {
+				clog.HandleError(err)
+			}
+		}
+	}
+	ls.sinks = sinks
+	ls.conf = c.clone()
+	return changes, nil
+}
+
+// Reload 重新加载配置文件并应用, 有变更时以info等级输出变更项.
+func (ls *Loggers) Reload(path string) ([]string, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := ls.Apply(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		ls.root.Info().Str("path", path).Strs("changes", changes).Msg("clog config reloaded")
+	}
+	return changes, nil
+}
+
+// Watch 每隔interval检查配置文件的修改时间与大小, 变化时调用Reload, 返回停止检查的函数.
+// 加载失败时以error等级输出错误并保持当前配置.
+//
+//	stop := logs.Watch("clog.json", 5*time.Second)
+//	defer stop()
+func (ls *Loggers) Watch(path string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	var (
+		quit = make(chan struct{})
+		done = make(chan struct{})
+		once sync.Once
+	)
+	last := statFile(path)
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			cur := statFile(path)
+			if cur == last {
+				continue
+			}
+			last = cur
+			// 文件被编辑器替换的过程中可能短暂不存在
+			if cur == (fileStat{}) {
+				continue
+			}
+			if _, err := ls.Reload(path); err != nil {
+				ls.root.Error().Err(err).Str("path", path).Msg("clog config reload failed")
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+		})
+	}
+}
+
+type fileStat struct {
+	mod  time.Time
+	size int64
+}
+
+func statFile(path string) fileStat {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}
+	}
+	return fileStat{mod: fi.ModTime(), size: fi.Size()}
+}
+
+// diff 返回从old到c的变更项, 如 level: "info" -> "debug".
+func diff(old, c *Config) []string {
+	var changes []string
+	add := func(key string, a, b interface{}, suffix string) {
+		changes = append(changes, fmt.Sprintf("%s: %#v -> %#v%s", key, a, b, suffix))
+	}
+	if old.Level != c.Level {
+		add("level", old.Level, c.Level, "")
+	}
+	if old.Encoder != c.Encoder {
+		add("encoder", old.Encoder, c.Encoder, "")
+	}
+	const rebuild = " (requires rebuild)"
+	if old.TimeFormat != c.TimeFormat {
+		add("time_format", old.TimeFormat, c.TimeFormat, rebuild)
+	}
+	if old.Timestamp != c.Timestamp {
+		add("timestamp", old.Timestamp, c.Timestamp, rebuild)
+	}
+	diffStruct("fields", reflect.ValueOf(old.Fields), reflect.ValueOf(c.Fields), func(key string, a, b interface{}) {
+		add(key, a, b, rebuild)
+	})
+	diffStruct("sampling", reflect.ValueOf(old.Sampling), reflect.ValueOf(c.Sampling), func(key string, a, b interface{}) {
+		add(key, a, b, "")
+	})
+
+	names := make([]string, 0, len(old.Loggers)+len(c.Loggers))
+	for name := range old.Loggers {
+		names = append(names, name)
+	}
+	for name := range c.Loggers {
+		if _, ok := old.Loggers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a, inOld := old.Loggers[name]
+		b, inNew := c.Loggers[name]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("loggers.%s: added %#v", name, b))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("loggers.%s: removed", name))
+		case a != b:
+			add("loggers."+name, a, b, "")
+		}
+	}
+
+	for i := 0; i < len(old.Sinks) || i < len(c.Sinks); i++ {
+		key := fmt.Sprintf("sinks[%d]", i)
+		switch {
+		case i >= len(old.Sinks):
+			changes = append(changes, key+": added "+c.Sinks[i].describe())
+		case i >= len(c.Sinks):
+			changes = append(changes, key+": removed "+old.Sinks[i].describe())
+		default:
+			diffStruct(key, reflect.ValueOf(old.Sinks[i]), reflect.ValueOf(c.Sinks[i]), func(key string, a, b interface{}) {
+				add(key, a, b, "")
+			})
+		}
+	}
+	return changes
+}
+
+// diffStruct 按json tag比较a与b的字段.
+func diffStruct(prefix string, a, b reflect.Value, fn func(key string, a, b interface{})) {
+	for i := 0; i < a.NumField(); i++ {
+		x, y := a.Field(i).Interface(), b.Field(i).Interface()
+		if x != y {
+			tag, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("json"), ",")
+			fn(prefix+"."+tag, x, y)
+		}
+	}
+}
+
+func (s Sink) describe() string {
+	switch {
+	case len(s.Path) > 0:
+		return s.Type + " " + s.Path
+	case len(s.Addr) > 0:
+		return s.Type + " " + s.Network + "://" + s.Addr
+	}
+	return s.Type
+}
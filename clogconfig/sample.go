@@ -0,0 +1,91 @@
+package clogconfig
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+// Sampling 采样配置. 每个周期内等级与消息相同的日志, 前Initial条全部输出, 此后每Thereafter条输出一条.
+// Initial与Thereafter均为0时不采样.
+type Sampling struct {
+	Initial    int    `json:"initial,omitempty" yaml:"initial" toml:"initial"`          // 每个周期内全部输出的条数
+	Thereafter int    `json:"thereafter,omitempty" yaml:"thereafter" toml:"thereafter"` // 超出Initial后每n条输出一条, 0表示全部丢弃
+	Tick       string `json:"tick,omitempty" yaml:"tick" toml:"tick"`                   // 周期, 默认1s
+}
+
+func (s *Sampling) enabled() bool {
+	return s.Initial > 0 || s.Thereafter > 0
+}
+
+func (s *Sampling) validate(add func(key string, err error)) {
+	if s.Initial < 0 {
+		add("sampling.initial", errors.New("must not be negative"))
+	}
+	if s.Thereafter < 0 {
+		add("sampling.thereafter", errors.New("must not be negative"))
+	}
+	if _, err := parseDuration(s.Tick); err != nil {
+		add("sampling.tick", err)
+	}
+}
+
+type sampleKey struct {
+	level clog.Level
+	msg   string
+}
+
+// sampler 按Sampling丢弃日志的Hook, 配置可在运行时替换.
+type sampler struct {
+	enabled uint32
+	mu      sync.Mutex
+	conf    Sampling
+	tick    time.Duration
+	reset   time.Time
+	counts  map[sampleKey]int
+}
+
+func newSampler(s Sampling) *sampler {
+	sp := new(sampler)
+	sp.set(s)
+	return sp
+}
+
+// set 替换采样配置并清空计数.
+func (sp *sampler) set(s Sampling) {
+	tick, _ := parseDuration(s.Tick)
+	if tick == 0 {
+		tick = time.Second
+	}
+	sp.mu.Lock()
+	sp.conf, sp.tick = s, tick
+	sp.reset, sp.counts = time.Time{}, nil
+	sp.mu.Unlock()
+	var enabled uint32
+	if s.enabled() {
+		enabled = 1
+	}
+	atomic.StoreUint32(&sp.enabled, enabled)
+}
+
+func (sp *sampler) Run(e *clog.Event, level clog.Level, msg string) {
+	if atomic.LoadUint32(&sp.enabled) == 0 {
+		return
+	}
+	sp.mu.Lock()
+	if now := time.Now(); now.After(sp.reset) {
+		sp.reset, sp.counts = now.Add(sp.tick), make(map[sampleKey]int)
+	}
+	key := sampleKey{level: level, msg: msg}
+	n := sp.counts[key] + 1
+	sp.counts[key] = n
+	initial, thereafter := sp.conf.Initial, sp.conf.Thereafter
+	sp.mu.Unlock()
+	if n <= initial || thereafter > 0 && (n-initial)%thereafter == 0 {
+		return
+	}
+	e.Discard()
+}
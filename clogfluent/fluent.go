@@ -0,0 +1,443 @@
+// Package clogfluent 提供Fluentd Forward协议输出源,
+// 日志事件转为MessagePack编码的 [tag, time, record] 并以PackedForward模式批量发送至fluentd/fluent-bit.
+package clogfluent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+var (
+	// ErrBufferFull 待发送日志数达到上限时返回,日志被丢弃.
+	ErrBufferFull = errors.New("clogfluent: buffer is full")
+	// ErrClosed 向已关闭的Writer写入时返回.
+	ErrClosed = errors.New("clogfluent: writer closed")
+	// ErrAck 服务端返回的ack与发送的chunk不一致.
+	ErrAck = errors.New("clogfluent: ack mismatch")
+)
+
+// Writer Fluentd Forward协议输出源,实现 clog.LevelWriter 接口. 通过New()构建.
+//
+// 日志写入内存缓冲后由后台协程按tag分组,以PackedForward模式批量发送.
+// 开启Ack后每个批次携带chunk选项并等待服务端确认,未确认的批次在重连后重发,因此可能重复投递.
+type Writer struct {
+	dropped   uint64
+	network   string
+	addr      string
+	tag       string
+	tagField  string
+	msgKey    string
+	ack       bool
+	batchSize int
+	limit     int
+	interval  time.Duration
+	timeout   time.Duration
+	minWait   time.Duration
+	maxWait   time.Duration
+	handle    func(error)
+
+	mu      sync.Mutex
+	pending []entry
+	closed  bool
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	conn    net.Conn
+	buf     []byte
+	resp    []byte
+}
+
+// entry 已编码的一条日志, data为MessagePack编码的 [time, record].
+type entry struct {
+	tag  string
+	data []byte
+}
+
+type option struct {
+	w *Writer
+}
+
+// New 构建Forward协议输出源, network可选 "tcp" "unix"
+//
+//	w := clogfluent.New("tcp", "127.0.0.1:24224").Tag("app").TagField("logger").Ack(true).Finish()
+//	defer w.Close()
+//	clog.NewOption().WithWriter(w).Logger()
+func New(network, addr string) *option {
+	return &option{w: &Writer{
+		network:   network,
+		addr:      addr,
+		tag:       "clog",
+		batchSize: 512,
+		limit:     8192,
+		interval:  time.Second,
+		timeout:   5 * time.Second,
+		minWait:   100 * time.Millisecond,
+		maxWait:   30 * time.Second,
+		handle:    clog.HandleError,
+		notify:    make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}}
+}
+
+// Tag 设置日志tag,默认 "clog". 设置TagField时作为tag前缀
+func (o *option) Tag(tag string) *option {
+	o.w.tag = tag
+	return o
+}
+
+// TagField 从日志的key字段派生tag, 字段存在时tag为 "<Tag>.<字段值>", 否则使用Tag.
+// 可配合Logger.AppendStrPrefix("logger", name)按logger名称区分tag.
+func (o *option) TagField(key string) *option {
+	o.w.tagField = key
+	return o
+}
+
+// Config 设置解析日志使用的字段名, 应与Logger的WithConfig一致, 默认使用clog.Set设置的默认配置
+func (o *option) Config(c clog.Config) *option {
+	o.w.msgKey = c.MessageFieldName
+	return o
+}
+
+// Ack 设置是否要求服务端确认每个批次,默认关闭
+func (o *option) Ack(enable bool) *option {
+	o.w.ack = enable
+	return o
+}
+
+// BatchSize 设置单批次最大条数,默认512
+func (o *option) BatchSize(n int) *option {
+	if n > 0 {
+		o.w.batchSize = n
+	}
+	return o
+}
+
+// FlushInterval 设置批次最长等待时间,默认1s
+func (o *option) FlushInterval(d time.Duration) *option {
+	if d > 0 {
+		o.w.interval = d
+	}
+	return o
+}
+
+// Buffer 设置待发送日志条数上限,达到上限后丢弃新日志,默认8192
+func (o *option) Buffer(n int) *option {
+	if n > 0 {
+		o.w.limit = n
+	}
+	return o
+}
+
+// Timeout 设置连接,写入与等待ack的超时时间,默认5s
+func (o *option) Timeout(d time.Duration) *option {
+	if d > 0 {
+		o.w.timeout = d
+	}
+	return o
+}
+
+// Backoff 设置重连等待时间区间,每次失败等待时间翻倍并附加随机抖动,默认100ms~30s
+func (o *option) Backoff(min, max time.Duration) *option {
+	if min > 0 && max >= min {
+		o.w.minWait, o.w.maxWait = min, max
+	}
+	return o
+}
+
+// ErrorHandler 设置后台发送错误的处理函数, 默认为clog.HandleError, 即 clog.Set.ErrHandler 设置的处理函数.
+// Logger通过WithConfig设置的ErrorHandler不作用于本输出源.
+func (o *option) ErrorHandler(f func(error)) *option {
+	if f != nil {
+		o.w.handle = f
+	}
+	return o
+}
+
+// Finish 返回Writer实例并启动后台发送协程.
+func (o *option) Finish() *Writer {
+	go o.w.run()
+	return o.w
+}
+
+// Write 实现 io.Writer 接口.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口, 日志编码后写入缓冲并立即返回.
+func (w *Writer) WriteLevel(_ clog.Level, p []byte) (int, error) {
+	e := w.encode(time.Now(), p)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if len(w.pending) >= w.limit {
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrBufferFull
+	}
+	w.pending = append(w.pending, e)
+	if len(w.pending) >= w.batchSize {
+		w.signal()
+	}
+	return len(p), nil
+}
+
+// Dropped 返回因缓冲已满或关闭时未发送被丢弃的日志数.
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close 尝试在超时时间内发送缓冲中的日志后关闭连接,未发送的日志交由ErrorHandler处理.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+// encode 将JSON日志编码为 [time, record], 无法解析的日志作为message字段的值.
+func (w *Writer) encode(t time.Time, p []byte) entry {
+	if n := len(p); n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+	e := entry{tag: w.tag}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	// 按字段原有顺序编码, 数字保持原精度
+	v, err := decodeValue(dec)
+	record, ok := v.(object)
+	if err != nil || !ok {
+		key := w.msgKey
+		if len(key) == 0 {
+			key = clog.MessageFieldName()
+		}
+		record = object{{key: key, val: string(p)}}
+	}
+	if len(w.tagField) > 0 {
+		if s, ok := record.get(w.tagField).(string); ok && len(s) > 0 {
+			if len(e.tag) > 0 {
+				e.tag += "." + s
+			} else {
+				e.tag = s
+			}
+		}
+	}
+	e.data = appendArrayHeader(make([]byte, 0, len(p)+16), 2)
+	e.data = appendEventTime(e.data, t)
+	e.data = appendValue(e.data, record)
+	return e
+}
+
+func (w *Writer) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	var wait time.Duration
+	for {
+		select {
+		case <-w.notify:
+		case <-ticker.C:
+		case <-w.stop:
+			w.closeFlush()
+			return
+		}
+		for !w.flush() {
+			if wait == 0 {
+				wait = w.minWait
+			} else if wait *= 2; wait > w.maxWait {
+				wait = w.maxWait
+			}
+			select {
+			case <-time.After(jitter(wait)):
+			case <-w.stop:
+				w.closeFlush()
+				return
+			}
+		}
+		wait = 0
+	}
+}
+
+// closeFlush 关闭时在超时时间内尽力发送缓冲中的日志.
+func (w *Writer) closeFlush() {
+	deadline := time.Now().Add(w.timeout)
+	for !w.flush() {
+		remain := deadline.Sub(time.Now())
+		if remain <= 0 {
+			w.mu.Lock()
+			n := len(w.pending)
+			w.pending = nil
+			w.mu.Unlock()
+			atomic.AddUint64(&w.dropped, uint64(n))
+			w.handle(fmt.Errorf("clogfluent: drop %d entries on close", n))
+			return
+		}
+		if remain > w.minWait {
+			remain = w.minWait
+		}
+		time.Sleep(remain)
+	}
+}
+
+// flush 按批次发送缓冲中的日志,全部发送完成返回true.
+func (w *Writer) flush() bool {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+		batch := w.pending[:n:n]
+		w.pending = w.pending[n:]
+		w.mu.Unlock()
+		if n == 0 {
+			return true
+		}
+		if w.conn == nil {
+			conn, err := net.DialTimeout(w.network, w.addr, w.timeout)
+			if err != nil {
+				w.requeue(batch)
+				return false
+			}
+			w.conn = conn
+		}
+		if rest := w.sendBatch(batch); len(rest) > 0 {
+			w.requeue(rest)
+			return false
+		}
+	}
+}
+
+// sendBatch 将批次按tag分组发送,返回未发送成功的日志.
+func (w *Writer) sendBatch(batch []entry) []entry {
+	var tags []string
+	groups := make(map[string][]entry)
+	for _, e := range batch {
+		if _, ok := groups[e.tag]; !ok {
+			tags = append(tags, e.tag)
+		}
+		groups[e.tag] = append(groups[e.tag], e)
+	}
+	for i, tag := range tags {
+		if err := w.send(tag, groups[tag]); err != nil {
+			_ = w.conn.Close()
+			w.conn = nil
+			var rest []entry
+			for _, t := range tags[i:] {
+				rest = append(rest, groups[t]...)
+			}
+			return rest
+		}
+	}
+	return nil
+}
+
+// send 以PackedForward模式发送同一tag的日志: [tag, bin(entries), option]
+func (w *Writer) send(tag string, entries []entry) error {
+	size := 0
+	for _, e := range entries {
+		size += len(e.data)
+	}
+	buf := appendArrayHeader(w.buf[:0], 3)
+	buf = appendString(buf, tag)
+	buf = appendBinHeader(buf, size)
+	for _, e := range entries {
+		buf = append(buf, e.data...)
+	}
+	var chunk string
+	if w.ack {
+		chunk = newChunk()
+		buf = appendMapHeader(buf, 2)
+		buf = appendString(buf, "chunk")
+		buf = appendString(buf, chunk)
+	} else {
+		buf = appendMapHeader(buf, 1)
+	}
+	buf = appendString(buf, "size")
+	buf = appendInt(buf, int64(len(entries)))
+	w.buf = buf
+
+	_ = w.conn.SetDeadline(time.Now().Add(w.timeout))
+	if _, err := w.conn.Write(buf); err != nil {
+		return err
+	}
+	if !w.ack {
+		return nil
+	}
+	return w.readAck(chunk)
+}
+
+// readAck 读取服务端响应 {"ack": chunk}.
+func (w *Writer) readAck(chunk string) error {
+	if cap(w.resp) < 256 {
+		w.resp = make([]byte, 256)
+	}
+	resp := w.resp[:0]
+	for {
+		n, err := w.conn.Read(w.resp[len(resp):cap(w.resp)])
+		resp = w.resp[:len(resp)+n]
+		if m, perr := readStringMap(resp); perr == nil {
+			if m["ack"] != chunk {
+				return ErrAck
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp) == cap(w.resp) {
+			return errMsgpack
+		}
+	}
+}
+
+// requeue 将发送失败的日志放回缓冲头部.
+func (w *Writer) requeue(batch []entry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(batch[:len(batch):len(batch)], w.pending...)
+}
+
+func newChunk() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// jitter 返回[d/2, d)区间内的随机时长.
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + mrand.Int63n(half))
+}
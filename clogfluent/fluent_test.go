@@ -0,0 +1,297 @@
+package clogfluent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// decode 解码测试所需的MessagePack子集.
+func decode(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	readLen := func(size int) (int, error) {
+		b, err := readN(size)
+		if err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return int(n), nil
+	}
+	var n int
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return decodeMap(r, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return decodeArray(r, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		b, err := readN(int(c & 0x1f))
+		return string(b), err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = readLen(1 << (c - 0xc4)); err != nil {
+			return nil, err
+		}
+		return readN(n)
+	case 0xcb:
+		b, err := readN(8)
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		b, err := readN(size)
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		shift := uint(64 - size*8)
+		return int64(v<<shift) >> shift, err
+	case 0xd7:
+		b, err := readN(9)
+		if err != nil || b[0] != 0 {
+			return nil, fmt.Errorf("unexpected ext %v", b)
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(b[1:])), int64(binary.BigEndian.Uint32(b[5:]))), nil
+	case 0xd9, 0xda, 0xdb:
+		if n, err = readLen(1 << (c - 0xd9)); err != nil {
+			return nil, err
+		}
+		b, err := readN(n)
+		return string(b), err
+	case 0xdc, 0xdd:
+		if n, err = readLen(2 << (c - 0xdc)); err != nil {
+			return nil, err
+		}
+		return decodeArray(r, n)
+	case 0xde, 0xdf:
+		if n, err = readLen(2 << (c - 0xde)); err != nil {
+			return nil, err
+		}
+		return decodeMap(r, n)
+	}
+	return nil, fmt.Errorf("unexpected type 0x%x", c)
+}
+
+func decodeArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func decodeMap(r *bufio.Reader, n int) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		m[k.(string)] = v
+	}
+	return m, nil
+}
+
+type message struct {
+	tag     string
+	entries []interface{}
+	option  map[string]interface{}
+}
+
+// serve 接收Forward消息, ack(n)为false时第n个连接收到消息后直接断开且不确认.
+func serve(t *testing.T, ln net.Listener, ack func(n int) bool, out chan<- message) {
+	for n := 0; ; n++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(n int) {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				v, err := decode(r)
+				if err != nil {
+					return
+				}
+				arr := v.([]interface{})
+				br := bufio.NewReader(bytes.NewReader(arr[1].([]byte)))
+				var msg message
+				msg.tag = arr[0].(string)
+				msg.option = arr[2].(map[string]interface{})
+				for {
+					e, err := decode(br)
+					if err != nil {
+						break
+					}
+					msg.entries = append(msg.entries, e)
+				}
+				if !ack(n) {
+					return
+				}
+				if chunk, ok := msg.option["chunk"].(string); ok {
+					resp := appendMapHeader(nil, 1)
+					resp = appendString(resp, "ack")
+					resp = appendString(resp, chunk)
+					if _, err = conn.Write(resp); err != nil {
+						t.Error(err)
+					}
+				}
+				out <- msg
+			}
+		}(n)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	out := make(chan message, 8)
+	// 第一个连接不确认直接断开,验证重连与重发
+	go serve(t, ln, func(n int) bool { return n > 0 }, out)
+
+	w := New("tcp", ln.Addr().String()).
+		Tag("app").
+		TagField("logger").
+		Ack(true).
+		BatchSize(3).
+		Backoff(10*time.Millisecond, 50*time.Millisecond).
+		Finish()
+	lines := []string{
+		`{"level":"info","logger":"db","n":1,"f":1.5}`,
+		`{"level":"warn","message":"hello","ok":true,"nil":null}`,
+		`{"level":"info","logger":"db","arr":[1,"a"],"obj":{"k":-100000}}`,
+	}
+	for _, line := range lines {
+		if _, err = w.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string][]interface{})
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-out:
+			if size := msg.option["size"]; size != int64(len(msg.entries)) {
+				t.Errorf("invalid size option: %v", size)
+			}
+			got[msg.tag] = msg.entries
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for forward message")
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Error(err)
+	}
+
+	records := func(entries []interface{}) []interface{} {
+		var rs []interface{}
+		for _, e := range entries {
+			pair := e.([]interface{})
+			if _, ok := pair[0].(time.Time); !ok {
+				t.Errorf("invalid event time: %v", pair[0])
+			}
+			rs = append(rs, pair[1])
+		}
+		return rs
+	}
+	want := map[string][]interface{}{
+		"app.db": {
+			map[string]interface{}{"level": "info", "logger": "db", "n": int64(1), "f": 1.5},
+			map[string]interface{}{"level": "info", "logger": "db", "arr": []interface{}{int64(1), "a"},
+				"obj": map[string]interface{}{"k": int64(-100000)}},
+		},
+		"app": {
+			map[string]interface{}{"level": "warn", "message": "hello", "ok": true, "nil": nil},
+		},
+	}
+	for tag, entries := range want {
+		if rs := records(got[tag]); !reflect.DeepEqual(rs, entries) {
+			t.Errorf("invalid records for tag %s:\ngot:  %v\nwant: %v", tag, rs, entries)
+		}
+	}
+	if _, err = w.Write([]byte("{}")); err != ErrClosed {
+		t.Errorf("write after close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestWriterBufferFull(t *testing.T) {
+	w := New("tcp", "127.0.0.1:1").Buffer(2).Timeout(10 * time.Millisecond).Finish()
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte(`{"n":1}`))
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && err != ErrBufferFull {
+			t.Errorf("got %v, want %v", err, ErrBufferFull)
+		}
+	}
+	_ = w.Close()
+	if d := w.Dropped(); d != 3 {
+		t.Errorf("invalid dropped count: got %d, want 3", d)
+	}
+}
+
+func TestEncodeOrderAndPrecision(t *testing.T) {
+	w := New("tcp", "").Tag("app").w
+	ts := time.Unix(1, 2)
+	e := w.encode(ts, []byte(`{"z":1,"a":{"y":18446744073709551615,"b":123456789012345678901234567890},"m":1.5}`+"\n"))
+
+	want := appendEventTime(appendArrayHeader(nil, 2), ts)
+	want = appendMapHeader(want, 3)
+	want = appendInt(appendString(want, "z"), 1)
+	want = appendMapHeader(appendString(want, "a"), 2)
+	want = appendUint64(append(appendString(want, "y"), 0xcf), math.MaxUint64)
+	want = appendString(appendString(want, "b"), "123456789012345678901234567890")
+	want = appendFloat(appendString(want, "m"), 1.5)
+	if !bytes.Equal(e.data, want) {
+		t.Errorf("invalid record:\ngot:  %x\nwant: %x", e.data, want)
+	}
+}
+
+func TestReadStringMap(t *testing.T) {
+	m, err := readStringMap([]byte{0x81, 0xa3, 'a', 'c', 'k', 0xa1, 'x'})
+	if err != nil || m["ack"] != "x" {
+		t.Errorf("readStringMap = %v %v, want map[ack:x]", m, err)
+	}
+	if _, err = readStringMap([]byte{0xdf, 0xff, 0xff, 0xff, 0xff, 0xa0, 0xa0}); err != errMsgpack {
+		t.Errorf("readStringMap err = %v, want %v", err, errMsgpack)
+	}
+}
@@ -0,0 +1,255 @@
+package clogfluent
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// member JSON对象的字段.
+type member struct {
+	key string
+	val interface{}
+}
+
+// object 按出现顺序保存字段的JSON对象.
+type object []member
+
+// get 返回key字段的值.
+func (o object) get(key string) interface{} {
+	for _, m := range o {
+		if m.key == key {
+			return m.val
+		}
+	}
+	return nil
+}
+
+// decodeValue 解码JSON值, 对象解码为object以保持字段顺序, 数字保持为json.Number. dec需开启UseNumber.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	if d == '[' {
+		arr := []interface{}{}
+		for dec.More() {
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	obj := object{}
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		v, err := decodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, member{key: key, val: v})
+	}
+	_, err = dec.Token()
+	return obj, err
+}
+
+// appendValue 将JSON解码后的值编码为MessagePack.
+func appendValue(dst []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, 0xc0)
+	case bool:
+		if v {
+			return append(dst, 0xc3)
+		}
+		return append(dst, 0xc2)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return appendInt(dst, i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return appendUint64(append(dst, 0xcf), u)
+		}
+		if f, err := v.Float64(); err == nil && strings.ContainsAny(string(v), ".eE") {
+			return appendFloat(dst, f)
+		}
+		// 超出int64 uint64范围的整数与超出float64范围的数以字符串保留原值
+		return appendString(dst, string(v))
+	case string:
+		return appendString(dst, v)
+	case []interface{}:
+		dst = appendArrayHeader(dst, len(v))
+		for _, item := range v {
+			dst = appendValue(dst, item)
+		}
+		return dst
+	case object:
+		dst = appendMapHeader(dst, len(v))
+		for _, m := range v {
+			dst = appendString(dst, m.key)
+			dst = appendValue(dst, m.val)
+		}
+		return dst
+	}
+	return append(dst, 0xc0)
+}
+
+func appendInt(dst []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(dst, byte(i))
+	case i < 0 && i >= -32:
+		return append(dst, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(dst, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return append(dst, 0xd1, byte(i>>8), byte(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return append(dst, 0xd2, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	}
+	dst = append(dst, 0xd3)
+	return appendUint64(dst, uint64(i))
+}
+
+func appendFloat(dst []byte, f float64) []byte {
+	dst = append(dst, 0xcb)
+	return appendUint64(dst, math.Float64bits(f))
+}
+
+func appendString(dst []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = append(dst, 0xda, byte(n>>8), byte(n))
+	default:
+		dst = append(dst, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(dst, s...)
+}
+
+func appendBinHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= math.MaxUint8:
+		return append(dst, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, 0xc5, byte(n>>8), byte(n))
+	}
+	return append(dst, 0xc6, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendArrayHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(dst, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, 0xdc, byte(n>>8), byte(n))
+	}
+	return append(dst, 0xdd, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendMapHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(dst, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, 0xde, byte(n>>8), byte(n))
+	}
+	return append(dst, 0xdf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// appendEventTime 编码Forward协议的EventTime扩展类型(ext type 0).
+func appendEventTime(dst []byte, t time.Time) []byte {
+	dst = append(dst, 0xd7, 0x00)
+	dst = appendUint32(dst, uint32(t.Unix()))
+	return appendUint32(dst, uint32(t.Nanosecond()))
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	return appendUint32(appendUint32(dst, uint32(v>>32)), uint32(v))
+}
+
+var errMsgpack = errors.New("clogfluent: invalid msgpack response")
+
+// readStringMap 解码value均为字符串的MessagePack map,用于解析ack响应.
+func readStringMap(b []byte) (map[string]string, error) {
+	n, b, err := readMapHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	// 每个键值对至少占2字节, 避免按服务端返回的长度预分配过大的map
+	if n > len(b)/2 {
+		return nil, errMsgpack
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		var k, v string
+		if k, b, err = readString(b); err != nil {
+			return nil, err
+		}
+		if v, b, err = readString(b); err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func readMapHeader(b []byte) (int, []byte, error) {
+	if len(b) < 1 {
+		return 0, nil, errMsgpack
+	}
+	switch c := b[0]; {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), b[1:], nil
+	case c == 0xde && len(b) >= 3:
+		return int(binary.BigEndian.Uint16(b[1:])), b[3:], nil
+	case c == 0xdf && len(b) >= 5:
+		return int(binary.BigEndian.Uint32(b[1:])), b[5:], nil
+	}
+	return 0, nil, errMsgpack
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 1 {
+		return "", nil, errMsgpack
+	}
+	var n, h int
+	switch c := b[0]; {
+	case c&0xe0 == 0xa0:
+		n, h = int(c&0x1f), 1
+	case (c == 0xd9 || c == 0xc4) && len(b) >= 2:
+		n, h = int(b[1]), 2
+	case (c == 0xda || c == 0xc5) && len(b) >= 3:
+		n, h = int(binary.BigEndian.Uint16(b[1:])), 3
+	case (c == 0xdb || c == 0xc6) && len(b) >= 5:
+		n, h = int(binary.BigEndian.Uint32(b[1:])), 5
+	default:
+		return "", nil, errMsgpack
+	}
+	if len(b) < h+n {
+		return "", nil, errMsgpack
+	}
+	return string(b[h : h+n]), b[h+n:], nil
+}
@@ -0,0 +1,150 @@
+package cloggrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor 返回记录一元调用日志的客户端拦截器.
+//
+// context中存在请求ID(RequestID)且outgoing metadata未设置时, 写入 x-request-id 向下游传递.
+// 存在请求ID时日志中包含request_id字段.
+//
+//	conn, err := grpc.Dial(addr,
+//		grpc.WithChainUnaryInterceptor(cloggrpc.UnaryClientInterceptor(&log, nil)),
+//		grpc.WithChainStreamInterceptor(cloggrpc.StreamClientInterceptor(&log, nil)),
+//	)
+func UnaryClientInterceptor(l *clog.Logger, opts *Options) grpc.UnaryClientInterceptor {
+	g := newLogger(l, opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx, log := g.clientContext(ctx)
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		if g.skipped(method) {
+			return err
+		}
+		e := g.clientEvent(log, method, cc, err, start)
+		if g.payload {
+			e = g.appendPayload(log, e, "request", req)
+			if err == nil {
+				e = g.appendPayload(log, e, "response", reply)
+			}
+		}
+		e.Msg("grpc client call")
+		return err
+	}
+}
+
+// StreamClientInterceptor 返回记录流式调用日志的客户端拦截器.
+// 调用日志在接收消息返回错误(io.EOF视为OK)或非服务端流式调用收到响应时输出, 记录收发的消息数.
+func StreamClientInterceptor(l *clog.Logger, opts *Options) grpc.StreamClientInterceptor {
+	g := newLogger(l, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx, log := g.clientContext(ctx)
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		skip := g.skipped(method)
+		if err != nil {
+			if !skip {
+				g.clientEvent(log, method, cc, err, start).Msg("grpc client stream")
+			}
+			return cs, err
+		}
+		return &clientStream{ClientStream: cs, desc: desc, cc: cc, method: method, start: start, log: log, g: g, skip: skip}, nil
+	}
+}
+
+func (g *logger) clientContext(ctx context.Context) (context.Context, *clog.Logger) {
+	id := RequestID(ctx)
+	if len(id) == 0 {
+		return ctx, g.log
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); !ok || len(md.Get(RequestIDMetadataKey)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+	}
+	return ctx, g.log.With("request_id", id)
+}
+
+func (g *logger) clientEvent(log *clog.Logger, method string, cc *grpc.ClientConn, err error, start time.Time) *clog.Event {
+	code := status.Code(err)
+	e := log.WithLevel(g.level(code)).
+		Str("method", method).
+		Str("target", cc.Target()).
+		Str("code", code.String()).
+		TimeDur("duration", time.Since(start))
+	if err != nil {
+		e = e.Str("error", status.Convert(err).Message())
+	}
+	return e
+}
+
+// clientStream 统计收发的消息数并在流结束时输出调用日志.
+type clientStream struct {
+	grpc.ClientStream
+	desc   *grpc.StreamDesc
+	cc     *grpc.ClientConn
+	method string
+	start  time.Time
+	log    *clog.Logger
+	g      *logger
+	skip   bool
+	recv   int64
+	sent   int64
+	once   sync.Once
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.finish(err)
+		}
+		return err
+	}
+	atomic.AddInt64(&s.sent, 1)
+	if s.g.payload && !s.skip {
+		s.g.appendPayload(s.log, s.log.WithLevel(s.g.payLevel), "sent", m).Msg("grpc client stream send")
+	}
+	return nil
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if errors.Is(err, io.EOF) {
+		s.finish(nil)
+		return err
+	}
+	if err != nil {
+		s.finish(err)
+		return err
+	}
+	atomic.AddInt64(&s.recv, 1)
+	if s.g.payload && !s.skip {
+		s.g.appendPayload(s.log, s.log.WithLevel(s.g.payLevel), "recv", m).Msg("grpc client stream recv")
+	}
+	if !s.desc.ServerStreams {
+		s.finish(nil)
+	}
+	return nil
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		if s.skip {
+			return
+		}
+		s.g.clientEvent(s.log, s.method, s.cc, err, s.start).
+			Int64("recv_msgs", atomic.LoadInt64(&s.recv)).
+			Int64("sent_msgs", atomic.LoadInt64(&s.sent)).
+			Msg("grpc client stream")
+	})
+}
@@ -0,0 +1,238 @@
+package cloggrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines 等待至少n行日志并解码.
+func (b *syncBuffer) lines(t *testing.T, n int) []map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.Lock()
+		s := strings.TrimSpace(b.buf.String())
+		b.mu.Unlock()
+		var out []map[string]interface{}
+		if len(s) > 0 {
+			for _, line := range strings.Split(s, "\n") {
+				var m map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &m); err != nil {
+					t.Fatalf("invalid log line %q: %v", line, err)
+				}
+				out = append(out, m)
+			}
+		}
+		if len(out) >= n || time.Now().After(deadline) {
+			if len(out) < n {
+				t.Fatalf("want %d log lines, got %d: %s", n, len(out), s)
+			}
+			return out
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type env struct {
+	srvLog *syncBuffer
+	cliLog *syncBuffer
+	client healthpb.HealthClient
+	health *health.Server
+}
+
+func newEnv(t *testing.T, srvOpts, cliOpts *Options) *env {
+	t.Helper()
+	e := &env{srvLog: &syncBuffer{}, cliLog: &syncBuffer{}, health: health.NewServer()}
+	srvLog := clog.NewOption().WithWriter(e.srvLog).WithRedactKeys("service").Logger()
+	cliLog := clog.NewOption().WithWriter(e.cliLog).Logger()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(&srvLog, srvOpts)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(&srvLog, srvOpts)),
+	)
+	healthpb.RegisterHealthServer(srv, e.health)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(&cliLog, cliOpts)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(&cliLog, cliOpts)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	e.client = healthpb.NewHealthClient(conn)
+	return e
+}
+
+func check(t *testing.T, m map[string]interface{}, want map[string]interface{}) {
+	t.Helper()
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("invalid log field %s:\ngot:  %v\nwant: %v\nline: %v", k, m[k], v, m)
+		}
+	}
+}
+
+func TestUnary(t *testing.T) {
+	e := newEnv(t, nil, nil)
+	e.health.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	var header metadata.MD
+	if _, err := e.client.Check(ctx, &healthpb.HealthCheckRequest{Service: "svc"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get(RequestIDMetadataKey); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("invalid request id header:\ngot:  %v\nwant: %v", got, "abc")
+	}
+	check(t, e.srvLog.lines(t, 1)[0], map[string]interface{}{
+		"level":      "info",
+		"request_id": "abc",
+		"method":     "/grpc.health.v1.Health/Check",
+		"code":       "OK",
+		"peer":       "bufconn",
+		"message":    "grpc server call",
+	})
+	cli := e.cliLog.lines(t, 1)[0]
+	check(t, cli, map[string]interface{}{
+		"level":      "info",
+		"request_id": "abc",
+		"method":     "/grpc.health.v1.Health/Check",
+		"target":     "bufnet",
+		"code":       "OK",
+		"message":    "grpc client call",
+	})
+	if _, ok := cli["duration"]; !ok {
+		t.Errorf("missing duration field: %v", cli)
+	}
+}
+
+func TestCodeLevels(t *testing.T) {
+	e := newEnv(t, nil, &Options{CodeLevels: map[codes.Code]clog.Level{codes.NotFound: clog.ErrorLevel}})
+	_, err := e.client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv := e.srvLog.lines(t, 1)[0]
+	check(t, srv, map[string]interface{}{"level": "info", "code": "NotFound", "error": "unknown service"})
+	if id, _ := srv["request_id"].(string); len(id) != 16 {
+		t.Errorf("invalid generated request id: %v", srv["request_id"])
+	}
+	check(t, e.cliLog.lines(t, 1)[0], map[string]interface{}{"level": "error", "code": "NotFound"})
+}
+
+func TestPayload(t *testing.T) {
+	e := newEnv(t, &Options{LogPayload: true}, &Options{LogPayload: true, MaxPayloadSize: 12})
+	e.health.SetServingStatus("secret-svc", healthpb.HealthCheckResponse_SERVING)
+	if _, err := e.client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "secret-svc"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := e.srvLog.lines(t, 1)[0]
+	if got, _ := json.Marshal(srv["request"]); string(got) != `{"service":"******"}` {
+		t.Errorf("invalid request payload:\ngot:  %s\nwant: %s", got, `{"service":"******"}`)
+	}
+	if got, _ := json.Marshal(srv["response"]); string(got) != `{"status":"SERVING"}` {
+		t.Errorf("invalid response payload:\ngot:  %s\nwant: %s", got, `{"status":"SERVING"}`)
+	}
+	check(t, e.cliLog.lines(t, 1)[0], map[string]interface{}{
+		"request":  `{"service":"…(+12 bytes)`,
+		"response": `{"status":"S…(+8 bytes)`,
+	})
+}
+
+func TestPayloadNonProto(t *testing.T) {
+	var buf bytes.Buffer
+	log := clog.NewOption().WithWriter(&buf).Logger()
+	g := newLogger(&log, &Options{MaxPayloadSize: 12})
+	g.appendPayload(&log, log.Info(), "request", map[string]string{"service": "non-proto-svc"}).Msg("")
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["request"] != `{"service":"…(+15 bytes)` {
+		t.Errorf("invalid request payload: %v", got["request"])
+	}
+}
+
+func TestStream(t *testing.T) {
+	e := newEnv(t, &Options{LogPayload: true}, nil)
+	e.health.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := e.client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err = stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	check(t, e.cliLog.lines(t, 1)[0], map[string]interface{}{
+		"method":    "/grpc.health.v1.Health/Watch",
+		"code":      "Canceled",
+		"recv_msgs": float64(1),
+		"sent_msgs": float64(1),
+		"message":   "grpc client stream",
+	})
+	lines := e.srvLog.lines(t, 3)
+	check(t, lines[0], map[string]interface{}{"level": "debug", "message": "grpc server stream recv"})
+	check(t, lines[1], map[string]interface{}{"level": "debug", "message": "grpc server stream send"})
+	check(t, lines[2], map[string]interface{}{
+		"method":    "/grpc.health.v1.Health/Watch",
+		"code":      "Canceled",
+		"recv_msgs": float64(1),
+		"sent_msgs": float64(1),
+		"message":   "grpc server stream",
+	})
+}
+
+func TestSkipMethods(t *testing.T) {
+	e := newEnv(t, &Options{SkipMethods: []string{"/grpc.health.v1.Health/Check"}}, nil)
+	e.health.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	if _, err := e.client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "svc"}); err != nil {
+		t.Fatal(err)
+	}
+	e.cliLog.lines(t, 1)
+	time.Sleep(20 * time.Millisecond)
+	e.srvLog.mu.Lock()
+	defer e.srvLog.mu.Unlock()
+	if e.srvLog.buf.Len() != 0 {
+		t.Errorf("skipped method should not be logged: %s", e.srvLog.buf.String())
+	}
+}
@@ -0,0 +1,129 @@
+// Package cloggrpc 提供记录gRPC调用日志的服务端与客户端拦截器.
+package cloggrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/textutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestIDMetadataKey 传递请求ID的metadata键.
+const RequestIDMetadataKey = "x-request-id"
+
+// DefaultMaxPayloadSize 默认记录的请求与响应内容最大字节数.
+const DefaultMaxPayloadSize = 4096
+
+// Options 拦截器配置, 零值可用.
+type Options struct {
+	// CodeLevels 覆盖DefaultCodeLevel中状态码对应的日志级别
+	CodeLevels map[codes.Code]clog.Level
+	// SkipMethods 不记录日志的完整方法名, 如 /grpc.health.v1.Health/Check
+	SkipMethods []string
+	// LogPayload 记录请求与响应内容. 一元调用记录于调用日志的request response字段,
+	// 流式调用的每条消息以PayloadLevel级别单独记录
+	LogPayload bool
+	// MaxPayloadSize 内容以JSON编码并按Logger的脱敏规则处理后的最大字节数, 超出部分替换为 …(+N bytes), 默认 DefaultMaxPayloadSize
+	MaxPayloadSize int
+	// PayloadLevel 流式调用消息内容的日志级别, 零值为 DebugLevel
+	PayloadLevel clog.Level
+}
+
+type requestIDKey struct{}
+
+// RequestID 返回服务端拦截器注入context的请求ID.
+// 客户端拦截器会将其写入outgoing metadata, 由此在调用链中传递.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// DefaultCodeLevel 返回状态码对应的默认日志级别.
+func DefaultCodeLevel(code codes.Code) clog.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return clog.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange:
+		return clog.WarnLevel
+	}
+	return clog.ErrorLevel
+}
+
+type logger struct {
+	log      *clog.Logger
+	levels   map[codes.Code]clog.Level
+	skip     map[string]struct{}
+	payload  bool
+	max      int
+	payLevel clog.Level
+}
+
+func newLogger(l *clog.Logger, opts *Options) *logger {
+	if opts == nil {
+		opts = &Options{}
+	}
+	g := &logger{
+		log:      l,
+		levels:   opts.CodeLevels,
+		skip:     make(map[string]struct{}, len(opts.SkipMethods)),
+		payload:  opts.LogPayload,
+		max:      opts.MaxPayloadSize,
+		payLevel: opts.PayloadLevel,
+	}
+	for _, m := range opts.SkipMethods {
+		g.skip[m] = struct{}{}
+	}
+	if g.max <= 0 {
+		g.max = DefaultMaxPayloadSize
+	}
+	return g
+}
+
+func (g *logger) skipped(method string) bool {
+	_, ok := g.skip[method]
+	return ok
+}
+
+func (g *logger) level(code codes.Code) clog.Level {
+	if lvl, ok := g.levels[code]; ok {
+		return lvl
+	}
+	return DefaultCodeLevel(code)
+}
+
+// appendPayload 以JSON编码消息内容, 脱敏后按MaxPayloadSize截断.
+func (g *logger) appendPayload(log *clog.Logger, e *clog.Event, key string, msg interface{}) *clog.Event {
+	if e == nil {
+		return e
+	}
+	var (
+		b   []byte
+		err error
+	)
+	if m, ok := msg.(proto.Message); ok {
+		if b, err = protojson.Marshal(m); err == nil {
+			// protojson的输出包含随机空白
+			var buf bytes.Buffer
+			if json.Compact(&buf, b) == nil {
+				b = buf.Bytes()
+			}
+		}
+	} else {
+		b, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return e.Str(key, fmt.Sprintf("!ERROR: %v", err))
+	}
+	b = log.RedactJSON(key, b)
+	if len(b) > g.max {
+		return e.Str(key, textutil.Truncate(string(b), g.max))
+	}
+	return e.RawJSON(key, b)
+}
@@ -0,0 +1,132 @@
+package cloggrpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor 返回记录一元调用日志的服务端拦截器.
+//
+// 请求ID取自metadata x-request-id, 不存在时生成并通过响应header返回. 携带request_id前缀字段的子Logger注入context,
+// 处理函数中通过 clog.Ctx(ctx) 获取. 调用结束后按状态码对应的级别输出 method peer code duration 等字段.
+//
+//	srv := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(cloggrpc.UnaryServerInterceptor(&log, nil)),
+//		grpc.ChainStreamInterceptor(cloggrpc.StreamServerInterceptor(&log, nil)),
+//	)
+func UnaryServerInterceptor(l *clog.Logger, opts *Options) grpc.UnaryServerInterceptor {
+	g := newLogger(l, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, log := g.serverContext(ctx)
+		resp, err := handler(ctx, req)
+		if g.skipped(info.FullMethod) {
+			return resp, err
+		}
+		e := g.serverEvent(ctx, log, info.FullMethod, err, start)
+		if g.payload {
+			e = g.appendPayload(log, e, "request", req)
+			if err == nil {
+				e = g.appendPayload(log, e, "response", resp)
+			}
+		}
+		e.Msg("grpc server call")
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 返回记录流式调用日志的服务端拦截器, 除UnaryServerInterceptor的字段外记录收发的消息数.
+func StreamServerInterceptor(l *clog.Logger, opts *Options) grpc.StreamServerInterceptor {
+	g := newLogger(l, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, log := g.serverContext(ss.Context())
+		s := &serverStream{ServerStream: ss, ctx: ctx, log: log, g: g, skip: g.skipped(info.FullMethod)}
+		err := handler(srv, s)
+		if s.skip {
+			return err
+		}
+		g.serverEvent(ctx, log, info.FullMethod, err, start).
+			Int64("recv_msgs", atomic.LoadInt64(&s.recv)).
+			Int64("sent_msgs", atomic.LoadInt64(&s.sent)).
+			Msg("grpc server stream")
+		return err
+	}
+}
+
+// serverContext 读取或生成请求ID, 返回注入子Logger与请求ID的context.
+func (g *logger) serverContext(ctx context.Context) (context.Context, *clog.Logger) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDMetadataKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if len(id) == 0 {
+		id = requestid.New()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	log := g.log.With("request_id", id)
+	ctx = context.WithValue(log.WithContext(ctx), requestIDKey{}, id)
+	return ctx, log
+}
+
+func (g *logger) serverEvent(ctx context.Context, log *clog.Logger, method string, err error, start time.Time) *clog.Event {
+	code := status.Code(err)
+	e := log.WithLevel(g.level(code)).
+		Str("method", method).
+		Str("code", code.String()).
+		TimeDur("duration", time.Since(start))
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		e = e.Str("peer", p.Addr.String())
+	}
+	if err != nil {
+		e = e.Str("error", status.Convert(err).Message())
+	}
+	return e
+}
+
+// serverStream 替换context并统计收发的消息数.
+type serverStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	log  *clog.Logger
+	g    *logger
+	skip bool
+	recv int64
+	sent int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.recv, 1)
+		if s.g.payload && !s.skip {
+			s.g.appendPayload(s.log, s.log.WithLevel(s.g.payLevel), "recv", m).Msg("grpc server stream recv")
+		}
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+		if s.g.payload && !s.skip {
+			s.g.appendPayload(s.log, s.log.WithLevel(s.g.payLevel), "sent", m).Msg("grpc server stream send")
+		}
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package cloghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+type jsonArray struct{}
+
+// JSONArray 以JSON数组形式发送批次: [{...},{...}]
+func JSONArray() Format {
+	return jsonArray{}
+}
+
+func (jsonArray) ContentType() string {
+	return "application/json"
+}
+
+func (jsonArray) Encode(dst []byte, batch []Entry) []byte {
+	dst = append(dst, '[')
+	for i, e := range batch {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, e.Line...)
+	}
+	return append(dst, ']')
+}
+
+type elasticBulk struct {
+	action []byte
+}
+
+// BulkError Elasticsearch _bulk响应中部分日志写入失败且不可重试.
+type BulkError struct {
+	Failed int    // 写入失败的日志数
+	Status int    // 首个失败项的状态码
+	Reason string // 首个失败项的错误信息
+}
+
+func (e *BulkError) Error() string {
+	return "cloghttp: " + strconv.Itoa(e.Failed) + " bulk items failed, status " + strconv.Itoa(e.Status) + ": " + e.Reason
+}
+
+// ElasticBulk 以Elasticsearch _bulk API的NDJSON格式发送批次,每条日志写入index索引.
+// 响应中状态码为429或5xx的日志按重试策略重发, 其余失败的日志以*BulkError交由ErrorHandler处理.
+func ElasticBulk(index string) Format {
+	b, _ := json.Marshal(index)
+	return elasticBulk{action: []byte(`{"index":{"_index":` + string(b) + `}}` + "\n")}
+}
+
+func (elasticBulk) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (f elasticBulk) Encode(dst []byte, batch []Entry) []byte {
+	for _, e := range batch {
+		dst = append(dst, f.action...)
+		dst = append(dst, e.Line...)
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+// Check 解析_bulk响应, 各项与批次中的日志按顺序对应.
+func (elasticBulk) Check(body []byte, batch []Entry) ([]Entry, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("cloghttp: invalid bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil, nil
+	}
+	var (
+		retry []Entry
+		berr  *BulkError
+	)
+	for i, item := range resp.Items {
+		if i >= len(batch) {
+			break
+		}
+		for _, r := range item {
+			if r.Status < 300 {
+				continue
+			}
+			if r.Status == http.StatusTooManyRequests || r.Status >= 500 {
+				retry = append(retry, batch[i])
+				continue
+			}
+			if berr == nil {
+				berr = &BulkError{Status: r.Status, Reason: r.Error.Type + ": " + r.Error.Reason}
+			}
+			berr.Failed++
+		}
+	}
+	if berr != nil {
+		return retry, berr
+	}
+	return retry, nil
+}
+
+type loki struct {
+	static map[string]string
+	fields []string
+}
+
+// Loki 以Loki push API格式发送批次. static为固定标签, fields为取自日志顶层字段的标签(如level),
+// 标签值相同的日志归入同一stream.
+func Loki(static map[string]string, fields ...string) Format {
+	return loki{static: static, fields: fields}
+}
+
+func (loki) ContentType() string {
+	return "application/json"
+}
+
+func (f loki) Encode(dst []byte, batch []Entry) []byte {
+	var (
+		keys    []string
+		streams = make(map[string][]Entry)
+		labels  = make(map[string]map[string]string)
+		found   = make([]bool, len(f.fields))
+	)
+	for _, e := range batch {
+		set := make(map[string]string, len(f.static)+len(f.fields))
+		for k, v := range f.static {
+			set[k] = v
+		}
+		if len(f.fields) > 0 {
+			// 一次遍历取出全部标签字段, 重复的字段取第一个
+			for i := range found {
+				found[i] = false
+			}
+			n := 0
+			_ = jsonfield.Range(e.Line, func(key string, val json.RawMessage) bool {
+				for i, field := range f.fields {
+					if !found[i] && field == key {
+						set[field] = jsonfield.String(val)
+						found[i] = true
+						n++
+					}
+				}
+				return n < len(f.fields)
+			})
+		}
+		key := labelKey(set)
+		if _, ok := streams[key]; !ok {
+			keys = append(keys, key)
+			labels[key] = set
+		}
+		streams[key] = append(streams[key], e)
+	}
+
+	dst = append(dst, `{"streams":[`...)
+	for i, key := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		stream, _ := json.Marshal(labels[key])
+		dst = append(dst, `{"stream":`...)
+		dst = append(dst, stream...)
+		dst = append(dst, `,"values":[`...)
+		for j, e := range streams[key] {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			line, _ := json.Marshal(string(e.Line))
+			dst = append(dst, `["`...)
+			dst = strconv.AppendInt(dst, e.Time.UnixNano(), 10)
+			dst = append(dst, `",`...)
+			dst = append(dst, line...)
+			dst = append(dst, ']')
+		}
+		dst = append(dst, "]}"...)
+	}
+	return append(dst, "]}"...)
+}
+
+func labelKey(set map[string]string) string {
+	pairs := make([]string, 0, len(set))
+	for k, v := range set {
+		pairs = append(pairs, k+"\x00"+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x01")
+}
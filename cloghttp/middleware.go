@@ -0,0 +1,244 @@
+package cloghttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/requestid"
+)
+
+// DefaultRequestIDHeader 默认的请求ID请求头.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// MiddlewareOptions Middleware配置, 零值可用.
+type MiddlewareOptions struct {
+	// RequestIDHeader 读取与回写请求ID的请求头, 默认 X-Request-ID.
+	// 请求头的值超过128字节或包含字母 数字 - _ . : + / = 以外的字符时重新生成
+	RequestIDHeader string
+	// SkipPaths 不记录访问日志的路径, 以/结尾时按前缀匹配
+	SkipPaths []string
+	// Headers 记录到 headers 字段的请求头
+	Headers []string
+	// TrustedProxies 受信任的代理地址(CIDR或IP), RemoteAddr属于其中时从 X-Forwarded-For 解析客户端IP
+	TrustedProxies []string
+}
+
+type requestIDKey struct{}
+
+// RequestID 返回Middleware注入context的请求ID.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type middleware struct {
+	log     *clog.Logger
+	header  string
+	skip    []string
+	headers []string
+	proxies []*net.IPNet
+}
+
+// Middleware 返回记录访问日志的net/http中间件.
+//
+// 每个请求生成携带 request_id 前缀字段的子Logger并注入请求context, 处理函数中通过 clog.Ctx(r.Context()) 获取.
+// 请求结束后按状态码选择级别(5xx Error, 4xx Warn, 其余 Info)输出 method path status bytes latency remote_ip user_agent 等字段.
+// 处理函数panic时以Error级别记录panic与堆栈并返回500.
+//
+//	http.ListenAndServe(":8080", cloghttp.Middleware(log, nil)(mux))
+func Middleware(l *clog.Logger, opts *MiddlewareOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &MiddlewareOptions{}
+	}
+	m := &middleware{
+		log:     l,
+		header:  opts.RequestIDHeader,
+		skip:    opts.SkipPaths,
+		headers: opts.Headers,
+	}
+	if len(m.header) == 0 {
+		m.header = DefaultRequestIDHeader
+	}
+	for _, p := range opts.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			m.proxies = append(m.proxies, n)
+		} else {
+			clog.HandleError(fmt.Errorf("cloghttp: invalid trusted proxy %q: %w", p, err))
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id := r.Header.Get(m.header)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	w.Header().Set(m.header, id)
+	log := m.log.With("request_id", id)
+	ctx := context.WithValue(log.WithContext(r.Context()), requestIDKey{}, id)
+	r = r.WithContext(ctx)
+	rw := &responseWriter{ResponseWriter: w}
+
+	defer func() {
+		rec := recover()
+		if rec != nil && rec == http.ErrAbortHandler {
+			panic(rec)
+		}
+		if rec != nil && !rw.wrote {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+		if rec == nil && m.skipped(r.URL.Path) {
+			return
+		}
+		var e *clog.Event
+		switch status := rw.status(); {
+		case rec != nil || status >= http.StatusInternalServerError:
+			e = log.Error()
+		case status >= http.StatusBadRequest:
+			e = log.Warn()
+		default:
+			e = log.Info()
+		}
+		e = e.Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rw.status()).
+			Int64("bytes", rw.bytes).
+			TimeDur("latency", time.Since(start)).
+			Str("remote_ip", m.remoteIP(r)).
+			Str("user_agent", r.UserAgent())
+		if len(m.headers) > 0 {
+			h := clog.Dict()
+			for _, name := range m.headers {
+				if v := r.Header.Get(name); len(v) > 0 {
+					h.Str(http.CanonicalHeaderKey(name), v)
+				}
+			}
+			e = e.Dict("headers", h)
+		}
+		if rec != nil {
+			e.Str("panic", fmt.Sprint(rec)).Str("stack", string(debug.Stack())).Msg("http request panic")
+			return
+		}
+		e.Msg("http request")
+	}()
+	next.ServeHTTP(rw, r)
+}
+
+func (m *middleware) skipped(path string) bool {
+	for _, p := range m.skip {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 返回客户端IP. RemoteAddr为受信任代理时, 取 X-Forwarded-For 中从右往左第一个非受信任代理的地址.
+func (m *middleware) remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !m.trusted(host) {
+		return host
+	}
+	xff := r.Header.Values("X-Forwarded-For")
+	var hops []string
+	for _, v := range xff {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !m.trusted(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func (m *middleware) trusted(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range m.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter 记录状态码与写入字节数.
+type responseWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+	wrote bool
+}
+
+func (w *responseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.code = code
+		w.wrote = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wrote = true
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("cloghttp: ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap 供 http.ResponseController 获取原始ResponseWriter.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
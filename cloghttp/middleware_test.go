@@ -0,0 +1,150 @@
+package cloghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cuckooemm/clog"
+)
+
+func serveMiddleware(t *testing.T, opts *MiddlewareOptions, h http.HandlerFunc, req *http.Request) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	log := clog.NewOption().WithWriter(out).WithLogLevel(clog.TraceLevel).Logger()
+	rec := httptest.NewRecorder()
+	Middleware(&log, opts)(h).ServeHTTP(rec, req)
+	if out.Len() == 0 {
+		return rec, nil
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &m); err != nil {
+		t.Fatalf("invalid log output %q: %v", out.String(), err)
+	}
+	return rec, m
+}
+
+func TestMiddleware_AccessLog(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users?id=1", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("X-Tenant", "t1")
+	req.Header.Set("Authorization", "secret")
+	rec, m := serveMiddleware(t, &MiddlewareOptions{Headers: []string{"x-tenant"}}, func(w http.ResponseWriter, r *http.Request) {
+		if got := RequestID(r.Context()); got != "abc" {
+			t.Errorf("invalid request id:\ngot:  %v\nwant: %v", got, "abc")
+		}
+		clog.Ctx(r.Context()).Info().Msg("handler")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("hello"))
+	}, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "abc" {
+		t.Errorf("invalid response header:\ngot:  %v\nwant: %v", got, "abc")
+	}
+	want := map[string]interface{}{
+		"level":      "warn",
+		"request_id": "abc",
+		"method":     "POST",
+		"path":       "/users",
+		"status":     float64(404),
+		"bytes":      float64(5),
+		"remote_ip":  "192.0.2.1",
+		"user_agent": "test",
+		"message":    "http request",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("invalid log field %s:\ngot:  %v\nwant: %v", k, m[k], v)
+		}
+	}
+	if _, ok := m["latency"]; !ok {
+		t.Errorf("missing latency field: %v", m)
+	}
+	headers, _ := m["headers"].(map[string]interface{})
+	if len(headers) != 1 || headers["X-Tenant"] != "t1" {
+		t.Errorf("invalid headers field:\ngot:  %v\nwant: %v", m["headers"], map[string]string{"X-Tenant": "t1"})
+	}
+}
+
+func TestMiddleware_GeneratedID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec, m := serveMiddleware(t, nil, func(w http.ResponseWriter, r *http.Request) {}, req)
+	id := rec.Header().Get("X-Request-ID")
+	if len(id) != 16 || m["request_id"] != id {
+		t.Errorf("invalid request id:\ngot:  %v %v\nwant: 16 hex chars", id, m["request_id"])
+	}
+	if m["level"] != "info" || m["status"] != float64(200) {
+		t.Errorf("invalid log output: %v", m)
+	}
+}
+
+func TestMiddleware_InvalidID(t *testing.T) {
+	for _, id := range []string{"a b", "abc\"}", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", id)
+		rec, m := serveMiddleware(t, nil, func(w http.ResponseWriter, r *http.Request) {}, req)
+		if got := rec.Header().Get("X-Request-ID"); len(got) != 16 || m["request_id"] != got {
+			t.Errorf("request id %q not replaced: %v %v", id, got, m["request_id"])
+		}
+	}
+}
+
+func TestMiddleware_SkipPaths(t *testing.T) {
+	opts := &MiddlewareOptions{SkipPaths: []string{"/healthz", "/static/"}}
+	for _, path := range []string{"/healthz", "/static/app.js"} {
+		_, m := serveMiddleware(t, opts, func(w http.ResponseWriter, r *http.Request) {}, httptest.NewRequest(http.MethodGet, path, nil))
+		if m != nil {
+			t.Errorf("path %s should be skipped, got %v", path, m)
+		}
+	}
+	_, m := serveMiddleware(t, opts, func(w http.ResponseWriter, r *http.Request) {}, httptest.NewRequest(http.MethodGet, "/healthz/x", nil))
+	if m == nil {
+		t.Errorf("path /healthz/x should be logged")
+	}
+}
+
+func TestMiddleware_ForwardedFor(t *testing.T) {
+	tests := []struct {
+		remote string
+		xff    string
+		want   string
+	}{
+		{"10.0.0.1:1234", "203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"10.0.0.1:1234", "1.1.1.1, 203.0.113.9", "203.0.113.9"},
+		{"198.51.100.7:1234", "203.0.113.9", "198.51.100.7"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+	}
+	opts := &MiddlewareOptions{TrustedProxies: []string{"10.0.0.0/8"}}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		if len(tt.xff) > 0 {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		_, m := serveMiddleware(t, opts, func(w http.ResponseWriter, r *http.Request) {}, req)
+		if m["remote_ip"] != tt.want {
+			t.Errorf("invalid remote_ip:\ngot:  %v\nwant: %v", m["remote_ip"], tt.want)
+		}
+	}
+}
+
+func TestMiddleware_Panic(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	rec, m := serveMiddleware(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("invalid status:\ngot:  %v\nwant: %v", rec.Code, http.StatusInternalServerError)
+	}
+	if m["level"] != "error" || m["panic"] != "boom" || m["status"] != float64(500) {
+		t.Errorf("invalid log output: %v", m)
+	}
+	if stack, _ := m["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Errorf("missing stack: %v", m["stack"])
+	}
+}
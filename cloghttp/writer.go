@@ -0,0 +1,432 @@
+package cloghttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+var (
+	// ErrQueueFull 待发送批次队列已满时返回,当前批次被丢弃.
+	ErrQueueFull = errors.New("cloghttp: batch queue is full")
+	// ErrClosed 向已关闭的BatchWriter写入时返回.
+	ErrClosed = errors.New("cloghttp: writer closed")
+	// ErrCloseTimeout Close未能在CloseTimeout内发送全部批次时返回,未发送的批次被丢弃.
+	ErrCloseTimeout = errors.New("cloghttp: close timed out")
+	// ErrRejected 2xx响应中部分日志写入失败且重试次数耗尽.
+	ErrRejected = errors.New("cloghttp: entries rejected by server")
+)
+
+// StatusError 服务端返回非2xx状态码.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return "cloghttp: unexpected status " + strconv.Itoa(e.StatusCode) + ": " + e.Body
+}
+
+// Entry 批次中的一条日志.
+type Entry struct {
+	Time  time.Time
+	Level clog.Level
+	Line  []byte // 不含换行符的JSON日志
+}
+
+// Format 定义批次的请求体格式.
+type Format interface {
+	// ContentType 返回请求体的Content-Type.
+	ContentType() string
+	// Encode 将批次编码为请求体追加至dst.
+	Encode(dst []byte, batch []Entry) []byte
+}
+
+// ResponseChecker 可选接口, Format实现后BatchWriter在2xx响应时检查各条日志的写入结果.
+type ResponseChecker interface {
+	// Check 解析响应体, 返回需要重试的日志, err描述不可重试的失败, 交由ErrorHandler处理
+	Check(body []byte, batch []Entry) (retry []Entry, err error)
+}
+
+// BatchWriter 按条数,字节数或时间间隔批量发送日志的HTTP输出源,实现 clog.LevelWriter 接口.
+// 通过NewBatchWriter()构建.
+type BatchWriter struct {
+	dropped    uint64
+	url        string
+	format     Format
+	client     *http.Client
+	header     http.Header
+	gzip       bool
+	batchSize  int
+	batchBytes int
+	interval   time.Duration
+	retries    int
+	minWait    time.Duration
+	maxWait    time.Duration
+	closeWait  time.Duration
+	handle     func(error)
+
+	mu     sync.Mutex
+	batch  []Entry
+	bytes  int
+	closed bool
+	queue  chan []Entry
+	stop   chan struct{}
+	done   chan struct{}
+	ctx    context.Context // Close超时后取消,中止正在进行的请求与重试
+	cancel context.CancelFunc
+	body   bytes.Buffer
+	gz     *gzip.Writer
+	buf    []byte
+}
+
+type batchOption struct {
+	w *BatchWriter
+}
+
+// NewBatchWriter 构建HTTP批量输出源, 默认以JSON数组格式发送.
+//
+//	w := cloghttp.NewBatchWriter("http://loki:3100/loki/api/v1/push").
+//		Format(cloghttp.Loki(map[string]string{"app": "api"}, "level")).
+//		BatchSize(1000).
+//		FlushInterval(time.Second).
+//		Finish()
+//	defer w.Close()
+func NewBatchWriter(url string) *batchOption {
+	return &batchOption{w: &BatchWriter{
+		url:        url,
+		format:     JSONArray(),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		header:     make(http.Header),
+		gzip:       true,
+		batchSize:  500,
+		batchBytes: 1 << 20,
+		interval:   time.Second,
+		retries:    5,
+		minWait:    200 * time.Millisecond,
+		maxWait:    10 * time.Second,
+		closeWait:  30 * time.Second,
+		handle:     clog.HandleError,
+		queue:      make(chan []Entry, 8),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}}
+}
+
+// Format 设置请求体格式: JSONArray() Loki() ElasticBulk()
+func (o *batchOption) Format(f Format) *batchOption {
+	if f != nil {
+		o.w.format = f
+	}
+	return o
+}
+
+// Client 设置http.Client,默认超时10s
+func (o *batchOption) Client(c *http.Client) *batchOption {
+	if c != nil {
+		o.w.client = c
+	}
+	return o
+}
+
+// Header 添加请求头
+func (o *batchOption) Header(key, value string) *batchOption {
+	o.w.header.Add(key, value)
+	return o
+}
+
+// Gzip 设置是否以gzip压缩请求体,默认开启
+func (o *batchOption) Gzip(enable bool) *batchOption {
+	o.w.gzip = enable
+	return o
+}
+
+// BatchSize 设置单批次最大条数,默认500
+func (o *batchOption) BatchSize(n int) *batchOption {
+	if n > 0 {
+		o.w.batchSize = n
+	}
+	return o
+}
+
+// BatchBytes 设置单批次最大字节数,默认1MiB
+func (o *batchOption) BatchBytes(n int) *batchOption {
+	if n > 0 {
+		o.w.batchBytes = n
+	}
+	return o
+}
+
+// FlushInterval 设置批次最长等待时间,默认1s
+func (o *batchOption) FlushInterval(d time.Duration) *batchOption {
+	if d > 0 {
+		o.w.interval = d
+	}
+	return o
+}
+
+// Queue 设置待发送批次队列长度,队列已满时丢弃新批次,默认8
+func (o *batchOption) Queue(n int) *batchOption {
+	if n > 0 {
+		o.w.queue = make(chan []Entry, n)
+	}
+	return o
+}
+
+// CloseTimeout 设置Close等待剩余批次发送完成的最长时间,默认30s
+func (o *batchOption) CloseTimeout(d time.Duration) *batchOption {
+	if d > 0 {
+		o.w.closeWait = d
+	}
+	return o
+}
+
+// Retry 设置5xx与429响应及网络错误的最大重试次数与退避区间,每次重试等待时间翻倍. 默认5次,200ms~10s
+func (o *batchOption) Retry(max int, minWait, maxWait time.Duration) *batchOption {
+	o.w.retries = max
+	if minWait > 0 && maxWait >= minWait {
+		o.w.minWait, o.w.maxWait = minWait, maxWait
+	}
+	return o
+}
+
+// ErrorHandler 设置后台发送错误的处理函数, 默认为clog.HandleError, 即 clog.Set.ErrHandler 设置的处理函数.
+// Logger通过WithConfig设置的ErrorHandler不作用于本输出源.
+func (o *batchOption) ErrorHandler(f func(error)) *batchOption {
+	if f != nil {
+		o.w.handle = f
+	}
+	return o
+}
+
+// Finish 返回BatchWriter实例并启动后台发送协程.
+func (o *batchOption) Finish() *BatchWriter {
+	if o.w.gzip {
+		o.w.gz = gzip.NewWriter(ioutil.Discard)
+	}
+	o.w.ctx, o.w.cancel = context.WithCancel(context.Background())
+	go o.w.run()
+	return o.w
+}
+
+// Write 实现 io.Writer 接口.
+func (w *BatchWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口, 日志加入当前批次后立即返回.
+func (w *BatchWriter) WriteLevel(l clog.Level, p []byte) (int, error) {
+	line := p
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	// p 在写入完成后会被放回clog的事件池,需拷贝
+	w.batch = append(w.batch, Entry{Time: time.Now(), Level: l, Line: append([]byte(nil), line...)})
+	w.bytes += len(line)
+	if len(w.batch) >= w.batchSize || w.bytes >= w.batchBytes {
+		if err := w.enqueue(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Dropped 返回因队列已满被丢弃的日志数.
+func (w *BatchWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close 发送当前批次及队列中的全部批次后返回, 失败的批次仍按Retry设置重试.
+// 超过CloseTimeout时中止发送并返回ErrCloseTimeout, 未发送的批次交由ErrorHandler处理.
+func (w *BatchWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	batch := w.batch
+	w.batch, w.bytes = nil, 0
+	w.mu.Unlock()
+
+	timer := time.NewTimer(w.closeWait)
+	defer timer.Stop()
+	var err error
+	if len(batch) > 0 {
+		// 队列已满时等待后台协程发送, 不丢弃最后的批次
+		select {
+		case w.queue <- batch:
+		case <-timer.C:
+			w.cancel()
+			w.handle(fmt.Errorf("cloghttp: drop batch of %d entries: %w", len(batch), ErrCloseTimeout))
+			err = ErrCloseTimeout
+		}
+	}
+	close(w.stop)
+	if err == nil {
+		select {
+		case <-w.done:
+			w.cancel()
+			return nil
+		case <-timer.C:
+			w.cancel()
+			err = ErrCloseTimeout
+		}
+	}
+	<-w.done
+	return err
+}
+
+// enqueue 将当前批次放入发送队列,调用方需持有锁.
+func (w *BatchWriter) enqueue() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	batch := w.batch
+	w.batch, w.bytes = nil, 0
+	select {
+	case w.queue <- batch:
+		return nil
+	default:
+		atomic.AddUint64(&w.dropped, uint64(len(batch)))
+		return ErrQueueFull
+	}
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case batch := <-w.queue:
+			w.send(batch)
+		case <-ticker.C:
+			w.mu.Lock()
+			err := w.enqueue()
+			w.mu.Unlock()
+			if err != nil {
+				w.handle(err)
+			}
+		case <-w.stop:
+			for {
+				select {
+				case batch := <-w.queue:
+					w.send(batch)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send 发送批次,失败时按退避时间重试,最终失败交由ErrorHandler处理.
+func (w *BatchWriter) send(batch []Entry) {
+	body := w.encode(batch)
+	checker, _ := w.format.(ResponseChecker)
+	wait := w.minWait
+	for attempt := 0; ; attempt++ {
+		retryAfter, resp, err := w.post(body, checker != nil)
+		if err == nil {
+			if checker == nil {
+				return
+			}
+			retry, cerr := checker.Check(resp, batch)
+			if cerr != nil {
+				w.handle(cerr)
+			}
+			if len(retry) == 0 {
+				return
+			}
+			// 仅重发写入失败的日志
+			batch, err = retry, ErrRejected
+			body = w.encode(batch)
+		}
+		if retryAfter < 0 || attempt >= w.retries {
+			w.handle(fmt.Errorf("cloghttp: drop batch of %d entries: %w", len(batch), err))
+			return
+		}
+		if retryAfter == 0 {
+			retryAfter = wait
+			if wait *= 2; wait > w.maxWait {
+				wait = w.maxWait
+			}
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-w.ctx.Done():
+			w.handle(fmt.Errorf("cloghttp: drop batch of %d entries: %w", len(batch), ErrCloseTimeout))
+			return
+		}
+	}
+}
+
+// encode 编码批次, 开启gzip时返回压缩后的请求体.
+func (w *BatchWriter) encode(batch []Entry) []byte {
+	w.buf = w.format.Encode(w.buf[:0], batch)
+	if w.gz == nil {
+		return w.buf
+	}
+	w.body.Reset()
+	w.gz.Reset(&w.body)
+	_, _ = w.gz.Write(w.buf)
+	_ = w.gz.Close()
+	return w.body.Bytes()
+}
+
+// post 发送请求, retryAfter<0表示错误不可重试, 0表示使用默认退避时间. full为true时返回完整的2xx响应体.
+func (w *BatchWriter) post(body []byte, full bool) (retryAfter time.Duration, resp []byte, err error) {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, nil, err
+	}
+	for k, v := range w.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", w.format.ContentType())
+	if w.gz != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		if full {
+			if resp, err = ioutil.ReadAll(res.Body); err != nil {
+				return 0, nil, err
+			}
+		}
+		return 0, resp, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err = &StatusError{StatusCode: res.StatusCode, Body: string(msg)}
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+		return -1, nil, err
+	}
+	if s, perr := strconv.Atoi(res.Header.Get("Retry-After")); perr == nil && s > 0 {
+		retryAfter = time.Duration(s) * time.Second
+		if retryAfter > w.maxWait {
+			retryAfter = w.maxWait
+		}
+	}
+	return retryAfter, nil, err
+}
@@ -0,0 +1,226 @@
+package cloghttp
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	fail   int
+	bodies []string
+	header http.Header
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail > 0 {
+		r.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, _ := ioutil.ReadAll(body)
+	r.bodies = append(r.bodies, string(b))
+	r.header = req.Header
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestBatchWriter_JSONArray(t *testing.T) {
+	rec := &recorder{fail: 2}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	w := NewBatchWriter(srv.URL).BatchSize(2).Retry(3, time.Millisecond, time.Millisecond).Header("X-Token", "t").Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Int("i", 0).Msg("")
+	log.Info().Int("i", 1).Msg("")
+	log.Warn().Int("i", 2).Msg("")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`[{"level":"info","i":0},{"level":"info","i":1}]`,
+		`[{"level":"warn","i":2}]`,
+	}
+	if len(rec.bodies) != len(want) {
+		t.Fatalf("invalid batches: %q", rec.bodies)
+	}
+	for i := range want {
+		if rec.bodies[i] != want[i] {
+			t.Errorf("invalid batch %d:\ngot:  %v\nwant: %v", i, rec.bodies[i], want[i])
+		}
+	}
+	if got := rec.header.Get("X-Token"); got != "t" {
+		t.Errorf("invalid header X-Token: %q", got)
+	}
+}
+
+func TestBatchWriter_Loki(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	w := NewBatchWriter(srv.URL).Format(Loki(map[string]string{"app": "api"}, "level")).Gzip(false).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Msg("a")
+	log.Error().Msg("b")
+	log.Info().Msg("c")
+	_ = w.Close()
+
+	re := regexp.MustCompile(`"\d{19}"`)
+	got := re.ReplaceAllString(rec.bodies[0], `"0"`)
+	want := `{"streams":[` +
+		`{"stream":{"app":"api","level":"info"},"values":[["0","{\"level\":\"info\",\"message\":\"a\"}"],["0","{\"level\":\"info\",\"message\":\"c\"}"]]},` +
+		`{"stream":{"app":"api","level":"error"},"values":[["0","{\"level\":\"error\",\"message\":\"b\"}"]]}]}`
+	if got != want {
+		t.Errorf("invalid loki body:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestBatchWriter_ElasticBulk(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	w := NewBatchWriter(srv.URL).Format(ElasticBulk("logs")).FlushInterval(10 * time.Millisecond).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Msg("a")
+	time.Sleep(100 * time.Millisecond)
+	_ = w.Close()
+
+	want := `{"index":{"_index":"logs"}}` + "\n" + `{"level":"info","message":"a"}` + "\n"
+	if len(rec.bodies) != 1 || rec.bodies[0] != want {
+		t.Errorf("invalid bulk body:\ngot:  %q\nwant: %q", rec.bodies, want)
+	}
+	if got := rec.header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("invalid content type: %q", got)
+	}
+}
+
+func TestBatchWriter_ElasticBulkErrors(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		errs   []error
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			_, _ = w.Write([]byte(`{"took":1,"errors":true,"items":[` +
+				`{"index":{"status":201}},` +
+				`{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"busy"}}},` +
+				`{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad field"}}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer srv.Close()
+
+	w := NewBatchWriter(srv.URL).Format(ElasticBulk("logs")).Gzip(false).Retry(3, time.Millisecond, time.Millisecond).
+		ErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Log().Msg("a")
+	log.Log().Msg("b")
+	log.Log().Msg("c")
+	_ = w.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 仅重发状态码为429的日志
+	want := `{"index":{"_index":"logs"}}` + "\n" + `{"message":"b"}` + "\n"
+	if len(bodies) != 2 || bodies[1] != want {
+		t.Errorf("invalid retried body:\ngot:  %q\nwant: %q", bodies, want)
+	}
+	var be *BulkError
+	if len(errs) != 1 || !errors.As(errs[0], &be) || be.Failed != 1 || be.Status != 400 {
+		t.Errorf("invalid errors: %v", errs)
+	}
+}
+
+func TestBatchWriter_CloseWaitsForQueue(t *testing.T) {
+	rec := &recorder{}
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		rec.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	w := NewBatchWriter(srv.URL).BatchSize(2).Queue(1).Gzip(false).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	for i := 0; i < 2; i++ {
+		log.Info().Int("i", i).Msg("")
+	}
+	time.Sleep(20 * time.Millisecond) // 首个批次发送中
+	for i := 2; i < 5; i++ {
+		log.Info().Int("i", i).Msg("")
+	}
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.bodies) != 3 || w.Dropped() != 0 {
+		t.Errorf("final batch should not be dropped: %q, dropped %d", rec.bodies, w.Dropped())
+	}
+}
+
+func TestBatchWriter_CloseTimeout(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var dropped []error
+	w := NewBatchWriter(srv.URL).Retry(1000, 10*time.Millisecond, 10*time.Millisecond).CloseTimeout(50 * time.Millisecond).
+		ErrorHandler(func(err error) { dropped = append(dropped, err) }).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Msg("a")
+	start := time.Now()
+	if err := w.Close(); err != ErrCloseTimeout {
+		t.Fatalf("Close err = %v, want %v", err, ErrCloseTimeout)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	// 关闭过程中的重试仍按退避时间等待
+	mu.Lock()
+	defer mu.Unlock()
+	if requests > 10 {
+		t.Errorf("retries without backoff: %d requests", requests)
+	}
+	if len(dropped) != 1 {
+		t.Errorf("dropped batch should be reported once, got %v", dropped)
+	}
+}
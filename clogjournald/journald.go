@@ -0,0 +1,269 @@
+// Package clogjournald 提供写入systemd-journald原生协议的输出源,
+// 日志顶层字段转为大写的journal字段,日志等级映射为PRIORITY.
+package clogjournald
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+// DefaultSocket journald原生协议socket路径.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// errNoJournal journald socket不存在或当前系统不支持时返回,此时写入Fallback输出源.
+var errNoJournal = errors.New("clogjournald: journald socket not available")
+
+// redialInterval socket不存在时重新检查的间隔, 期间日志直接写入Fallback输出源.
+const redialInterval = 5 * time.Second
+
+var now = time.Now
+
+// Priority 返回clog日志等级对应的journal PRIORITY(syslog severity).
+func Priority(l clog.Level) byte {
+	switch l {
+	case clog.TraceLevel, clog.DebugLevel:
+		return '7'
+	case clog.InfoLevel:
+		return '6'
+	case clog.WarnLevel:
+		return '4'
+	case clog.ErrorLevel:
+		return '3'
+	case clog.FatalLevel:
+		return '2'
+	case clog.PanicLevel:
+		return '1'
+	}
+	return '5'
+}
+
+// Writer journald输出源,实现 clog.LevelWriter 接口. 通过New()构建.
+type Writer struct {
+	socket     string
+	identifier string
+	msgKey     string
+	callerKey  string
+	fallback   clog.LevelWriter
+
+	mu      sync.Mutex
+	conn    conn
+	retryAt time.Time // socket不存在时下次检查的时间
+	buf     []byte
+}
+
+type option struct {
+	w        *Writer
+	fallback io.Writer
+}
+
+// New 构建journald输出源
+//
+//	w := clogjournald.New().Identifier("api").Finish()
+//	clog.NewOption().WithWriter(w).Logger()
+func New() *option {
+	return &option{
+		w: &Writer{
+			socket:     DefaultSocket,
+			identifier: filepath.Base(os.Args[0]),
+		},
+		fallback: os.Stderr,
+	}
+}
+
+// Socket 设置journald socket路径,默认 /run/systemd/journal/socket
+func (o *option) Socket(path string) *option {
+	if len(path) > 0 {
+		o.w.socket = path
+	}
+	return o
+}
+
+// Identifier 设置SYSLOG_IDENTIFIER字段,默认为程序名
+func (o *option) Identifier(name string) *option {
+	o.w.identifier = name
+	return o
+}
+
+// Config 设置解析日志使用的字段名, 应与Logger的WithConfig一致, 默认使用clog.Set设置的默认配置
+func (o *option) Config(c clog.Config) *option {
+	o.w.msgKey, o.w.callerKey = c.MessageFieldName, c.CallerFieldName
+	return o
+}
+
+// Fallback 设置journald socket不存在时的输出源,默认os.Stderr,为nil时丢弃日志
+func (o *option) Fallback(w io.Writer) *option {
+	o.fallback = w
+	return o
+}
+
+// Finish 返回Writer实例, socket不存在时日志写入Fallback设置的输出源.
+func (o *option) Finish() *Writer {
+	if o.fallback != nil {
+		if lw, ok := o.fallback.(clog.LevelWriter); ok {
+			o.w.fallback = lw
+		} else {
+			o.w.fallback = levelWriterAdapter{o.fallback}
+		}
+	}
+	return o.w
+}
+
+type levelWriterAdapter struct {
+	io.Writer
+}
+
+func (lw levelWriterAdapter) WriteLevel(_ clog.Level, p []byte) (int, error) {
+	return lw.Write(p)
+}
+
+// Enabled 判断journald socket是否可用.
+func Enabled() bool {
+	_, err := os.Stat(DefaultSocket)
+	return err == nil
+}
+
+// Write 实现 io.Writer 接口,以NoLevel等级写入.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口.
+func (w *Writer) WriteLevel(l clog.Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		var err error
+		if now().Before(w.retryAt) {
+			err = errNoJournal
+		} else if w.conn, err = dial(w.socket); err == errNoJournal {
+			w.retryAt = now().Add(redialInterval)
+		}
+		if err == errNoJournal {
+			if w.fallback == nil {
+				return len(p), nil
+			}
+			return w.fallback.WriteLevel(l, p)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	w.buf = w.appendEntry(w.buf[:0], l, p)
+	if err := w.conn.send(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭socket.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// appendEntry 将日志事件编码为journal原生协议数据.
+func (w *Writer) appendEntry(dst []byte, l clog.Level, p []byte) []byte {
+	if n := len(p); n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+	dst = appendField(dst, "PRIORITY", []byte{Priority(l)})
+	if len(w.identifier) > 0 {
+		dst = appendField(dst, "SYSLOG_IDENTIFIER", []byte(w.identifier))
+	}
+	var (
+		msgKey    = w.msgKey
+		callerKey = w.callerKey
+		hasMsg    bool
+	)
+	if len(msgKey) == 0 {
+		msgKey = clog.MessageFieldName()
+	}
+	if len(callerKey) == 0 {
+		callerKey = clog.CallerFieldName()
+	}
+	err := jsonfield.Range(p, func(key string, val json.RawMessage) bool {
+		switch key {
+		case msgKey:
+			dst = appendField(dst, "MESSAGE", []byte(jsonfield.String(val)))
+			hasMsg = true
+			return true
+		case callerKey:
+			caller := jsonfield.String(val)
+			if file, line, ok := jsonfield.Caller(caller); ok {
+				dst = appendField(dst, "CODE_FILE", []byte(file))
+				dst = appendField(dst, "CODE_LINE", []byte(line))
+				return true
+			}
+		}
+		if name := fieldName(key); len(name) > 0 {
+			dst = appendField(dst, name, []byte(jsonfield.String(val)))
+		}
+		return true
+	})
+	if err != nil {
+		return appendField(dst, "MESSAGE", p)
+	}
+	if !hasMsg {
+		// journal要求MESSAGE字段,无message时使用完整日志
+		dst = appendField(dst, "MESSAGE", p)
+	}
+	return dst
+}
+
+// appendField 写入一个journal字段, value包含换行时使用二进制长度前缀形式.
+func appendField(dst []byte, name string, val []byte) []byte {
+	dst = append(dst, name...)
+	for _, c := range val {
+		if c == '\n' {
+			var size [8]byte
+			binary.LittleEndian.PutUint64(size[:], uint64(len(val)))
+			dst = append(dst, '\n')
+			dst = append(dst, size[:]...)
+			dst = append(dst, val...)
+			return append(dst, '\n')
+		}
+	}
+	dst = append(dst, '=')
+	dst = append(dst, val...)
+	return append(dst, '\n')
+}
+
+// fieldName 将日志字段名转为journal字段名: 大写字母,数字与下划线组成,不能以下划线或数字开头,最长64字符.
+func fieldName(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key) && b.Len() < 64; i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+			if b.Len() == 0 {
+				continue
+			}
+		default:
+			if b.Len() == 0 {
+				continue
+			}
+			c = '_'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package clogjournald
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const memfdName = "clog-journal"
+
+type conn interface {
+	send(p []byte) error
+	Close() error
+}
+
+type unixConn struct {
+	*net.UnixConn
+	addr *net.UnixAddr
+}
+
+func dial(path string) (conn, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, errNoJournal
+		}
+		return nil, err
+	}
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{UnixConn: c, addr: &net.UnixAddr{Name: path, Net: "unixgram"}}, nil
+}
+
+// send 发送数据报,超出socket数据报大小限制时写入memfd并传递文件描述符.
+func (c *unixConn) send(p []byte) error {
+	_, _, err := c.WriteMsgUnix(p, nil, c.addr)
+	if err == nil {
+		return nil
+	}
+	if !isTooLarge(err) {
+		return err
+	}
+	f, err := payloadFile(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = c.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), c.addr)
+	return err
+}
+
+func isTooLarge(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.EMSGSIZE || errno == syscall.ENOBUFS
+}
+
+// payloadFile 将数据写入密封的memfd, 内核不支持时使用/dev/shm下已删除的临时文件.
+func payloadFile(p []byte) (*os.File, error) {
+	if f, err := memfd(p); err == nil {
+		return f, nil
+	}
+	f, err := ioutil.TempFile("/dev/shm", "clog-journal-")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err = f.Write(p); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func memfd(p []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate(memfdName, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), memfdName)
+	if _, err = f.Write(p); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	seals := unix.F_SEAL_SEAL | unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE
+	if _, err = unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package clogjournald
+
+type conn interface {
+	send(p []byte) error
+	Close() error
+}
+
+func dial(string) (conn, error) {
+	return nil, errNoJournal
+}
@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+package clogjournald
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+func listen(t *testing.T) (*net.UnixConn, string) {
+	dir, err := ioutil.TempDir("", "clogjournald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "socket")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, path
+}
+
+func TestWriter(t *testing.T) {
+	c, path := listen(t)
+	w := New().Socket(path).Identifier("api").Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Warn().Str("request-id", "abc").Str("caller", "/src/main.go:12").Str("body", "a\nb").Msg("hello")
+
+	buf := make([]byte, 4096)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "PRIORITY=4\nSYSLOG_IDENTIFIER=api\nLEVEL=warn\nREQUEST_ID=abc\nCODE_FILE=/src/main.go\nCODE_LINE=12\n" +
+		"BODY\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nMESSAGE=hello\n"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("invalid journal entry:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestWriter_LargePayload(t *testing.T) {
+	c, path := listen(t)
+	_ = c.SetReadBuffer(1 << 12)
+	w := New().Socket(path).Identifier("").Finish()
+	defer w.Close()
+	large := strings.Repeat("x", 1<<22)
+	log := clog.NewOption().WithWriter(w).Logger()
+	go log.Info().Msg(large)
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, oobn, _, _, err := c.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("invalid control message: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("invalid unix rights: %v", err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "payload")
+	defer f.Close()
+	_, _ = f.Seek(0, 0)
+	got, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("PRIORITY=6\nLEVEL=info\nMESSAGE=" + large + "\n"); !bytes.Equal(got, want) {
+		t.Errorf("invalid payload length %d, want %d", len(got), len(want))
+	}
+}
+
+func TestWriter_Fallback(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := New().Socket("/nonexistent/journal/socket").Fallback(out).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Msg("hello")
+	if got, want := out.String(), `{"level":"info","message":"hello"}`+"\n"; got != want {
+		t.Errorf("invalid fallback output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestWriter_Redial(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	ts := time.Now()
+	now = func() time.Time { return ts }
+
+	c, path := listen(t)
+	_ = c.Close()
+	_ = os.Remove(path)
+	out := &bytes.Buffer{}
+	w := New().Socket(path).Fallback(out).Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Msg("a")
+
+	// socket恢复后在重新检查的间隔内仍写入Fallback输出源
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	log.Info().Msg("b")
+	if got, want := out.String(), `{"level":"info","message":"a"}`+"\n"+`{"level":"info","message":"b"}`+"\n"; got != want {
+		t.Errorf("invalid fallback output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	ts = ts.Add(redialInterval)
+	log.Info().Msg("c")
+	buf := make([]byte, 1024)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf[:n], []byte("MESSAGE=c\n")) {
+		t.Errorf("invalid journal entry: %q", buf[:n])
+	}
+}
@@ -0,0 +1,85 @@
+package clogkafka
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+// ErrProducerClosed 向已关闭的MemoryProducer发送消息时返回.
+var ErrProducerClosed = errors.New("clogkafka: producer closed")
+
+// Message 待发布至topic的一条日志.
+type Message struct {
+	Topic string
+	Key   []byte // KeyField字段值,字段不存在时为nil
+	Value []byte // 不含换行符的JSON日志
+	Level clog.Level
+	Time  time.Time
+}
+
+// Producer 消息发布接口, 可基于sarama,franz-go,segmentio/kafka-go等客户端实现.
+type Producer interface {
+	// Produce 同步发布一批消息,返回的错误视为整批投递失败. 返回后msgs会被复用,需保留时应拷贝.
+	Produce(msgs []Message) error
+	// Close 关闭Producer.
+	Close() error
+}
+
+// MemoryProducer 将消息保存在内存中的Producer,用于测试,无需broker.
+type MemoryProducer struct {
+	mu     sync.Mutex
+	msgs   []Message
+	err    error
+	closed bool
+}
+
+// NewMemoryProducer 构建内存Producer.
+func NewMemoryProducer() *MemoryProducer {
+	return new(MemoryProducer)
+}
+
+// Produce 实现 Producer 接口.
+func (p *MemoryProducer) Produce(msgs []Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+// Fail 设置后续Produce返回的错误,为nil时恢复正常.
+func (p *MemoryProducer) Fail(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+// Messages 返回已发布消息的副本.
+func (p *MemoryProducer) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.msgs...)
+}
+
+// Reset 清空已发布消息.
+func (p *MemoryProducer) Reset() {
+	p.mu.Lock()
+	p.msgs = nil
+	p.mu.Unlock()
+}
+
+// Close 实现 Producer 接口.
+func (p *MemoryProducer) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,233 @@
+// Package clogkafka 提供将日志发布至Kafka兼容topic的输出源.
+// 具体客户端通过 Producer 接口接入,包内提供用于测试的 MemoryProducer.
+package clogkafka
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+var (
+	// ErrQueueFull Drop策略下队列已满时返回,日志被丢弃.
+	ErrQueueFull = errors.New("clogkafka: queue is full")
+	// ErrClosed 向已关闭的Writer写入时返回.
+	ErrClosed = errors.New("clogkafka: writer closed")
+)
+
+// Policy 队列已满时的处理策略.
+type Policy uint8
+
+const (
+	// Block 阻塞写入直至队列有空位,对调用方产生背压.
+	Block Policy = iota
+	// Drop 丢弃日志并返回ErrQueueFull.
+	Drop
+)
+
+// DeliveryError 一批消息投递失败.
+type DeliveryError struct {
+	Topic    string
+	Messages int
+	Err      error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("clogkafka: deliver %d messages to %s: %s", e.Messages, e.Topic, e.Err.Error())
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Writer 批量发布日志的输出源,实现 clog.LevelWriter 接口. 通过New()构建.
+type Writer struct {
+	dropped   uint64
+	failed    uint64
+	producer  Producer
+	topic     string
+	keyField  string
+	batchSize int
+	interval  time.Duration
+	policy    Policy
+	handle    func(error)
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Message
+	done   chan struct{}
+}
+
+type option struct {
+	w *Writer
+}
+
+// New 构建输出源, 日志发布至topic, Close时一并关闭producer.
+//
+//	w := clogkafka.New(producer, "app-logs").KeyField("request_id").BatchSize(200).Policy(clogkafka.Drop).Finish()
+//	defer w.Close()
+//	clog.NewOption().WithWriter(w).Logger()
+func New(p Producer, topic string) *option {
+	return &option{w: &Writer{
+		producer:  p,
+		topic:     topic,
+		batchSize: 100,
+		interval:  time.Second,
+		handle:    clog.HandleError,
+		queue:     make(chan Message, 4096),
+		done:      make(chan struct{}),
+	}}
+}
+
+// KeyField 以日志的key字段值作为消息key, 相同key的消息进入同一分区
+func (o *option) KeyField(key string) *option {
+	o.w.keyField = key
+	return o
+}
+
+// BatchSize 设置单批次最大条数,默认100
+func (o *option) BatchSize(n int) *option {
+	if n > 0 {
+		o.w.batchSize = n
+	}
+	return o
+}
+
+// FlushInterval 设置批次最长等待时间,默认1s
+func (o *option) FlushInterval(d time.Duration) *option {
+	if d > 0 {
+		o.w.interval = d
+	}
+	return o
+}
+
+// Queue 设置待发布队列长度,默认4096
+func (o *option) Queue(n int) *option {
+	if n > 0 {
+		o.w.queue = make(chan Message, n)
+	}
+	return o
+}
+
+// Policy 设置队列已满时的处理策略,默认Block
+func (o *option) Policy(p Policy) *option {
+	o.w.policy = p
+	return o
+}
+
+// ErrorHandler 设置后台发布错误的处理函数, 默认为clog.HandleError, 即 clog.Set.ErrHandler 设置的处理函数.
+// Logger通过WithConfig设置的ErrorHandler不作用于本输出源.
+func (o *option) ErrorHandler(f func(error)) *option {
+	if f != nil {
+		o.w.handle = f
+	}
+	return o
+}
+
+// Finish 返回Writer实例并启动后台发布协程.
+func (o *option) Finish() *Writer {
+	if o.w.producer == nil {
+		panic("clogkafka: nil producer")
+	}
+	go o.w.run()
+	return o.w
+}
+
+// Write 实现 io.Writer 接口.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口.
+func (w *Writer) WriteLevel(l clog.Level, p []byte) (int, error) {
+	line := p
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	// p 在写入完成后会被放回clog的事件池,需拷贝
+	msg := Message{Topic: w.topic, Value: append([]byte(nil), line...), Level: l, Time: time.Now()}
+	if len(w.keyField) > 0 {
+		if val, ok := jsonfield.Lookup(line, w.keyField); ok {
+			msg.Key = []byte(jsonfield.String(val))
+		}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.policy == Block {
+		w.queue <- msg
+		return len(p), nil
+	}
+	select {
+	case w.queue <- msg:
+		return len(p), nil
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrQueueFull
+	}
+}
+
+// Dropped 返回因队列已满被丢弃的日志数.
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Failed 返回投递失败的日志数.
+func (w *Writer) Failed() uint64 {
+	return atomic.LoadUint64(&w.failed)
+}
+
+// Close 发布队列中的全部日志后关闭producer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	return w.producer.Close()
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	batch := make([]Message, 0, w.batchSize)
+	for {
+		select {
+		case msg, ok := <-w.queue:
+			if !ok {
+				w.produce(batch)
+				return
+			}
+			if batch = append(batch, msg); len(batch) >= w.batchSize {
+				w.produce(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.produce(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// produce 发布批次,失败时交由ErrorHandler处理.
+func (w *Writer) produce(batch []Message) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := w.producer.Produce(batch); err != nil {
+		atomic.AddUint64(&w.failed, uint64(len(batch)))
+		w.handle(&DeliveryError{Topic: w.topic, Messages: len(batch), Err: err})
+	}
+}
@@ -0,0 +1,134 @@
+package clogkafka
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+func TestWriter(t *testing.T) {
+	p := NewMemoryProducer()
+	w := New(p, "logs").KeyField("request_id").BatchSize(2).Finish()
+	lines := []string{
+		`{"level":"info","request_id":"r1","message":"a"}` + "\n",
+		`{"level":"warn","message":"b"}` + "\n",
+		`{"level":"error","request_id":"r2","message":"c"}` + "\n",
+	}
+	levels := []clog.Level{clog.InfoLevel, clog.WarnLevel, clog.ErrorLevel}
+	for i, line := range lines {
+		if _, err := w.WriteLevel(levels[i], []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := p.Messages()
+	if len(msgs) != len(lines) {
+		t.Fatalf("invalid message count: got %d, want %d", len(msgs), len(lines))
+	}
+	keys := []string{"r1", "", "r2"}
+	for i, msg := range msgs {
+		if msg.Topic != "logs" || string(msg.Key) != keys[i] || msg.Level != levels[i] {
+			t.Errorf("invalid message %d: %+v", i, msg)
+		}
+		if got, want := string(msg.Value), lines[i][:len(lines[i])-1]; got != want {
+			t.Errorf("invalid message value:\ngot:  %v\nwant: %v", got, want)
+		}
+	}
+	if _, err := w.Write([]byte("{}")); err != ErrClosed {
+		t.Errorf("write after close: got %v, want %v", err, ErrClosed)
+	}
+	if err := p.Produce(nil); err != ErrProducerClosed {
+		t.Errorf("producer not closed: got %v", err)
+	}
+}
+
+func TestWriterDeliveryError(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	clog.Set.ErrHandler(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	defer clog.Set.ErrHandler(nil)
+
+	fail := errors.New("broker unavailable")
+	p := NewMemoryProducer()
+	p.Fail(fail)
+	w := New(p, "logs").BatchSize(10).Finish()
+	for i := 0; i < 3; i++ {
+		_, _ = w.Write([]byte(`{"n":1}`))
+	}
+	_ = w.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 {
+		t.Fatalf("invalid error count: got %d, want 1", len(errs))
+	}
+	var de *DeliveryError
+	if !errors.As(errs[0], &de) || de.Messages != 3 || !errors.Is(de, fail) {
+		t.Errorf("invalid delivery error: %v", errs[0])
+	}
+	if n := w.Failed(); n != 3 {
+		t.Errorf("invalid failed count: got %d, want 3", n)
+	}
+}
+
+// blockingProducer 在release关闭前阻塞Produce.
+type blockingProducer struct {
+	MemoryProducer
+	release chan struct{}
+}
+
+func (p *blockingProducer) Produce(msgs []Message) error {
+	<-p.release
+	return p.MemoryProducer.Produce(msgs)
+}
+
+func TestWriterPolicy(t *testing.T) {
+	p := &blockingProducer{release: make(chan struct{})}
+	w := New(p, "logs").BatchSize(1).Queue(1).Policy(Drop).Finish()
+	// 第一条被后台协程取出并阻塞在Produce,第二条占满队列
+	_, _ = w.Write([]byte(`{"n":1}`))
+	time.Sleep(50 * time.Millisecond)
+	_, _ = w.Write([]byte(`{"n":2}`))
+	if _, err := w.Write([]byte(`{"n":3}`)); err != ErrQueueFull {
+		t.Errorf("got %v, want %v", err, ErrQueueFull)
+	}
+	if n := w.Dropped(); n != 1 {
+		t.Errorf("invalid dropped count: got %d, want 1", n)
+	}
+
+	// Block策略下写入等待队列空位
+	bp := &blockingProducer{release: make(chan struct{})}
+	bw := New(bp, "logs").BatchSize(1).Queue(1).Finish()
+	_, _ = bw.Write([]byte(`{"n":1}`))
+	time.Sleep(50 * time.Millisecond)
+	_, _ = bw.Write([]byte(`{"n":2}`))
+	written := make(chan struct{})
+	go func() {
+		_, _ = bw.Write([]byte(`{"n":3}`))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Error("write should block while queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(bp.release)
+	<-written
+	close(p.release)
+	_ = w.Close()
+	_ = bw.Close()
+	if n := len(bp.Messages()); n != 3 {
+		t.Errorf("invalid message count: got %d, want 3", n)
+	}
+}
@@ -0,0 +1,318 @@
+// Package clognet 提供通过TCP,UDP或unix socket发送换行分隔JSON(NDJSON)日志的输出源,
+// 适用于直接投递至Vector,Fluent Bit等日志采集器.
+package clognet
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/storage"
+)
+
+var (
+	// ErrBufferFull 内存缓冲已满且未开启磁盘溢出时返回,日志被丢弃.
+	ErrBufferFull = errors.New("clognet: buffer is full")
+	// ErrClosed 向已关闭的NetWriter写入时返回.
+	ErrClosed = errors.New("clognet: writer closed")
+)
+
+// NetWriter 异步网络输出源,实现 clog.LevelWriter 接口. 通过New()构建.
+//
+// 日志先写入内存缓冲,由后台协程发送. 连接断开时以带随机抖动的指数退避重连,
+// 内存缓冲写满后溢出至磁盘队列,连接恢复后按写入顺序先发送内存缓冲再回放磁盘队列.
+type NetWriter struct {
+	dropped  uint64
+	network  string
+	addr     string
+	limit    int
+	timeout  time.Duration
+	minWait  time.Duration
+	maxWait  time.Duration
+	spool    *storage.Queue
+	spilling bool // 磁盘队列非空,新日志需写入磁盘队列以保证顺序
+
+	mu     sync.Mutex
+	mem    [][]byte
+	size   int
+	closed bool
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	conn   net.Conn
+}
+
+type option struct {
+	w         *NetWriter
+	spoolPath string
+	spoolSize int
+}
+
+// New 构建网络输出源, network可选 "tcp" "udp" "unix" "unixgram"
+//
+//	w := clognet.New("tcp", "127.0.0.1:9000").Buffer(8 << 20).Spill("./spool/net", 1024).Finish()
+//	defer w.Close()
+//	clog.NewOption().WithWriter(w).Logger()
+func New(network, addr string) *option {
+	return &option{w: &NetWriter{
+		network: network,
+		addr:    addr,
+		limit:   4 << 20,
+		timeout: 5 * time.Second,
+		minWait: 100 * time.Millisecond,
+		maxWait: 30 * time.Second,
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}}
+}
+
+// Buffer 设置内存缓冲大小上限,单位字节,默认4MiB
+func (o *option) Buffer(size int) *option {
+	if size > 0 {
+		o.w.limit = size
+	}
+	return o
+}
+
+// Spill 开启磁盘溢出, 内存缓冲写满后日志写入path前缀的磁盘队列, maxSize为磁盘队列大小上限,单位Mb, 0为不限制.
+// 进程重启后未回放的磁盘队列会继续回放.
+func (o *option) Spill(path string, maxSize int) *option {
+	o.spoolPath, o.spoolSize = path, maxSize
+	return o
+}
+
+// Timeout 设置连接与写入超时,默认5s
+func (o *option) Timeout(d time.Duration) *option {
+	if d > 0 {
+		o.w.timeout = d
+	}
+	return o
+}
+
+// Backoff 设置重连等待时间区间,每次失败等待时间翻倍并附加随机抖动,默认100ms~30s
+func (o *option) Backoff(min, max time.Duration) *option {
+	if min > 0 && max >= min {
+		o.w.minWait, o.w.maxWait = min, max
+	}
+	return o
+}
+
+// Finish 返回NetWriter实例并启动后台发送协程.
+func (o *option) Finish() *NetWriter {
+	if len(o.spoolPath) > 0 {
+		o.w.spool = storage.NewQueue(o.spoolPath).MaxSize(o.spoolSize).Finish()
+		o.w.spilling = o.w.spool.Len() > 0
+	}
+	go o.w.run()
+	o.w.signal()
+	return o.w
+}
+
+// Write 实现 io.Writer 接口.
+func (w *NetWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口, 日志写入缓冲后立即返回.
+func (w *NetWriter) WriteLevel(_ clog.Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if !w.spilling && w.size+len(p) <= w.limit {
+		// p 在写入完成后会被放回clog的事件池,需拷贝
+		w.mem = append(w.mem, append([]byte(nil), p...))
+		w.size += len(p)
+		w.signal()
+		return len(p), nil
+	}
+	if w.spool == nil {
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrBufferFull
+	}
+	if _, err := w.spool.Write(p); err != nil {
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, err
+	}
+	w.spilling = true
+	w.signal()
+	return len(p), nil
+}
+
+// Dropped 返回因缓冲已满被丢弃的日志数.
+func (w *NetWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close 尝试在超时时间内发送缓冲中的日志,未发送的日志写入磁盘队列(如已开启)后关闭连接.
+func (w *NetWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+
+	var err error
+	if w.spool != nil {
+		// 内存缓冲中的日志早于磁盘队列中的日志, 写入队列头部以保证回放顺序
+		w.mu.Lock()
+		err = w.spool.WriteFront(w.mem)
+		w.mem, w.size = nil, 0
+		w.mu.Unlock()
+		if cerr := w.spool.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if w.conn != nil {
+		if cerr := w.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (w *NetWriter) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *NetWriter) run() {
+	defer close(w.done)
+	var wait time.Duration
+	for {
+		select {
+		case <-w.notify:
+		case <-w.stop:
+			w.closeFlush()
+			return
+		}
+		for !w.flush() {
+			if wait == 0 {
+				wait = w.minWait
+			} else if wait *= 2; wait > w.maxWait {
+				wait = w.maxWait
+			}
+			select {
+			case <-time.After(jitter(wait)):
+			case <-w.stop:
+				w.closeFlush()
+				return
+			}
+		}
+		wait = 0
+	}
+}
+
+// closeFlush 关闭时在超时时间内尽力发送缓冲中的日志.
+func (w *NetWriter) closeFlush() {
+	deadline := time.Now().Add(w.timeout)
+	for {
+		if w.flush() {
+			return
+		}
+		remain := deadline.Sub(time.Now())
+		if remain <= 0 {
+			return
+		}
+		if remain > w.minWait {
+			remain = w.minWait
+		}
+		time.Sleep(remain)
+	}
+}
+
+// flush 依次发送内存缓冲与磁盘队列中的日志,全部发送完成返回true.
+func (w *NetWriter) flush() bool {
+	for {
+		if w.idle() {
+			return true
+		}
+		if w.conn == nil {
+			conn, err := net.DialTimeout(w.network, w.addr, w.timeout)
+			if err != nil {
+				return false
+			}
+			w.conn = conn
+		}
+		w.mu.Lock()
+		batch := w.mem
+		w.mem, w.size = nil, 0
+		w.mu.Unlock()
+		for i, p := range batch {
+			if err := w.send(p); err != nil {
+				w.requeue(batch[i:])
+				return false
+			}
+		}
+		if w.spool != nil {
+			sent := 0
+			if err := w.spool.Drain(func(p []byte) error {
+				if err := w.send(p); err != nil {
+					return err
+				}
+				sent++
+				return nil
+			}); err != nil {
+				return false
+			}
+			// 本轮未发送任何日志时退避重试, 避免空转
+			if sent == 0 && len(batch) == 0 {
+				return false
+			}
+		}
+	}
+}
+
+// idle 缓冲与磁盘队列均为空时返回true并恢复写入内存缓冲.
+func (w *NetWriter) idle() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.mem) == 0 && (w.spool == nil || w.spool.Len() == 0) {
+		w.spilling = false
+		return true
+	}
+	return false
+}
+
+func (w *NetWriter) send(p []byte) error {
+	_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	if _, err := w.conn.Write(p); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// requeue 将发送失败的日志放回内存缓冲头部.
+func (w *NetWriter) requeue(batch [][]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, p := range batch {
+		n += len(p)
+	}
+	w.mem = append(batch[:len(batch):len(batch)], w.mem...)
+	w.size += n
+}
+
+// jitter 返回[d/2, d)区间内的随机时长.
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
@@ -0,0 +1,152 @@
+package clognet
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+func collect(t *testing.T, ln net.Listener, n int) []string {
+	lines := make(chan string, n)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					s, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- s
+				}
+			}()
+		}
+	}()
+	var got []string
+	for len(got) < n {
+		select {
+		case s := <-lines:
+			got = append(got, s)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for lines, got %d", len(got))
+		}
+	}
+	return got
+}
+
+func TestNetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w := New("tcp", ln.Addr().String()).Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Info().Int("i", 0).Msg("a")
+	log.Info().Int("i", 1).Msg("b")
+	got := collect(t, ln, 2)
+	want := []string{`{"level":"info","i":0,"message":"a"}` + "\n", `{"level":"info","i":1,"message":"b"}` + "\n"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid lines:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestNetWriter_Spill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clognet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	w := New("tcp", addr).Buffer(64).Spill(filepath.Join(dir, "spool"), 0).Backoff(10*time.Millisecond, 50*time.Millisecond).Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	var want []string
+	for i := 0; i < 20; i++ {
+		log.Log().Int("i", i).Msg("")
+		want = append(want, fmt.Sprintf(`{"i":%d}`+"\n", i))
+	}
+	if w.spool.Len() == 0 {
+		t.Fatal("expected events spilled to disk")
+	}
+
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("can't listen on %s again: %v", addr, err)
+	}
+	defer ln.Close()
+	got := collect(t, ln, 20)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid replay order:\ngot:  %q\nwant: %q", got, want)
+	}
+	if w.Dropped() != 0 {
+		t.Errorf("dropped = %d, want 0", w.Dropped())
+	}
+}
+
+func TestNetWriter_CloseReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clognet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	spool := filepath.Join(dir, "spool")
+
+	w := New("tcp", addr).Buffer(64).Spill(spool, 0).Timeout(50*time.Millisecond).Backoff(time.Hour, time.Hour).Finish()
+	log := clog.NewOption().WithWriter(w).Logger()
+	var want []string
+	for i := 0; i < 20; i++ {
+		log.Log().Int("i", i).Msg("")
+		want = append(want, fmt.Sprintf(`{"i":%d}`+"\n", i))
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后内存缓冲中的日志先于溢出的日志回放
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("can't listen on %s again: %v", addr, err)
+	}
+	defer ln.Close()
+	w = New("tcp", addr).Spill(spool, 0).Finish()
+	defer w.Close()
+	got := collect(t, ln, 20)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid replay order:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestNetWriter_BufferFull(t *testing.T) {
+	w := New("tcp", "127.0.0.1:1").Buffer(8).Backoff(time.Hour, time.Hour).Finish()
+	defer w.Close()
+	if _, err := w.WriteLevel(clog.InfoLevel, []byte("0123456789\n")); err != ErrBufferFull {
+		t.Errorf("WriteLevel err = %v, want %v", err, ErrBufferFull)
+	}
+	if w.Dropped() != 1 {
+		t.Errorf("dropped = %d, want 1", w.Dropped())
+	}
+}
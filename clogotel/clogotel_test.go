@@ -0,0 +1,112 @@
+package clogotel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/cloghttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContext(t *testing.T) (context.Context, trace.SpanContext) {
+	tid, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, err := trace.SpanIDFromHex("0102030405060708")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: trace.FlagsSampled})
+	return trace.ContextWithSpanContext(context.Background(), sc), sc
+}
+
+func TestTraceHook(t *testing.T) {
+	ctx, _ := spanContext(t)
+	out := &bytes.Buffer{}
+	log := clog.NewOption().WithWriter(out).WithHook(TraceHook()).Logger()
+	log.Log().Ctx(ctx).Msg("")
+	want := `{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"0102030405060708","trace_flags":"01"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	out.Reset()
+	log.Log().Ctx(context.Background()).Msg("")
+	if got, want := out.String(), "{}\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestLogsExport(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []exportRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req exportRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	w := cloghttp.NewBatchWriter(collector.URL + "/v1/logs").
+		Format(Logs(map[string]string{"service.name": "api"})).
+		Gzip(false).
+		Finish()
+	ctx, _ := spanContext(t)
+	log := clog.NewOption().WithWriter(w).WithHook(TraceHook()).Logger()
+	log.Warn().Ctx(ctx).Str("user", "u1").Int("n", 3).Float64("f", 0.5).
+		Bool("ok", true).Strs("tags", []string{"a"}).Msg("slow request")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 1 || len(reqs[0].ResourceLogs) != 1 {
+		t.Fatalf("invalid export requests: %+v", reqs)
+	}
+	rl := reqs[0].ResourceLogs[0]
+	if len(rl.Resource.Attributes) != 1 || rl.Resource.Attributes[0].Key != "service.name" ||
+		*rl.Resource.Attributes[0].Value.StringValue != "api" {
+		t.Errorf("invalid resource: %+v", rl.Resource)
+	}
+	records := rl.ScopeLogs[0].LogRecords
+	if len(records) != 1 {
+		t.Fatalf("invalid record count: %d", len(records))
+	}
+	r := records[0]
+	if r.SeverityNumber != 13 || r.SeverityText != "warn" || len(r.TimeUnixNano) == 0 {
+		t.Errorf("invalid severity or time: %+v", r)
+	}
+	if r.Body.StringValue == nil || *r.Body.StringValue != "slow request" {
+		t.Errorf("invalid body: %+v", r.Body)
+	}
+	if r.TraceID != "0102030405060708090a0b0c0d0e0f10" || r.SpanID != "0102030405060708" || r.Flags != 1 {
+		t.Errorf("invalid trace context: %+v", r)
+	}
+	got, _ := json.Marshal(r.Attributes)
+	want := `[{"key":"user","value":{"stringValue":"u1"}},{"key":"n","value":{"intValue":"3"}},` +
+		`{"key":"f","value":{"doubleValue":0.5}},{"key":"ok","value":{"boolValue":true}},` +
+		`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}}]`
+	if string(got) != want {
+		t.Errorf("invalid attributes:\ngot:  %s\nwant: %s", got, want)
+	}
+}
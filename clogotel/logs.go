@@ -0,0 +1,239 @@
+package clogotel
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/cloghttp"
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+const scopeName = "github.com/cuckooemm/clog"
+
+// Severity 返回clog日志等级对应的OTLP SeverityNumber.
+func Severity(l clog.Level) int {
+	switch l {
+	case clog.TraceLevel:
+		return 1
+	case clog.DebugLevel:
+		return 5
+	case clog.InfoLevel:
+		return 9
+	case clog.WarnLevel:
+		return 13
+	case clog.ErrorLevel:
+		return 17
+	case clog.FatalLevel:
+		return 21
+	case clog.PanicLevel:
+		return 24
+	}
+	return 0
+}
+
+type logs struct {
+	resource []keyValue
+	msgKey   string
+	levelKey string
+	timeKey  string
+}
+
+// Logs 返回OTLP/HTTP JSON格式(ExportLogsServiceRequest)的批次格式, resource为资源属性(如service.name).
+// 配合cloghttp.NewBatchWriter导出至collector的 /v1/logs:
+//
+//	w := cloghttp.NewBatchWriter("http://collector:4318/v1/logs").
+//		Format(clogotel.Logs(map[string]string{"service.name": "api"})).
+//		Finish()
+//
+// 日志等级映射为severityNumber, message字段为body, trace_id,span_id,trace_flags字段为链路信息,
+// 其余字段(level与时间字段除外)转为attributes.
+func Logs(resource map[string]string) cloghttp.Format {
+	return LogsConfig(resource, clog.Config{})
+}
+
+// LogsConfig 同Logs, 按c中的字段名解析日志, 应与Logger的WithConfig一致. c中为空的字段名使用clog.Set设置的默认配置.
+func LogsConfig(resource map[string]string, c clog.Config) cloghttp.Format {
+	f := logs{msgKey: c.MessageFieldName, levelKey: c.LevelFieldName, timeKey: c.TimestampFieldName}
+	for k, v := range resource {
+		v := v
+		f.resource = append(f.resource, keyValue{Key: k, Value: anyValue{StringValue: &v}})
+	}
+	sort.Slice(f.resource, func(i, j int) bool { return f.resource[i].Key < f.resource[j].Key })
+	return f
+}
+
+func (logs) ContentType() string {
+	return "application/json"
+}
+
+func (f logs) Encode(dst []byte, batch []cloghttp.Entry) []byte {
+	records := make([]logRecord, 0, len(batch))
+	for _, e := range batch {
+		records = append(records, f.record(e))
+	}
+	req := exportRequest{ResourceLogs: []resourceLogs{{
+		Resource:  resource{Attributes: f.resource},
+		ScopeLogs: []scopeLogs{{Scope: scope{Name: scopeName}, LogRecords: records}},
+	}}}
+	b, err := json.Marshal(req)
+	if err != nil {
+		clog.HandleError(err)
+		return dst
+	}
+	return append(dst, b...)
+}
+
+func (f logs) record(e cloghttp.Entry) logRecord {
+	ts := strconv.FormatInt(e.Time.UnixNano(), 10)
+	r := logRecord{
+		TimeUnixNano:         ts,
+		ObservedTimeUnixNano: ts,
+		SeverityNumber:       Severity(e.Level),
+		SeverityText:         e.Level.String(),
+	}
+	if e.Level == clog.NoLevel {
+		r.SeverityText = ""
+	}
+	var (
+		msgKey   = f.msgKey
+		levelKey = f.levelKey
+		timeKey  = f.timeKey
+	)
+	if len(msgKey) == 0 {
+		msgKey = clog.MessageFieldName()
+	}
+	if len(levelKey) == 0 {
+		levelKey = clog.LevelFieldName()
+	}
+	if len(timeKey) == 0 {
+		timeKey = clog.TimestampFieldName()
+	}
+	err := jsonfield.Range(e.Line, func(key string, val json.RawMessage) bool {
+		switch key {
+		case msgKey:
+			r.Body = toAnyValue(val)
+		case levelKey, timeKey:
+		case TraceIDFieldName:
+			r.TraceID = jsonfield.String(val)
+		case SpanIDFieldName:
+			r.SpanID = jsonfield.String(val)
+		case TraceFlagsFieldName:
+			flags, _ := strconv.ParseUint(jsonfield.String(val), 16, 8)
+			r.Flags = uint32(flags)
+		default:
+			r.Attributes = append(r.Attributes, keyValue{Key: key, Value: toAnyValue(val)})
+		}
+		return true
+	})
+	if err != nil {
+		line := string(e.Line)
+		r.Body = anyValue{StringValue: &line}
+	}
+	return r
+}
+
+// toAnyValue 将JSON值转为OTLP AnyValue.
+func toAnyValue(raw json.RawMessage) anyValue {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		s := string(raw)
+		return anyValue{StringValue: &s}
+	}
+	return convert(v)
+}
+
+func convert(v interface{}) anyValue {
+	switch v := v.(type) {
+	case string:
+		return anyValue{StringValue: &v}
+	case bool:
+		return anyValue{BoolValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return anyValue{IntValue: v.String()}
+		}
+		f, _ := v.Float64()
+		return anyValue{DoubleValue: &f}
+	case []interface{}:
+		arr := &arrayValue{Values: make([]anyValue, 0, len(v))}
+		for _, item := range v {
+			arr.Values = append(arr.Values, convert(item))
+		}
+		return anyValue{ArrayValue: arr}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		kv := &kvList{Values: make([]keyValue, 0, len(v))}
+		for _, k := range keys {
+			kv.Values = append(kv.Values, keyValue{Key: k, Value: convert(v[k])})
+		}
+		return anyValue{KvlistValue: kv}
+	}
+	// null
+	return anyValue{}
+}
+
+// OTLP JSON 编码结构, 参见 opentelemetry-proto logs/v1 与 common/v1.
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	Flags                uint32     `json:"flags,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    string      `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *kvList     `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type kvList struct {
+	Values []keyValue `json:"values"`
+}
@@ -0,0 +1,36 @@
+// Package clogotel 提供OpenTelemetry集成: 从context注入链路信息的Hook,
+// 以及以OTLP/HTTP JSON格式导出日志的 cloghttp.Format.
+package clogotel
+
+import (
+	"github.com/cuckooemm/clog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 注入的链路字段名, Logs格式会将其转为日志记录的traceId,spanId与flags.
+const (
+	TraceIDFieldName    = "trace_id"
+	SpanIDFieldName     = "span_id"
+	TraceFlagsFieldName = "trace_flags"
+)
+
+// TraceHook 返回从事件context中读取span并注入trace_id,span_id,trace_flags字段的Hook.
+// 事件需通过Event.Ctx(ctx)关联context, 无有效span时不写入字段.
+//
+//	log := clog.NewOption().WithHook(clogotel.TraceHook()).Logger()
+//	log.Info().Ctx(ctx).Msg("handled")
+func TraceHook() clog.Hook {
+	return traceHook{}
+}
+
+type traceHook struct{}
+
+func (traceHook) Run(e *clog.Event, _ clog.Level, _ string) {
+	sc := trace.SpanContextFromContext(e.GetCtx())
+	if !sc.IsValid() {
+		return
+	}
+	e.Str(TraceIDFieldName, sc.TraceID().String()).
+		Str(SpanIDFieldName, sc.SpanID().String()).
+		Str(TraceFlagsFieldName, sc.TraceFlags().String())
+}
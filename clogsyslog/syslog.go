@@ -0,0 +1,463 @@
+// Package clogsyslog 提供直接写入rsyslog/syslog-ng/journald等syslog服务的输出源,
+// 支持RFC 5424与RFC 3164格式,unix socket,UDP,TCP及TLS传输.
+package clogsyslog
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cuckooemm/clog"
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+// Format syslog消息格式.
+type Format int8
+
+const (
+	// RFC5424 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	RFC5424 Format = iota
+	// RFC3164 <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+	RFC3164
+)
+
+// Facility syslog facility.
+type Facility int
+
+const (
+	Kern Facility = iota
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	Lpr
+	News
+	Uucp
+	Cron
+	AuthPriv
+	Ftp
+	_
+	_
+	_
+	_
+	Local0
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+// Severity syslog severity.
+type Severity int
+
+const (
+	Emerg Severity = iota
+	Alert
+	Crit
+	Err
+	Warning
+	Notice
+	Info
+	Debug
+)
+
+// SeverityOf 返回clog日志等级对应的syslog severity.
+func SeverityOf(l clog.Level) Severity {
+	switch l {
+	case clog.TraceLevel, clog.DebugLevel:
+		return Debug
+	case clog.InfoLevel:
+		return Info
+	case clog.WarnLevel:
+		return Warning
+	case clog.ErrorLevel:
+		return Err
+	case clog.FatalLevel:
+		return Crit
+	case clog.PanicLevel:
+		return Alert
+	}
+	return Notice
+}
+
+// structuredDataID RFC 5424 SD-ID, 32473为RFC 5612保留用于文档示例的企业编号.
+const structuredDataID = "clog@32473"
+
+var (
+	// ErrBackoff 连接断开后的重连等待期间写入时返回.
+	ErrBackoff = errors.New("clogsyslog: connection unavailable, waiting to reconnect")
+
+	now = time.Now
+)
+
+// Writer syslog输出源,实现 clog.LevelWriter 接口. 通过New()构建.
+type Writer struct {
+	network   string
+	addr      string
+	format    Format
+	facility  Facility
+	appName   string
+	hostname  string
+	pid       string
+	tlsConfig *tls.Config
+	framing   bool // octet-counting framing (RFC 6587)
+	stream    bool // 流式连接, 本机syslog socket在连接后确定
+	sd        bool // RFC 5424 structured data
+	msgKey    string
+	timeout   time.Duration
+	minWait   time.Duration
+	maxWait   time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	wait    time.Duration
+	retryAt time.Time
+	msg     []byte
+	buf     []byte
+}
+
+type option struct {
+	w *Writer
+}
+
+// New 构建syslog输出源, network可选 "unix" "unixgram" "udp" "tcp", network与addr为空时连接本机syslog socket.
+//
+//	w := clogsyslog.New("udp", "127.0.0.1:514").Facility(clogsyslog.Local0).AppName("api").Finish()
+//	clog.NewOption().WithWriter(w).Logger()
+func New(network, addr string) *option {
+	host, _ := os.Hostname()
+	return &option{w: &Writer{
+		network:  network,
+		addr:     addr,
+		format:   RFC5424,
+		facility: User,
+		appName:  filepath.Base(os.Args[0]),
+		hostname: host,
+		pid:      strconv.Itoa(os.Getpid()),
+		framing:  network != "udp" && network != "unixgram" && network != "",
+		stream:   network != "udp" && network != "unixgram" && network != "",
+		sd:       true,
+		timeout:  5 * time.Second,
+		minWait:  100 * time.Millisecond,
+		maxWait:  30 * time.Second,
+	}}
+}
+
+// Format 设置消息格式,默认RFC5424
+func (o *option) Format(f Format) *option {
+	o.w.format = f
+	return o
+}
+
+// Facility 设置facility,默认User
+func (o *option) Facility(f Facility) *option {
+	o.w.facility = f
+	return o
+}
+
+// AppName 设置APP-NAME(RFC 3164中为TAG),默认为程序名
+func (o *option) AppName(name string) *option {
+	if len(name) > 0 {
+		o.w.appName = name
+	}
+	return o
+}
+
+// Hostname 设置HOSTNAME,默认为os.Hostname()
+func (o *option) Hostname(name string) *option {
+	if len(name) > 0 {
+		o.w.hostname = name
+	}
+	return o
+}
+
+// TLS 使用TLS连接,仅network为tcp时有效
+func (o *option) TLS(cfg *tls.Config) *option {
+	o.w.tlsConfig = cfg
+	return o
+}
+
+// OctetCounting 设置流式连接是否使用octet-counting分帧(RFC 6587),否则以换行分隔. tcp与unix默认开启,
+// 连接本机syslog socket时默认关闭.
+func (o *option) OctetCounting(enable bool) *option {
+	o.w.framing = enable
+	return o
+}
+
+// StructuredData 设置RFC 5424是否将日志字段转为structured data,此时MSG为message字段.
+// 关闭后MSG为完整的JSON日志. 默认开启.
+func (o *option) StructuredData(enable bool) *option {
+	o.w.sd = enable
+	return o
+}
+
+// Config 设置解析日志使用的字段名, 应与Logger的WithConfig一致, 默认使用clog.Set设置的默认配置
+func (o *option) Config(c clog.Config) *option {
+	o.w.msgKey = c.MessageFieldName
+	return o
+}
+
+// Timeout 设置连接与写入超时,默认5s
+func (o *option) Timeout(d time.Duration) *option {
+	if d > 0 {
+		o.w.timeout = d
+	}
+	return o
+}
+
+// Backoff 设置重连等待时间区间,每次失败等待时间翻倍,默认100ms~30s
+func (o *option) Backoff(min, max time.Duration) *option {
+	if min > 0 && max >= min {
+		o.w.minWait, o.w.maxWait = min, max
+	}
+	return o
+}
+
+// Finish 返回Writer实例,连接在首次写入时建立.
+func (o *option) Finish() *Writer {
+	return o.w
+}
+
+// Write 实现 io.Writer 接口,以NoLevel等级写入.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(clog.NoLevel, p)
+}
+
+// WriteLevel 实现 clog.LevelWriter 接口,根据日志等级设置消息severity.
+func (w *Writer) WriteLevel(l clog.Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	w.msg = w.appendMessage(w.msg[:0], l, trimNewline(p))
+	w.buf = w.frame(w.buf[:0], w.msg)
+	if w.timeout > 0 {
+		_ = w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	if _, err := w.conn.Write(w.buf); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		w.backoff()
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭连接.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *Writer) connect() (err error) {
+	if !w.retryAt.IsZero() && now().Before(w.retryAt) {
+		return ErrBackoff
+	}
+	switch {
+	case w.network == "" && w.addr == "":
+		var network string
+		if w.conn, network, err = dialLocal(w.timeout); err == nil {
+			// 回退至unix stream socket时消息需要分帧
+			w.stream = network == "unix"
+		}
+	case w.tlsConfig != nil:
+		w.conn, err = tls.DialWithDialer(&net.Dialer{Timeout: w.timeout}, w.network, w.addr, w.tlsConfig)
+	default:
+		w.conn, err = net.DialTimeout(w.network, w.addr, w.timeout)
+	}
+	if err != nil {
+		w.conn = nil
+		w.backoff()
+		return err
+	}
+	w.wait = 0
+	w.retryAt = time.Time{}
+	return nil
+}
+
+func (w *Writer) backoff() {
+	if w.wait == 0 {
+		w.wait = w.minWait
+	} else if w.wait *= 2; w.wait > w.maxWait {
+		w.wait = w.maxWait
+	}
+	w.retryAt = now().Add(w.wait)
+}
+
+// localPaths 本机syslog socket路径.
+var localPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// dialLocal 连接本机syslog socket, 优先使用unixgram, 返回实际使用的network.
+func dialLocal(timeout time.Duration) (net.Conn, string, error) {
+	var err error
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range localPaths {
+			var conn net.Conn
+			if conn, err = net.DialTimeout(network, path, timeout); err == nil {
+				return conn, network, nil
+			}
+		}
+	}
+	return nil, "", err
+}
+
+// appendMessage 生成不含分帧的syslog消息.
+func (w *Writer) appendMessage(dst []byte, l clog.Level, p []byte) []byte {
+	pri := int(w.facility)*8 + int(SeverityOf(l))
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(pri), 10)
+	dst = append(dst, '>')
+	if w.format == RFC3164 {
+		dst = now().AppendFormat(dst, time.Stamp)
+		dst = append(dst, ' ')
+		dst = append(dst, w.hostname...)
+		dst = append(dst, ' ')
+		dst = append(dst, w.appName...)
+		dst = append(dst, '[')
+		dst = append(dst, w.pid...)
+		dst = append(dst, "]: "...)
+		return append(dst, p...)
+	}
+	dst = append(dst, "1 "...)
+	dst = now().AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, w.hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendHeaderField(dst, w.appName, 48)
+	dst = append(dst, ' ')
+	dst = append(dst, w.pid...)
+	dst = append(dst, " - "...)
+	if !w.sd {
+		dst = append(dst, '-', ' ')
+		return append(dst, p...)
+	}
+	msgKey := w.msgKey
+	if len(msgKey) == 0 {
+		msgKey = clog.MessageFieldName()
+	}
+	return appendStructuredData(dst, p, msgKey)
+}
+
+// frame 为流式连接添加分帧.
+func (w *Writer) frame(dst, msg []byte) []byte {
+	if !w.stream {
+		return append(dst, msg...)
+	}
+	if w.framing {
+		dst = strconv.AppendInt(dst, int64(len(msg)), 10)
+		dst = append(dst, ' ')
+		return append(dst, msg...)
+	}
+	return append(append(dst, msg...), '\n')
+}
+
+// appendStructuredData 将日志顶层字段写入SD-ELEMENT,msgKey字段作为MSG.
+func appendStructuredData(dst, p []byte, msgKey string) []byte {
+	var (
+		msg      string
+		hasParam bool
+		// SD-ELEMENT写入dst[start:], 解析成功后才保留
+		start = len(dst)
+	)
+	err := jsonfield.Range(p, func(key string, val json.RawMessage) bool {
+		if key == msgKey {
+			msg = jsonfield.String(val)
+			return true
+		}
+		if !hasParam {
+			dst = append(dst, '[')
+			dst = append(dst, structuredDataID...)
+			hasParam = true
+		}
+		dst = append(dst, ' ')
+		dst = appendParamName(dst, key)
+		dst = append(dst, '=', '"')
+		dst = appendParamValue(dst, jsonfield.String(val))
+		dst = append(dst, '"')
+		return true
+	})
+	if err != nil {
+		// 非JSON数据丢弃已写入的参数, 原样作为MSG输出
+		return append(append(dst[:start], '-', ' '), p...)
+	}
+	if hasParam {
+		dst = append(dst, ']')
+	} else {
+		dst = append(dst, '-')
+	}
+	if len(msg) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, msg...)
+	}
+	return dst
+}
+
+// appendHeaderField 写入HEADER字段,仅保留可打印ASCII字符,为空时写入NILVALUE.
+func appendHeaderField(dst []byte, s string, max int) []byte {
+	n := 0
+	for i := 0; i < len(s) && n < max; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			dst = append(dst, s[i])
+			n++
+		}
+	}
+	if n == 0 {
+		dst = append(dst, '-')
+	}
+	return dst
+}
+
+// appendParamName 写入PARAM-NAME,剔除 '=' ' ' ']' '"' 及非打印字符,最长32字符.
+func appendParamName(dst []byte, s string) []byte {
+	n := 0
+	for i := 0; i < len(s) && n < 32; i++ {
+		c := s[i]
+		if c <= 32 || c >= 127 || c == '=' || c == ']' || c == '"' {
+			continue
+		}
+		dst = append(dst, c)
+		n++
+	}
+	if n == 0 {
+		dst = append(dst, '_')
+	}
+	return dst
+}
+
+// appendParamValue 写入PARAM-VALUE,转义 '"' '\' ']'.
+func appendParamValue(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\', ']':
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, s[i])
+	}
+	return dst
+}
+
+func trimNewline(p []byte) []byte {
+	if n := len(p); n > 0 && p[n-1] == '\n' {
+		return p[:n-1]
+	}
+	return p
+}
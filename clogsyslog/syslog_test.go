@@ -0,0 +1,180 @@
+package clogsyslog
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cuckooemm/clog"
+)
+
+func fixedNow() func() {
+	orig := now
+	now = func() time.Time { return time.Date(2021, 9, 28, 12, 23, 22, 0, time.UTC) }
+	return func() { now = orig }
+}
+
+func TestWriter_UDP(t *testing.T) {
+	defer fixedNow()()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := New("udp", conn.LocalAddr().String()).Facility(Local0).AppName("api").Hostname("host").Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Warn().Str("foo", `b"a]r`).Int("n", 1).Msg("hello")
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<132>1 2021-09-28T12:23:22.000000Z host api ` + w.pid + ` - [clog@32473 level="warn" foo="b\"a\]r" n="1"] hello`
+	if got := string(buf[:n]); got != want {
+		t.Errorf("invalid syslog message:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestWriter_Config(t *testing.T) {
+	defer fixedNow()()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cfg := clog.Config{MessageFieldName: "msg"}
+	w := New("udp", conn.LocalAddr().String()).AppName("api").Hostname("host").Config(cfg).Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).WithConfig(cfg).Logger()
+	log.Info().Msg("hello")
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<14>1 2021-09-28T12:23:22.000000Z host api ` + w.pid + ` - [clog@32473 level="info"] hello`
+	if got := string(buf[:n]); got != want {
+		t.Errorf("invalid syslog message:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestWriter_TCP(t *testing.T) {
+	defer fixedNow()()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for i := 0; i < 2; i++ {
+			s, _ := r.ReadString('\n')
+			lines <- s
+		}
+	}()
+
+	w := New("tcp", ln.Addr().String()).Format(RFC3164).OctetCounting(false).AppName("api").Hostname("host").Finish()
+	defer w.Close()
+	log := clog.NewOption().WithWriter(w).Logger()
+	log.Error().Msg("one")
+	log.Log().Msg("two")
+
+	for _, want := range []string{
+		`<11>Sep 28 12:23:22 host api[` + w.pid + `]: {"level":"error","message":"one"}` + "\n",
+		`<13>Sep 28 12:23:22 host api[` + w.pid + `]: {"message":"two"}` + "\n",
+	} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("invalid syslog message:\ngot:  %v\nwant: %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func TestWriter_OctetCounting(t *testing.T) {
+	defer fixedNow()()
+	w := New("tcp", "").StructuredData(false).AppName("api").Hostname("host").Finish()
+	msg := w.appendMessage(nil, clog.InfoLevel, []byte(`{"a":1}`))
+	got := string(w.frame(nil, msg))
+	want := `<14>1 2021-09-28T12:23:22.000000Z host api ` + w.pid + ` - - {"a":1}`
+	if want = strconv.Itoa(len(want)) + " " + want; got != want {
+		t.Errorf("invalid framed message:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestWriter_LocalStream(t *testing.T) {
+	defer fixedNow()()
+	path := filepath.Join(t.TempDir(), "log")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	orig := localPaths
+	localPaths = []string{path}
+	defer func() { localPaths = orig }()
+
+	w := New("", "").StructuredData(false).AppName("api").Hostname("host").Finish()
+	defer w.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := w.WriteLevel(clog.InfoLevel, []byte(`{"a":1}`+"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	want := `<14>1 2021-09-28T12:23:22.000000Z host api ` + w.pid + ` - - {"a":1}` + "\n"
+	for i := 0; i < 2; i++ {
+		if got, err := r.ReadString('\n'); err != nil || got != want {
+			t.Errorf("invalid message %d:\ngot:  %q (%v)\nwant: %q", i, got, err, want)
+		}
+	}
+}
+
+func TestAppendStructuredData_Invalid(t *testing.T) {
+	got := string(appendStructuredData([]byte("x "), []byte(`{"a":1,"b":}`), "message"))
+	if want := `x - {"a":1,"b":}`; got != want {
+		t.Errorf("invalid message:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestWriter_Backoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	w := New("tcp", addr).Backoff(time.Hour, time.Hour).Finish()
+	if _, err := w.WriteLevel(clog.InfoLevel, []byte("{}\n")); err == nil || err == ErrBackoff {
+		t.Fatalf("first write err = %v, want dial error", err)
+	}
+	if _, err := w.WriteLevel(clog.InfoLevel, []byte("{}\n")); err != ErrBackoff {
+		t.Errorf("second write err = %v, want %v", err, ErrBackoff)
+	}
+}
@@ -0,0 +1,238 @@
+// Package clogtest 提供测试日志输出的工具: 将事件解码为结构化条目的Recorder,
+// 条目过滤与断言方法, 以及将日志输出至 t.Log 的TestingWriter.
+package clogtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cuckooemm/clog"
+)
+
+// Entry 解码后的日志事件.
+type Entry struct {
+	Level   clog.Level
+	Message string
+	// Fields 除level与message外的字段, 值按encoding/json解码, 数字为float64
+	Fields map[string]interface{}
+	// Raw 原始日志行, 不含换行符
+	Raw string
+}
+
+// Entries 日志条目列表, 过滤方法返回新的列表.
+type Entries []Entry
+
+// FilterLevel 返回等级为level的条目.
+func (es Entries) FilterLevel(level clog.Level) Entries {
+	return es.filter(func(e Entry) bool { return e.Level == level })
+}
+
+// FilterField 返回包含字段key且值等于value的条目, value按JSON编码后比较, 因此 1 与 1.0 相等.
+func (es Entries) FilterField(key string, value interface{}) Entries {
+	want := normalize(value)
+	return es.filter(func(e Entry) bool {
+		v, ok := e.Fields[key]
+		return ok && reflect.DeepEqual(v, want)
+	})
+}
+
+// FilterMessageContains 返回消息包含s的条目.
+func (es Entries) FilterMessageContains(s string) Entries {
+	return es.filter(func(e Entry) bool { return strings.Contains(e.Message, s) })
+}
+
+// Messages 返回所有条目的消息.
+func (es Entries) Messages() []string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func (es Entries) filter(f func(Entry) bool) Entries {
+	var out Entries
+	for _, e := range es {
+		if f(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Recorder 将写入的每行日志解码为Entry, 并发安全.
+type Recorder struct {
+	t       testing.TB
+	mu      sync.Mutex
+	entries Entries
+	partial []byte
+}
+
+// NewObserved 返回输出至Recorder的Logger, 等级为TraceLevel. 无法解码的日志行会使测试失败.
+//
+//	log, rec := clogtest.NewObserved(t)
+//	svc := NewService(log)
+//	svc.Run()
+//	rec.AssertLogged(t, clog.InfoLevel, "started", map[string]interface{}{"port": 8080})
+func NewObserved(t testing.TB) (*clog.Logger, *Recorder) {
+	rec := &Recorder{t: t}
+	log := clog.NewOption().WithWriter(rec).WithLogLevel(clog.TraceLevel).Logger()
+	return &log, rec
+}
+
+// Write 实现 io.Writer 接口, 按行解码日志.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(r.partial[:i])
+		r.partial = r.partial[i+1:]
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		e, err := decode(line)
+		if err != nil {
+			r.t.Errorf("clogtest: invalid log line %q: %v", line, err)
+			continue
+		}
+		r.entries = append(r.entries, e)
+	}
+	return len(p), nil
+}
+
+func decode(line string) (Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Entry{}, err
+	}
+	e := Entry{Level: clog.NoLevel, Fields: fields, Raw: line}
+	if v, ok := fields[clog.LevelFieldName()].(string); ok {
+		if lvl, err := clog.ParseLevel(v); err == nil {
+			e.Level = lvl
+			delete(fields, clog.LevelFieldName())
+		}
+	}
+	if v, ok := fields[clog.MessageFieldName()].(string); ok {
+		e.Message = v
+		delete(fields, clog.MessageFieldName())
+	}
+	return e, nil
+}
+
+// normalize 将v按JSON编码后解码, 与Entry.Fields中的值类型一致.
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err = json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// All 返回已记录的所有条目.
+func (r *Recorder) All() Entries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(Entries(nil), r.entries...)
+}
+
+// Len 返回已记录的条目数.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Reset 清空已记录的条目.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+	r.partial = nil
+}
+
+// FilterLevel 同 Entries.FilterLevel.
+func (r *Recorder) FilterLevel(level clog.Level) Entries {
+	return r.All().FilterLevel(level)
+}
+
+// FilterField 同 Entries.FilterField.
+func (r *Recorder) FilterField(key string, value interface{}) Entries {
+	return r.All().FilterField(key, value)
+}
+
+// FilterMessageContains 同 Entries.FilterMessageContains.
+func (r *Recorder) FilterMessageContains(s string) Entries {
+	return r.All().FilterMessageContains(s)
+}
+
+// AssertLogged 断言存在等级为level, 消息为msg且包含fields中所有字段的条目, fields可为nil.
+func (r *Recorder) AssertLogged(t testing.TB, level clog.Level, msg string, fields map[string]interface{}) {
+	t.Helper()
+	if r.find(level, msg, fields) {
+		return
+	}
+	t.Errorf("clogtest: no %s entry with message %q and fields %v, got:\n%s", level, msg, fields, r.dump())
+}
+
+// AssertNotLogged 断言不存在等级为level且消息为msg的条目.
+func (r *Recorder) AssertNotLogged(t testing.TB, level clog.Level, msg string) {
+	t.Helper()
+	if r.find(level, msg, nil) {
+		t.Errorf("clogtest: unexpected %s entry with message %q, got:\n%s", level, msg, r.dump())
+	}
+}
+
+func (r *Recorder) find(level clog.Level, msg string, fields map[string]interface{}) bool {
+	es := r.All().FilterLevel(level).filter(func(e Entry) bool { return e.Message == msg })
+	for k, v := range fields {
+		es = es.FilterField(k, v)
+	}
+	return len(es) > 0
+}
+
+func (r *Recorder) dump() string {
+	var b strings.Builder
+	for _, e := range r.All() {
+		b.WriteString("\t")
+		b.WriteString(e.Raw)
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return "\t(no entries)\n"
+	}
+	return b.String()
+}
+
+type testingWriter struct {
+	t testing.TB
+}
+
+// TestingWriter 返回将每行日志通过 t.Log 输出的io.Writer, 日志仅在测试失败或 go test -v 时显示.
+// 测试结束后不应再写入日志.
+//
+//	log := clog.NewOption().WithWriter(clogtest.TestingWriter(t)).Logger()
+func TestingWriter(t testing.TB) io.Writer {
+	return testingWriter{t: t}
+}
+
+func (w testingWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.t.Log(line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,97 @@
+package clogtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cuckooemm/clog"
+)
+
+// fakeT 记录Errorf与Log调用.
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestObserved(t *testing.T) {
+	log, rec := NewObserved(t)
+	log.Info().Int("port", 8080).Str("env", "dev").Msg("server started")
+	log.Warn().Err(errors.New("timeout")).Msg("retrying request")
+	log.Debug().Int("port", 9090).Msg("debug server started")
+
+	if got := rec.Len(); got != 3 {
+		t.Fatalf("invalid entry count:\ngot:  %v\nwant: %v", got, 3)
+	}
+	want := Entry{
+		Level:   clog.InfoLevel,
+		Message: "server started",
+		Fields:  map[string]interface{}{"port": float64(8080), "env": "dev"},
+		Raw:     `{"level":"info","port":8080,"env":"dev","message":"server started"}`,
+	}
+	if got := rec.All()[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid entry:\ngot:  %+v\nwant: %+v", got, want)
+	}
+	if got := rec.FilterLevel(clog.WarnLevel).Messages(); !reflect.DeepEqual(got, []string{"retrying request"}) {
+		t.Errorf("invalid FilterLevel result: %v", got)
+	}
+	if got := rec.FilterField("port", 9090).Messages(); !reflect.DeepEqual(got, []string{"debug server started"}) {
+		t.Errorf("invalid FilterField result: %v", got)
+	}
+	if got := rec.FilterMessageContains("started").FilterField("env", "dev").Messages(); !reflect.DeepEqual(got, []string{"server started"}) {
+		t.Errorf("invalid chained filter result: %v", got)
+	}
+
+	rec.AssertLogged(t, clog.InfoLevel, "server started", map[string]interface{}{"port": 8080})
+	rec.AssertLogged(t, clog.WarnLevel, "retrying request", map[string]interface{}{"error": "timeout"})
+	rec.AssertNotLogged(t, clog.ErrorLevel, "server started")
+
+	ft := &fakeT{}
+	rec.AssertLogged(ft, clog.InfoLevel, "server started", map[string]interface{}{"port": 1})
+	rec.AssertNotLogged(ft, clog.InfoLevel, "server started")
+	if len(ft.errors) != 2 || !strings.Contains(ft.errors[0], `"port":8080`) {
+		t.Errorf("assertions should fail with entry dump, got %q", ft.errors)
+	}
+
+	rec.Reset()
+	if rec.Len() != 0 {
+		t.Errorf("Reset should clear entries")
+	}
+}
+
+func TestObservedInvalidLine(t *testing.T) {
+	ft := &fakeT{}
+	_, rec := NewObserved(ft)
+	_, _ = rec.Write([]byte("not json\n{\"message\":"))
+	_, _ = rec.Write([]byte("\"split\"}\n"))
+	if len(ft.errors) != 1 {
+		t.Errorf("invalid line should fail the test, got %q", ft.errors)
+	}
+	if got := rec.All(); len(got) != 1 || got[0].Message != "split" || got[0].Level != clog.NoLevel {
+		t.Errorf("invalid entries: %+v", got)
+	}
+}
+
+func TestTestingWriter(t *testing.T) {
+	ft := &fakeT{}
+	log := clog.NewOption().WithWriter(TestingWriter(ft)).Logger()
+	log.Info().Msg("a")
+	log.Info().Msg("b")
+	want := []string{`{"level":"info","message":"a"}`, `{"level":"info","message":"b"}`}
+	if !reflect.DeepEqual(ft.logs, want) {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", ft.logs, want)
+	}
+}
@@ -0,0 +1,153 @@
+package clog
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config 日志格式配置. 通过 NewOption().WithConfig(c) 为Logger单独设置, Logger创建后不可修改;
+// 未设置时使用创建Logger时 clog.Set 设置的默认配置, 之后对 clog.Set 的修改不影响已创建的Logger.
+//
+//	c := clog.DefaultConfig()
+//	c.MessageFieldName = "msg"
+//	log := clog.NewOption().WithConfig(c).Logger()
+//
+// clogsyslog clogjournald clogfluent clogotel 按字段名解析日志, 默认使用 clog.Set 设置的字段名,
+// 修改字段名时需通过输出源的Config选项(clogotel为LogsConfig)传入相同的配置.
+type Config struct {
+	// TimeFormat time字段日期格式, 默认time.RFC3339, TimeFormatUnix*输出时间戳
+	TimeFormat string
+
+	LevelFieldName      string // 默认 level
+	TimestampFieldName  string // 默认 time
+	ErrorFieldName      string // 默认 error
+	ErrorStackFieldName string // 默认 stack
+	MessageFieldName    string // 默认 message
+	CallerFieldName     string // 默认 caller
+
+	// LevelMarshalFunc level字段的值, 默认Level.String
+	LevelMarshalFunc func(l Level) string
+	// ErrorMarshalFunc Err等方法序列化error的方法, 默认原样返回
+	ErrorMarshalFunc func(err error) interface{}
+	// ErrorStackMarshalFunc 调用Stack()后从error中提取堆栈的方法, 为nil时不输出堆栈
+	ErrorStackMarshalFunc func(err error) interface{}
+	// InterfaceMarshalFunc Interface等方法对未命中快速路径的类型使用的序列化方法, 默认json.Marshal.
+	// 快速路径的输出与json.Marshal一致, 替换的方法应与其兼容
+	InterfaceMarshalFunc func(v interface{}) ([]byte, error)
+	// InterfaceLogFormat 为true时Interface等方法按TimeFormat DurationUnit编码time.Time time.Duration,
+	// 实现error或fmt.Stringer的值输出其字符串, 输出与InterfaceMarshalFunc不同
+	InterfaceLogFormat bool
+	// TimestampFunc Timestamp()生成时间的方法, 默认time.Now
+	TimestampFunc func() time.Time
+	// CallerMarshalFunc caller字段的值, 默认 file:line
+	CallerMarshalFunc func(file string, line int) string
+	// CallerSkipFrameCount Caller()跳过的调用栈层数, 默认2
+	CallerSkipFrameCount int
+	// DurationUnit time.Duration字段的单位, 输出 d/DurationUnit, 默认time.Millisecond
+	DurationUnit time.Duration
+	// DurationInteger 为true时time.Duration字段以整数输出
+	DurationInteger bool
+	// ErrorHandler 写入输出源失败时调用, 为nil时输出至stderr. 必须为非阻塞且线程安全
+	ErrorHandler func(err error)
+}
+
+var (
+	defaultConfig atomic.Value // *Config
+	// configMu 串行化 clog.Set 对默认配置的修改
+	configMu sync.Mutex
+)
+
+func init() {
+	defaultConfig.Store(&Config{
+		TimeFormat:          time.RFC3339,
+		LevelFieldName:      "level",
+		TimestampFieldName:  "time",
+		ErrorFieldName:      "error",
+		ErrorStackFieldName: "stack",
+		MessageFieldName:    "message",
+		CallerFieldName:     "caller",
+		LevelMarshalFunc: func(l Level) string {
+			return l.String()
+		},
+		ErrorMarshalFunc: func(err error) interface{} {
+			return err
+		},
+		InterfaceMarshalFunc: json.Marshal,
+		TimestampFunc:        time.Now,
+		CallerMarshalFunc: func(file string, line int) string {
+			return file + ":" + strconv.Itoa(line)
+		},
+		CallerSkipFrameCount: 2,
+		DurationUnit:         time.Millisecond,
+	})
+}
+
+// loadConfig 返回当前默认配置, 返回值不可修改.
+func loadConfig() *Config {
+	return defaultConfig.Load().(*Config)
+}
+
+// updateConfig 复制默认配置并由f修改后原子替换, 正在输出的日志不受影响.
+func updateConfig(f func(c *Config)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	c := *loadConfig()
+	f(&c)
+	defaultConfig.Store(&c)
+}
+
+// DefaultConfig 返回当前默认配置的副本, 即 clog.Set 设置的配置.
+func DefaultConfig() Config {
+	return *loadConfig()
+}
+
+// normalize 以默认配置填充c中为空的时间格式 字段名与方法, DurationInteger InterfaceLogFormat保持原值.
+func (c Config) normalize() *Config {
+	def := loadConfig()
+	str := func(s *string, d string) {
+		if len(*s) == 0 {
+			*s = d
+		}
+	}
+	str(&c.TimeFormat, def.TimeFormat)
+	str(&c.LevelFieldName, def.LevelFieldName)
+	str(&c.TimestampFieldName, def.TimestampFieldName)
+	str(&c.ErrorFieldName, def.ErrorFieldName)
+	str(&c.ErrorStackFieldName, def.ErrorStackFieldName)
+	str(&c.MessageFieldName, def.MessageFieldName)
+	str(&c.CallerFieldName, def.CallerFieldName)
+	if c.LevelMarshalFunc == nil {
+		c.LevelMarshalFunc = def.LevelMarshalFunc
+	}
+	if c.ErrorMarshalFunc == nil {
+		c.ErrorMarshalFunc = def.ErrorMarshalFunc
+	}
+	if c.InterfaceMarshalFunc == nil {
+		c.InterfaceMarshalFunc = def.InterfaceMarshalFunc
+	}
+	if c.TimestampFunc == nil {
+		c.TimestampFunc = def.TimestampFunc
+	}
+	if c.CallerMarshalFunc == nil {
+		c.CallerMarshalFunc = def.CallerMarshalFunc
+	}
+	if c.CallerSkipFrameCount <= 0 {
+		c.CallerSkipFrameCount = def.CallerSkipFrameCount
+	}
+	if c.DurationUnit <= 0 {
+		c.DurationUnit = def.DurationUnit
+	}
+	return &c
+}
+
+// handleError 将写入错误交由ErrorHandler处理, 未设置时输出至stderr.
+func (c *Config) handleError(err error) {
+	if c.ErrorHandler != nil {
+		c.ErrorHandler(err)
+		return
+	}
+	printError(err)
+}
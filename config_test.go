@@ -0,0 +1,86 @@
+package clog
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithConfig(t *testing.T) {
+	c := DefaultConfig()
+	c.MessageFieldName = "msg"
+	c.LevelFieldName = "lvl"
+	c.ErrorFieldName = "err"
+	c.DurationUnit = time.Second
+	c.DurationInteger = true
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithConfig(c).Logger()
+	log.Info().Err(errors.New("e")).TimeDur("d", 3*time.Second).Msg("hi")
+	if got, want := out.String(), `{"lvl":"info","err":"e","d":3,"msg":"hi"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	// 其他Logger不受影响
+	out.Reset()
+	other := NewOption().WithWriter(out).Logger()
+	other.Info().Msg("hi")
+	if got, want := out.String(), `{"level":"info","message":"hi"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	// 未设置的字段使用默认配置
+	log = NewOption().WithConfig(Config{MessageFieldName: "m"}).Logger()
+	if got := log.Config(); got.LevelFieldName != "level" || got.MessageFieldName != "m" || got.LevelMarshalFunc == nil ||
+		got.TimeFormat != DefaultConfig().TimeFormat {
+		t.Errorf("invalid normalized config: %+v", got)
+	}
+
+	out.Reset()
+	tm := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	log = NewOption().WithWriter(out).WithConfig(Config{TimeFormat: TimeFormatUnixSec}).Logger()
+	log.Log().Time("t", tm).Msg("")
+	if got, want := out.String(), `{"t":1609556645}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestSetDefaultsForNewLoggers(t *testing.T) {
+	orig := DefaultConfig()
+	defer updateConfig(func(c *Config) { *c = orig })
+
+	out := &bytes.Buffer{}
+	before := NewOption().WithWriter(out).Logger()
+	Set.FiledName().MessageFieldName("msg")
+	after := NewOption().WithWriter(out).Logger()
+
+	before.Log().Msg("a")
+	after.Log().Msg("b")
+	if got, want := out.String(), `{"message":"a"}`+"\n"+`{"msg":"b"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestSetConcurrent(t *testing.T) {
+	orig := DefaultConfig()
+	defer updateConfig(func(c *Config) { *c = orig })
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			Set.TimeFormat(time.RFC3339Nano).BaseTimeDurationUnit(time.Microsecond)
+			Set.FiledName().MessageFieldName("msg")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			log := NewOption().WithWriter(&bytes.Buffer{}).Logger()
+			log.Info().TimeDur("d", time.Second).Timestamp().Msg("hi")
+		}
+	}()
+	wg.Wait()
+}
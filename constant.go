@@ -1,9 +1,7 @@
 package clog
 
 import (
-	"strconv"
 	"sync/atomic"
-	"time"
 )
 
 const (
@@ -43,50 +41,6 @@ func init() {
 var (
 	// 全局日志等级
 	gLevel = new(int32)
-
-	// timeLayoutFormat 定义time字段日期格式
-	timeLayoutFormat = time.RFC3339
-
-	// levelFieldName level字段输出key
-	levelFieldName = "level"
-
-	// timestampFieldName Timestamp() 方法日期key
-	timestampFieldName = "time"
-	// errorFieldName 错误信息字段key Err()方法key
-	errorFieldName = "error"
-	// errorStackFieldName 栈信息key
-	errorStackFieldName = "stack"
-
-	// messageFieldName Msg()方法key
-	messageFieldName = "message"
-	// callerFieldName caller()方法key
-	callerFieldName = "caller"
-
-	// errorHandler 当向输出源写入数据时遇到错误，此方法调用，默认输出至stderr. 此方法必须为非阻塞且线程安全
-	errorHandler     func(err error)
-	errorMarshalFunc = func(err error) interface{} {
-		return err
-	}
-	levelFieldMarshalFunc = func(l Level) string {
-		return l.String()
-	}
-	// timestampFunc 调用函数时生成时间的方法
-	timestampFunc = time.Now
-	// errorStackMarshal extract the stack from err if any.
-	errorStackMarshal func(err error) interface{}
-	// durationFieldUnit defines the unit for time.Duration type fields added
-	// timeDuration / durationFieldUnit
-	durationFieldUnit = time.Millisecond
-
-	// durationFieldInteger 为true则以整形输出时间戳
-	durationFieldInteger = false
-
-	callerMarshalFunc = func(file string, line int) string {
-		return file + ":" + strconv.Itoa(line)
-	}
-
-	// callerSkipFrameCount is the number of stack frames to skip to find the caller.
-	callerSkipFrameCount = 2
 )
 
 // SetGlobalLevel 设置全局日志等级,通过此全局变量控制全局日志输出.
@@ -98,3 +52,43 @@ func SetGlobalLevel(l Level) {
 func GlobalLevel() Level {
 	return Level(atomic.LoadInt32(gLevel))
 }
+
+// LevelVar 可并发修改的日志等级, 通过 WithLevelVar 设置后Logger的等级随Set变化.
+type LevelVar struct {
+	v int32
+}
+
+// NewLevelVar 返回初始等级为l的LevelVar.
+func NewLevelVar(l Level) *LevelVar {
+	return &LevelVar{v: int32(l)}
+}
+
+// Level 返回当前等级.
+func (v *LevelVar) Level() Level {
+	return Level(atomic.LoadInt32(&v.v))
+}
+
+// Set 修改等级, 对使用此LevelVar的所有Logger立即生效.
+func (v *LevelVar) Set(l Level) {
+	atomic.StoreInt32(&v.v, int32(l))
+}
+
+// LevelFieldName 返回默认配置的level字段key,供输出源解析日志事件使用.
+func LevelFieldName() string {
+	return loadConfig().LevelFieldName
+}
+
+// TimestampFieldName 返回默认配置的时间字段key.
+func TimestampFieldName() string {
+	return loadConfig().TimestampFieldName
+}
+
+// MessageFieldName 返回默认配置的Msg()字段key.
+func MessageFieldName() string {
+	return loadConfig().MessageFieldName
+}
+
+// CallerFieldName 返回默认配置的Caller()字段key.
+func CallerFieldName() string {
+	return loadConfig().CallerFieldName
+}
@@ -0,0 +1,58 @@
+package clog
+
+import "context"
+
+type loggerCtxKey struct{}
+
+// clone 返回Logger的副本,前缀与Hook不与原Logger共享底层数组.
+func (l *Logger) clone() *Logger {
+	c := &Logger{
+		w:      l.w,
+		level:  l.level,
+		lvar:   l.lvar,
+		redact: l.redact,
+		order:  l.order,
+		limit:  l.limit,
+		det:    l.det,
+		cfg:    l.cfg,
+	}
+	if len(l.preStr) > 0 {
+		c.preStr = make([]byte, len(l.preStr))
+		copy(c.preStr, l.preStr)
+	}
+	if len(l.preHook) > 0 {
+		c.preHook = make([]Hook, len(l.preHook))
+		copy(c.preHook, l.preHook)
+	}
+	if len(l.hooks) > 0 {
+		c.hooks = make([]Hook, len(l.hooks))
+		copy(c.hooks, l.hooks)
+	}
+	return c
+}
+
+// With 返回追加了前缀字段的子Logger, 不影响当前Logger.
+//
+//	reqLog := log.With("request_id", id)
+func (l *Logger) With(key string, val interface{}) *Logger {
+	c := l.clone()
+	c.AppendStrPrefix(key, val)
+	return c
+}
+
+// WithContext 返回关联了当前Logger的context, 通过Ctx获取.
+func (l *Logger) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// Ctx 返回WithContext关联的Logger, 未关联时返回全局实例.
+//
+//	clog.Ctx(r.Context()).Info().Msg("handled")
+func Ctx(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+	return std()
+}
@@ -0,0 +1,135 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+// Deterministic 稳定输出配置, 使golden文件与Example测试的输出不随运行时间与机器变化.
+type Deterministic struct {
+	// Time Timestamp()与WithTimestamp输出的时间, 零值为 2000-01-01T00:00:00Z
+	Time time.Time
+	// Step 大于0时每个事件的时间在Time基础上依次递增Step
+	Step time.Duration
+	// CallerRoot Caller()输出的文件路径去除的目录前缀, 为空时去除至文件所在模块的根目录(包含go.mod的目录)
+	CallerRoot string
+	// SortKeys 顶层字段按key排序
+	SortKeys bool
+	// FloatDecimals 大于0时所有浮点数四舍五入保留的小数位数, 并去除末尾的0
+	FloatDecimals int
+}
+
+// determinism Logger的稳定输出规则, 未开启时为nil.
+type determinism struct {
+	Deterministic
+	visitor
+	seq     int64
+	modules sync.Map // 目录 -> 模块根目录
+}
+
+func newDeterminism(d Deterministic) *determinism {
+	if d.Time.IsZero() {
+		d.Time = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if len(d.CallerRoot) > 0 {
+		d.CallerRoot = filepath.ToSlash(filepath.Clean(d.CallerRoot)) + "/"
+	}
+	return &determinism{Deterministic: d}
+}
+
+// now 返回下一个事件的时间.
+func (d *determinism) now() time.Time {
+	if d.Step <= 0 {
+		return d.Time
+	}
+	n := atomic.AddInt64(&d.seq, 1) - 1
+	return d.Time.Add(time.Duration(n) * d.Step)
+}
+
+// trimCaller 返回相对于CallerRoot或模块根目录的文件路径.
+func (d *determinism) trimCaller(file string) string {
+	file = filepath.ToSlash(file)
+	if len(d.CallerRoot) > 0 {
+		return strings.TrimPrefix(file, d.CallerRoot)
+	}
+	dir := filepath.Dir(file)
+	root, ok := d.modules.Load(dir)
+	if !ok {
+		root = moduleRoot(dir)
+		d.modules.Store(dir, root)
+	}
+	if r := root.(string); len(r) > 0 {
+		return strings.TrimPrefix(file, r)
+	}
+	return file
+}
+
+// moduleRoot 自dir向上查找包含go.mod的目录, 返回以/结尾的路径, 未找到时返回空字符串.
+func moduleRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.ToSlash(dir) + "/"
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// changes 根据事件的结构概要与未解码的顶层字段名判断是否需要舍入浮点数或排序字段.
+func (d *determinism) changes(keys [][]byte, s jsonfield.Summary) bool {
+	if d.FloatDecimals > 0 && s.Floats {
+		return true
+	}
+	if !d.SortKeys {
+		return false
+	}
+	if s.Escaped {
+		return true
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sort 按SortKeys对事件的顶层字段排序.
+func (d *determinism) sort(fields []jsonfield.Field) {
+	if d.SortKeys {
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	}
+}
+
+// Number 将浮点数按FloatDecimals舍入, 并去除末尾的0.
+func (d *determinism) Number(_ string, n json.Number) string {
+	if d.FloatDecimals <= 0 || !strings.ContainsAny(string(n), ".eE") {
+		return string(n)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return string(n)
+	}
+	p := math.Pow(10, float64(d.FloatDecimals))
+	f = math.Round(f*p) / p
+	s := strconv.FormatFloat(f, 'f', d.FloatDecimals, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package clog
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeterministic(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithTimestamp().WithDeterministic(Deterministic{
+		Time:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Step:          time.Second,
+		SortKeys:      true,
+		FloatDecimals: 2,
+	}).Logger()
+	log.Info().Float64("pi", 3.14159).Floats64("fs", []float64{0.005, 1.0, -0.001}).Int("n", 10).Msg("a")
+	log.Info().Interface("obj", map[string]interface{}{"x": 1.23456}).Msg("b")
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		`{"fs":[0.01,1,0],"level":"info","message":"a","n":10,"pi":3.14,"time":` + string(trs.AppendTime(nil, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), loadConfig().TimeFormat)) + `}`,
+		`{"level":"info","message":"b","obj":{"x":1.23},"time":` + string(trs.AppendTime(nil, time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), loadConfig().TimeFormat)) + `}`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got[i], want[i])
+		}
+	}
+
+	// 字段已有序且不含浮点数时原样输出
+	out.Reset()
+	log = NewOption().WithWriter(out).WithDeterministic(Deterministic{SortKeys: true, FloatDecimals: 2}).Logger()
+	log.Log().Int("a", 1).RawJSON("b", []byte(`{"y": 1, "x": 2}`)).Msg("")
+	log.Log().Int("b", 1).RawJSON("a", []byte(`{"y": 1, "x": 2}`)).Msg("")
+	if got, want := out.String(), `{"a":1,"b":{"y": 1, "x": 2}}`+"\n"+`{"a":{"y":1,"x":2},"b":1}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestDeterministicCaller(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithDeterministic(Deterministic{}).Logger()
+	log.Log().Caller().Msg("")
+	if got := out.String(); !strings.HasPrefix(got, `{"caller":"deterministic_test.go:`) {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, `{"caller":"deterministic_test.go:<line>"}`)
+	}
+
+	out.Reset()
+	wd, _ := os.Getwd()
+	log = NewOption().WithWriter(out).WithDeterministic(Deterministic{CallerRoot: wd + "/.."}).Logger()
+	log.Log().Caller().Msg("")
+	prefix := `{"caller":"` + wd[strings.LastIndex(wd, "/")+1:] + `/deterministic_test.go:`
+	if got := out.String(); !strings.HasPrefix(got, prefix) {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, prefix)
+	}
+}
+
+func Example_deterministic() {
+	log := NewOption().WithWriter(os.Stdout).WithTimestamp().WithDeterministic(Deterministic{
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		SortKeys: true,
+	}).Logger()
+	log.Info().Str("user", "alice").Int("id", 7).Msg("login")
+	// Output: {"id":7,"level":"info","message":"login","time":"2024-01-02T03:04:05Z","user":"alice"}
+}
@@ -1,6 +1,7 @@
 package clog
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -25,6 +26,21 @@ type Event struct {
 	done  func(msg string)
 	stack bool // 错误堆栈跟踪
 	hook  []Hook
+	ctx   context.Context
+	// redact 所属Logger的脱敏规则,未配置时为nil
+	redact *redactor
+	// order 所属Logger的字段去重与排序规则,未开启时为nil
+	order *fieldOrder
+	// limit 所属Logger的大小限制,未设置时为nil
+	limit *limiter
+	// lazy 延迟至Msg时计算的字段
+	lazy []lazyField
+	// det 所属Logger的稳定输出规则,未开启时为nil
+	det *determinism
+	// cfg 所属Logger的格式配置,不属于Logger的事件使用创建时的默认配置
+	cfg *Config
+	// keys rewrite时扫描得到的顶层字段名, 随事件复用
+	keys [][]byte
 }
 
 type LogObjectMarshaler interface {
@@ -43,12 +59,25 @@ func newEvent(w LevelWriter, level Level) *Event {
 	e.w = w
 	e.level = level
 	e.stack = false
+	e.ctx = nil
+	e.redact = nil
+	e.order = nil
+	e.limit = nil
+	e.det = nil
+	e.cfg = loadConfig()
+	e.lazy = e.lazy[:0]
 	return e
 }
 
 func putEvent(e *Event) {
 	if cap(e.buf) > maxCap {
-		return
+		// 超大事件不保留其buf,避免池中对象长期占用内存
+		e.buf = make([]byte, 0, initCap)
+		e.keys = nil
+	}
+	e.ctx = nil
+	for i := range e.lazy {
+		e.lazy[i] = lazyField{}
 	}
 	eventPool.Put(e)
 }
@@ -83,7 +112,23 @@ func (e *Event) Discard() *Event {
 	return nil
 }
 
-// Msg 输出日志 如果参数字段不为空字符串  则增加 messageFieldName 定义的field name
+// Ctx 为事件关联context,供Hook通过GetCtx读取,如注入trace_id等链路信息. 不会写入任何字段
+func (e *Event) Ctx(ctx context.Context) *Event {
+	if e != nil {
+		e.ctx = ctx
+	}
+	return e
+}
+
+// GetCtx 返回Ctx关联的context,未关联时返回context.Background()
+func (e *Event) GetCtx() context.Context {
+	if e == nil || e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// Msg 输出日志 如果参数字段不为空字符串  则增加 Config.MessageFieldName 定义的field name
 // 调用后输出此次日志上下文数据
 // NOTICE: 此方法只能被调用一次，多次调用会引发意料之外的结果
 func (e *Event) Msg(msg string) {
@@ -112,27 +157,46 @@ func (e *Event) msg(msg string) {
 	for _, hook := range e.hook {
 		hook.Run(e, e.level, msg)
 	}
+	if len(e.lazy) > 0 && e.level != Disabled {
+		e.evalLazy()
+	}
 	if msg != "" {
-		e.buf = trs.AppendString(trs.AppendKey(e.buf, messageFieldName), msg)
+		e.buf = trs.AppendString(trs.AppendKey(e.buf, e.cfg.MessageFieldName), msg)
+	}
+	if e.order != nil || e.det != nil || e.limit != nil {
+		if !e.rewrite() {
+			e.level = Disabled
+		}
 	}
 	if e.done != nil {
 		defer e.done(msg)
 	}
 	if err := e.write(); err != nil {
-		if errorHandler != nil {
-			errorHandler(err)
-		} else {
-			_, _ = fmt.Fprintf(os.Stderr, "clog: could not write to output: %v\n", err)
-		}
+		e.cfg.handleError(err)
 	}
 }
 
+// HandleError 将写入错误交由默认配置的ErrorHandler(clog.Set.ErrHandler)处理,未设置时输出至stderr.
+// 异步输出源在后台协程中报告写入错误时默认调用, 与写入的Logger通过WithConfig设置的ErrorHandler无关;
+// 需要按Logger区分时通过各输出源的ErrorHandler选项设置.
+func HandleError(err error) {
+	loadConfig().handleError(err)
+}
+
+func printError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "clog: could not write to output: %v\n", err)
+}
+
 // Fields 向事件上下文添加Map类型数据，
 func (e *Event) Fields(fields map[string]interface{}) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = appendFields(e.buf, fields)
+	if e.redact != nil {
+		e.buf = e.redact.appendFields(e.buf, appendFields(trs.AppendBeginMarker(nil), fields, e.cfg))
+		return e
+	}
+	e.buf = appendFields(e.buf, fields, e.cfg)
 	return e
 }
 
@@ -142,8 +206,13 @@ func (e *Event) Dict(key string, dict *Event) *Event {
 	if e == nil {
 		return e
 	}
+	dict.evalLazy()
 	dict.buf = trs.AppendEndMarker(dict.buf)
-	e.buf = append(trs.AppendKey(e.buf, key), dict.buf...)
+	if e.redact != nil {
+		e.buf = e.redact.appendJSON(trs.AppendKey(e.buf, key), key, dict.buf)
+	} else {
+		e.buf = append(trs.AppendKey(e.buf, key), dict.buf...)
+	}
 	putEvent(dict)
 	return e
 }
@@ -168,8 +237,13 @@ func (e *Event) Array(key string, arr LogArrayMarshaler) *Event {
 		a = aa
 	} else {
 		a = Arr()
+		a.cfg = e.cfg
 		arr.MarshalArray(a)
 	}
+	if e.redact != nil {
+		e.buf = e.redact.appendJSON(e.buf, key, a.write(nil))
+		return e
+	}
 	e.buf = a.write(e.buf)
 	return e
 }
@@ -186,6 +260,15 @@ func (e *Event) Object(key string, obj LogObjectMarshaler) *Event {
 		return e
 	}
 	e.buf = trs.AppendKey(e.buf, key)
+	if e.redact != nil {
+		o := newEvent(nil, 0)
+		o.cfg = e.cfg
+		o.buf = o.buf[:0]
+		o.appendObject(obj)
+		e.buf = e.redact.appendJSON(e.buf, key, o.buf)
+		putEvent(o)
+		return e
+	}
 	e.appendObject(obj)
 	return e
 }
@@ -204,6 +287,9 @@ func (e *Event) Str(key, val string) *Event {
 	if e == nil {
 		return e
 	}
+	if e.redact != nil {
+		val = e.redact.str(key, val)
+	}
 	e.buf = trs.AppendString(trs.AppendKey(e.buf, key), val)
 	return e
 }
@@ -213,6 +299,17 @@ func (e *Event) Strs(key string, vals []string) *Event {
 	if e == nil {
 		return e
 	}
+	if e.redact != nil {
+		if e.redact.matchKey(key) {
+			e.buf = trs.AppendString(trs.AppendKey(e.buf, key), redactedValue)
+			return e
+		}
+		masked := make([]string, len(vals))
+		for i, val := range vals {
+			masked[i] = e.redact.value(val)
+		}
+		vals = masked
+	}
 	e.buf = trs.AppendStrings(trs.AppendKey(e.buf, key), vals)
 	return e
 }
@@ -223,8 +320,7 @@ func (e *Event) Stringer(key string, val fmt.Stringer) *Event {
 		return e
 	}
 	if val != nil {
-		e.buf = trs.AppendString(trs.AppendKey(e.buf, key), val.String())
-		return e
+		return e.Str(key, val.String())
 	}
 	e.buf = trs.AppendInterface(trs.AppendKey(e.buf, key), nil)
 	return e
@@ -240,6 +336,9 @@ func (e *Event) Bytes(key string, val []byte) *Event {
 	if e == nil {
 		return e
 	}
+	if e.redact != nil {
+		val = []byte(e.redact.str(key, string(val)))
+	}
 	e.buf = trs.AppendBytes(trs.AppendKey(e.buf, key), val)
 	return e
 }
@@ -249,17 +348,17 @@ func (e *Event) Hex(key string, val []byte) *Event {
 	if e == nil {
 		return e
 	}
+	if e.redact != nil && e.redact.matchKey(key) {
+		e.buf = trs.AppendString(trs.AppendKey(e.buf, key), redactedValue)
+		return e
+	}
 	e.buf = trs.AppendHex(trs.AppendKey(e.buf, key), val)
 	return e
 }
 
 // HexStr 同Hex().
 func (e *Event) HexStr(key string, val string) *Event {
-	if e == nil {
-		return e
-	}
-	e.buf = trs.AppendHex(trs.AppendKey(e.buf, key), []byte(val))
-	return e
+	return e.Hex(key, []byte(val))
 }
 
 // RawJSON 添加原始JSON数据到事件上下文.
@@ -272,6 +371,10 @@ func (e *Event) RawJSON(key string, b []byte) *Event {
 	if e == nil {
 		return e
 	}
+	if e.redact != nil {
+		e.buf = e.redact.appendJSON(trs.AppendKey(e.buf, key), key, b)
+		return e
+	}
 	e.buf = append(trs.AppendKey(e.buf, key), b...)
 	return e
 }
@@ -282,7 +385,7 @@ func (e *Event) AnErr(key string, err error) *Event {
 	if e == nil {
 		return e
 	}
-	switch m := errorMarshalFunc(err).(type) {
+	switch m := e.cfg.ErrorMarshalFunc(err).(type) {
 	case nil:
 		return e
 	case LogObjectMarshaler:
@@ -307,8 +410,9 @@ func (e *Event) Errs(key string, errs []error) *Event {
 		return e
 	}
 	arr := Arr()
+	arr.cfg = e.cfg
 	for _, err := range errs {
-		switch m := errorMarshalFunc(err).(type) {
+		switch m := e.cfg.ErrorMarshalFunc(err).(type) {
 		case LogObjectMarshaler:
 			arr = arr.Object(m)
 		case error:
@@ -326,29 +430,29 @@ func (e *Event) Errs(key string, errs []error) *Event {
 //
 // 通过clog.Set.FiledName().ErrorFieldName("")更改默认 field name.
 //
-// 如果在此之前调用了Stack()函数且通过clog.Set.ErrStackMarshal(func)定义了errorStackMarshal
-// 则错误通过errorStackMarshal将结果添加到事件上下文中.
+// 如果在此之前调用了Stack()函数且通过clog.Set.ErrStackMarshal(func)定义了Config.ErrorStackMarshalFunc
+// 则错误通过ErrorStackMarshalFunc将结果添加到事件上下文中.
 // 通过clog.Set.FiledName().ErrStackFieldName()更改默认 field name.
 func (e *Event) Err(err error) *Event {
 	if e == nil {
 		return e
 	}
-	if e.stack && errorStackMarshal != nil {
-		switch m := errorStackMarshal(err).(type) {
+	if e.stack && e.cfg.ErrorStackMarshalFunc != nil {
+		switch m := e.cfg.ErrorStackMarshalFunc(err).(type) {
 		case nil:
 		case LogObjectMarshaler:
-			e.Object(errorStackFieldName, m)
+			e.Object(e.cfg.ErrorStackFieldName, m)
 		case error:
 			if m != nil && !isNilValue(m) {
-				e.Str(errorStackFieldName, m.Error())
+				e.Str(e.cfg.ErrorStackFieldName, m.Error())
 			}
 		case string:
-			e.Str(errorStackFieldName, m)
+			e.Str(e.cfg.ErrorStackFieldName, m)
 		default:
-			e.Interface(errorStackFieldName, m)
+			e.Interface(e.cfg.ErrorStackFieldName, m)
 		}
 	}
-	return e.AnErr(errorFieldName, err)
+	return e.AnErr(e.cfg.ErrorFieldName, err)
 }
 
 // Stack 为传递给Err()的错误开启堆栈打印跟踪.
@@ -609,8 +713,8 @@ func (e *Event) Floats64(key string, f []float64) *Event {
 }
 
 // Timestamp 添加时间数据到事件上下文.
-// 通过clog.Set.FiledName().TimestampFieldName()更改timestampFieldName. 函数等同于调用Time("time",time.Now())
-// 通过clog.Set.TimeFormat(timeLayout)更改timeLayoutFormat以自定义默认时间格式
+// 通过clog.Set.FiledName().TimestampFieldName()或Config.TimestampFieldName更改字段名. 函数等同于调用Time("time",time.Now())
+// 通过clog.Set.TimeFormat(timeLayout)或Config.TimeFormat自定义时间格式
 //
 //  	Log().Timestamp().Cease()
 //  	Log().Timestamp().Timestamp().Cease()
@@ -623,12 +727,20 @@ func (e *Event) Timestamp() *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendTime(trs.AppendKey(e.buf, timestampFieldName), timestampFunc(), timeLayoutFormat)
+	e.buf = trs.AppendTime(trs.AppendKey(e.buf, e.cfg.TimestampFieldName), e.now(), e.cfg.TimeFormat)
 	return e
 }
 
+// now 返回事件时间,开启稳定输出时由Logger的Deterministic配置生成.
+func (e *Event) now() time.Time {
+	if e.det != nil {
+		return e.det.now()
+	}
+	return e.cfg.TimestampFunc()
+}
+
 // Time 添加time类型数据到事件上下文.
-// 更改timeLayoutFormat以自定义默认时间格式
+// 通过Config.TimeFormat自定义时间格式
 //  	Log().Time("t",time.Now()).Cease()
 // Output:
 //  	{"t":"2006-01-02T15:04:05+08:00"}
@@ -636,7 +748,7 @@ func (e *Event) Time(key string, t time.Time) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendTime(trs.AppendKey(e.buf, key), t, timeLayoutFormat)
+	e.buf = trs.AppendTime(trs.AppendKey(e.buf, key), t, e.cfg.TimeFormat)
 	return e
 }
 
@@ -657,7 +769,7 @@ func (e *Event) Times(key string, t []time.Time) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendTimes(trs.AppendKey(e.buf, key), t, timeLayoutFormat)
+	e.buf = trs.AppendTimes(trs.AppendKey(e.buf, key), t, e.cfg.TimeFormat)
 	return e
 }
 
@@ -675,7 +787,7 @@ func (e *Event) TimeDur(key string, d time.Duration) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendDuration(trs.AppendKey(e.buf, key), d)
+	e.buf = trs.appendDuration(trs.AppendKey(e.buf, key), d, e.cfg)
 	return e
 }
 
@@ -684,7 +796,7 @@ func (e *Event) TimeDurs(key string, d []time.Duration) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendDurations(trs.AppendKey(e.buf, key), d)
+	e.buf = trs.appendDurations(trs.AppendKey(e.buf, key), d, e.cfg)
 	return e
 }
 
@@ -712,7 +824,7 @@ func (e *Event) TimeDiff(key string, start time.Time, end time.Time) *Event {
 	if e == nil {
 		return e
 	}
-	e.buf = trs.AppendDuration(trs.AppendKey(e.buf, key), end.Sub(start))
+	e.buf = trs.appendDuration(trs.AppendKey(e.buf, key), end.Sub(start), e.cfg)
 	return e
 }
 
@@ -735,16 +847,23 @@ func (e *Event) Interface(key string, i interface{}) *Event {
 	if obj, ok := i.(LogObjectMarshaler); ok {
 		return e.Object(key, obj)
 	}
-	e.buf = trs.AppendInterface(trs.AppendKey(e.buf, key), i)
+	if e.redact != nil {
+		e.buf = e.redact.appendJSON(trs.AppendKey(e.buf, key), key, trs.appendInterface(nil, i, e.cfg))
+		return e
+	}
+	e.buf = trs.appendInterface(trs.AppendKey(e.buf, key), i, e.cfg)
 	return e
 }
 
 // Caller 添加函数调用文件与行号信息到事件上下文.
 // 通过 clog.Set.FiledName().CallerFieldName("")更改默认field name.
 func (e *Event) Caller(skip ...int) *Event {
-	sk := callerSkipFrameCount
+	if e == nil {
+		return e
+	}
+	sk := e.cfg.CallerSkipFrameCount
 	if len(skip) > 0 {
-		sk = skip[0] + callerSkipFrameCount
+		sk += skip[0]
 	}
 	return e.caller(sk)
 }
@@ -757,7 +876,14 @@ func (e *Event) caller(skip int) *Event {
 	if !ok {
 		return e
 	}
-	e.buf = trs.AppendString(trs.AppendKey(e.buf, callerFieldName), callerMarshalFunc(file, line))
+	return e.appendCaller(file, line)
+}
+
+func (e *Event) appendCaller(file string, line int) *Event {
+	if e.det != nil {
+		file = e.det.trimCaller(file)
+	}
+	e.buf = trs.AppendString(trs.AppendKey(e.buf, e.cfg.CallerFieldName), e.cfg.CallerMarshalFunc(file, line))
 	return e
 }
 
@@ -11,7 +11,7 @@ func isNilValue(i interface{}) bool {
 	return (*[2]uintptr)(unsafe.Pointer(&i))[1] == 0
 }
 
-func appendFields(dst []byte, fields map[string]interface{}) []byte {
+func appendFields(dst []byte, fields map[string]interface{}, cfg *Config) []byte {
 	keys := make([]string, 0, len(fields))
 	for key := range fields {
 		keys = append(keys, key)
@@ -22,6 +22,7 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 		val := fields[key]
 		if val, ok := val.(LogObjectMarshaler); ok {
 			e := newEvent(nil, 0)
+			e.cfg = cfg
 			e.buf = e.buf[:0]
 			e.appendObject(val)
 			dst = append(dst, e.buf...)
@@ -34,9 +35,10 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 		case []byte:
 			dst = trs.AppendBytes(dst, val)
 		case error:
-			switch m := errorMarshalFunc(val).(type) {
+			switch m := cfg.ErrorMarshalFunc(val).(type) {
 			case LogObjectMarshaler:
 				e := newEvent(nil, 0)
+				e.cfg = cfg
 				e.buf = e.buf[:0]
 				e.appendObject(m)
 				dst = append(dst, e.buf...)
@@ -50,14 +52,15 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 			case string:
 				dst = trs.AppendString(dst, m)
 			default:
-				dst = trs.AppendInterface(dst, m)
+				dst = trs.appendInterface(dst, m, cfg)
 			}
 		case []error:
 			dst = trs.AppendArrayStart(dst)
 			for i, err := range val {
-				switch m := errorMarshalFunc(err).(type) {
+				switch m := cfg.ErrorMarshalFunc(err).(type) {
 				case LogObjectMarshaler:
 					e := newEvent(nil, 0)
+					e.cfg = cfg
 					e.buf = e.buf[:0]
 					e.appendObject(m)
 					dst = append(dst, e.buf...)
@@ -71,7 +74,7 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 				case string:
 					dst = trs.AppendString(dst, m)
 				default:
-					dst = trs.AppendInterface(dst, m)
+					dst = trs.appendInterface(dst, m, cfg)
 				}
 
 				if i < (len(val) - 1) {
@@ -106,9 +109,9 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 		case float64:
 			dst = trs.AppendFloat64(dst, val)
 		case time.Time:
-			dst = trs.AppendTime(dst, val, timeLayoutFormat)
+			dst = trs.AppendTime(dst, val, cfg.TimeFormat)
 		case time.Duration:
-			dst = trs.AppendDuration(dst, val)
+			dst = trs.appendDuration(dst, val, cfg)
 		case *string:
 			if val != nil {
 				dst = trs.AppendString(dst, *val)
@@ -195,13 +198,13 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 			}
 		case *time.Time:
 			if val != nil {
-				dst = trs.AppendTime(dst, *val, timeLayoutFormat)
+				dst = trs.AppendTime(dst, *val, cfg.TimeFormat)
 			} else {
 				dst = trs.AppendNil(dst)
 			}
 		case *time.Duration:
 			if val != nil {
-				dst = trs.AppendDuration(dst, *val)
+				dst = trs.appendDuration(dst, *val, cfg)
 			} else {
 				dst = trs.AppendNil(dst)
 			}
@@ -234,9 +237,9 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 		case []float64:
 			dst = trs.AppendFloats64(dst, val)
 		case []time.Time:
-			dst = trs.AppendTimes(dst, val, timeLayoutFormat)
+			dst = trs.AppendTimes(dst, val, cfg.TimeFormat)
 		case []time.Duration:
-			dst = trs.AppendDurations(dst, val)
+			dst = trs.appendDurations(dst, val, cfg)
 		case nil:
 			dst = trs.AppendNil(dst)
 		case net.IP:
@@ -246,7 +249,7 @@ func appendFields(dst []byte, fields map[string]interface{}) []byte {
 		case net.HardwareAddr:
 			dst = trs.AppendMACAddr(dst, val)
 		default:
-			dst = trs.AppendInterface(dst, val)
+			dst = trs.appendInterface(dst, val, cfg)
 		}
 	}
 	return dst
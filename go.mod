@@ -1,3 +1,17 @@
 module github.com/cuckooemm/clog
 
-go 1.12
+go 1.21
+
+require (
+	go.opentelemetry.io/otel/trace v1.28.0
+	golang.org/x/sys v0.18.0
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.1
+)
+
+require (
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
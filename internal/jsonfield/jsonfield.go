@@ -0,0 +1,88 @@
+// Package jsonfield 提供遍历clog JSON日志事件顶层字段的工具函数,供各输出源复用.
+package jsonfield
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+var errNotObject = errors.New("jsonfield: event is not a JSON object")
+
+// Range 按出现顺序遍历事件p的顶层字段, fn返回false时停止遍历.
+// val为字段的原始JSON值.
+func Range(p []byte, fn func(key string, val json.RawMessage) bool) error {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return errNotObject
+	}
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		if err = dec.Decode(&val); err != nil {
+			return err
+		}
+		if !fn(key, val) {
+			return nil
+		}
+	}
+	if _, err = dec.Token(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Lookup 返回事件p中第一个key字段的原始JSON值.
+func Lookup(p []byte, key string) (json.RawMessage, bool) {
+	var (
+		found json.RawMessage
+		ok    bool
+	)
+	_ = Range(p, func(k string, val json.RawMessage) bool {
+		if k == key {
+			found, ok = val, true
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// String 返回字段值的文本形式: 字符串去除引号并反转义, 其余类型返回原始JSON.
+func String(val json.RawMessage) string {
+	if len(val) > 0 && val[0] == '"' {
+		var s string
+		if err := json.Unmarshal(val, &s); err == nil {
+			return s
+		}
+	}
+	return string(val)
+}
+
+// IsScalar 判断字段值是否为字符串,数字,布尔或null.
+func IsScalar(val json.RawMessage) bool {
+	return len(val) > 0 && val[0] != '{' && val[0] != '['
+}
+
+// Caller 拆分"file:line"形式的caller字段.
+func Caller(caller string) (file string, line string, ok bool) {
+	i := strings.LastIndexByte(caller, ':')
+	if i <= 0 || i == len(caller)-1 {
+		return caller, "", false
+	}
+	for _, c := range caller[i+1:] {
+		if c < '0' || c > '9' {
+			return caller, "", false
+		}
+	}
+	return caller[:i], caller[i+1:], true
+}
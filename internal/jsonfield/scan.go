@@ -0,0 +1,227 @@
+package jsonfield
+
+import (
+	"unicode/utf8"
+)
+
+// Summary 事件结构的概要, 由Scan在不解码的情况下统计, 用于判断是否需要解析并重新编码事件.
+type Summary struct {
+	Depth     int  // 对象与数组的最大层数, 与Visitor.Enter的depth一致, 事件本身不计
+	MaxString int  // 字符串值的最大字节数, 不小于解码后的长度
+	MaxItems  int  // 数组的最大元素数
+	Floats    bool // 存在包含小数点或指数的数字
+	Escaped   bool // 顶层字段名包含转义字符
+}
+
+// Scan 不解码地扫描事件p, 返回结构概要并将顶层字段名(不含引号, 未解码)追加至keys.
+// p不是JSON对象时ok为false, 此时Summary与keys不完整.
+func Scan(p []byte, keys [][]byte) (s Summary, _ [][]byte, ok bool) {
+	sc := scanner{p: p}
+	sc.space()
+	if !sc.consume('{') {
+		return sc.s, keys, false
+	}
+	sc.space()
+	if sc.consume('}') {
+		return sc.s, keys, sc.end()
+	}
+	for {
+		sc.space()
+		start := sc.i + 1
+		_, escaped, ok := sc.str()
+		if !ok {
+			return sc.s, keys, false
+		}
+		keys = append(keys, p[start:sc.i-1:sc.i-1])
+		sc.s.Escaped = sc.s.Escaped || escaped
+		sc.space()
+		if !sc.consume(':') {
+			return sc.s, keys, false
+		}
+		sc.space()
+		if !sc.value(1) {
+			return sc.s, keys, false
+		}
+		sc.space()
+		if sc.consume('}') {
+			return sc.s, keys, sc.end()
+		}
+		if !sc.consume(',') {
+			return sc.s, keys, false
+		}
+	}
+}
+
+type scanner struct {
+	p []byte
+	i int
+	s Summary
+}
+
+func (sc *scanner) space() {
+	for sc.i < len(sc.p) {
+		switch sc.p[sc.i] {
+		case ' ', '\t', '\n', '\r':
+			sc.i++
+		default:
+			return
+		}
+	}
+}
+
+func (sc *scanner) consume(c byte) bool {
+	if sc.i < len(sc.p) && sc.p[sc.i] == c {
+		sc.i++
+		return true
+	}
+	return false
+}
+
+// end 判断对象之后只有空白字符.
+func (sc *scanner) end() bool {
+	sc.space()
+	return sc.i == len(sc.p)
+}
+
+// value 扫描一个值, depth为值所在的层数.
+func (sc *scanner) value(depth int) bool {
+	if sc.i >= len(sc.p) {
+		return false
+	}
+	switch c := sc.p[sc.i]; {
+	case c == '{':
+		return sc.object(depth)
+	case c == '[':
+		return sc.array(depth)
+	case c == '"':
+		n, _, ok := sc.str()
+		if n > sc.s.MaxString {
+			sc.s.MaxString = n
+		}
+		return ok
+	case c == 't':
+		return sc.literal("true")
+	case c == 'f':
+		return sc.literal("false")
+	case c == 'n':
+		return sc.literal("null")
+	case c == '-' || c >= '0' && c <= '9':
+		return sc.number()
+	}
+	return false
+}
+
+func (sc *scanner) object(depth int) bool {
+	if depth > sc.s.Depth {
+		sc.s.Depth = depth
+	}
+	sc.i++
+	sc.space()
+	if sc.consume('}') {
+		return true
+	}
+	for {
+		sc.space()
+		if _, _, ok := sc.str(); !ok {
+			return false
+		}
+		sc.space()
+		if !sc.consume(':') {
+			return false
+		}
+		sc.space()
+		if !sc.value(depth + 1) {
+			return false
+		}
+		sc.space()
+		if sc.consume('}') {
+			return true
+		}
+		if !sc.consume(',') {
+			return false
+		}
+	}
+}
+
+func (sc *scanner) array(depth int) bool {
+	if depth > sc.s.Depth {
+		sc.s.Depth = depth
+	}
+	sc.i++
+	sc.space()
+	if sc.consume(']') {
+		return true
+	}
+	for n := 1; ; n++ {
+		sc.space()
+		if !sc.value(depth + 1) {
+			return false
+		}
+		sc.space()
+		if sc.consume(']') {
+			if n > sc.s.MaxItems {
+				sc.s.MaxItems = n
+			}
+			return true
+		}
+		if !sc.consume(',') {
+			return false
+		}
+	}
+}
+
+// str 扫描字符串, 返回不小于解码后长度的字节数. 非法的UTF-8字节解码为U+FFFD, 按3字节计.
+func (sc *scanner) str() (n int, escaped bool, ok bool) {
+	if !sc.consume('"') {
+		return 0, false, false
+	}
+	for sc.i < len(sc.p) {
+		c := sc.p[sc.i]
+		switch {
+		case c == '"':
+			sc.i++
+			return n, escaped, true
+		case c == '\\':
+			escaped = true
+			sc.i += 2
+			n += 2
+		case c < 0x20:
+			return n, escaped, false
+		case c < utf8.RuneSelf:
+			sc.i++
+			n++
+		default:
+			r, size := utf8.DecodeRune(sc.p[sc.i:])
+			if r == utf8.RuneError && size == 1 {
+				n += 3
+			} else {
+				n += size
+			}
+			sc.i += size
+		}
+	}
+	return n, escaped, false
+}
+
+func (sc *scanner) literal(s string) bool {
+	if len(sc.p)-sc.i < len(s) || string(sc.p[sc.i:sc.i+len(s)]) != s {
+		return false
+	}
+	sc.i += len(s)
+	return true
+}
+
+// number 扫描数字, 不校验格式.
+func (sc *scanner) number() bool {
+	start := sc.i
+	for ; sc.i < len(sc.p); sc.i++ {
+		switch sc.p[sc.i] {
+		case '.', 'e', 'E':
+			sc.s.Floats = true
+		case '-', '+', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		default:
+			return sc.i > start
+		}
+	}
+	return sc.i > start
+}
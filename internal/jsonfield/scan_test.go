@@ -0,0 +1,40 @@
+package jsonfield
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	p := []byte(`{"a":1,"b\"c":{"d":[1,2.5,[true,null]],"e":"xyz"},"f":"é\n","g":[]} `)
+	s, keys, ok := Scan(p, nil)
+	if !ok {
+		t.Fatal("Scan failed")
+	}
+	want := Summary{Depth: 3, MaxString: 4, MaxItems: 3, Floats: true, Escaped: true}
+	if s != want {
+		t.Errorf("Scan = %+v, want %+v", s, want)
+	}
+	if got := fmt.Sprintf("%q", keys); got != `["a" "b\\\"c" "f" "g"]` {
+		t.Errorf("invalid keys: %s", got)
+	}
+
+	if s, _, _ = Scan([]byte("{\"a\":\"\xff\",\"b\":-1e3}"), nil); s.MaxString != 3 || !s.Floats || s.Depth != 0 {
+		t.Errorf("Scan = %+v", s)
+	}
+	for _, p := range []string{``, `[1]`, `{"a":}`, `{"a":1`, `{"a":[1,}`, `{"a":tru}`, `{"a":1}x`, `{"a":"` + "\n" + `"}`} {
+		if _, _, ok := Scan([]byte(p), nil); ok {
+			t.Errorf("Scan(%s) should fail", p)
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	keys := make([][]byte, 0, 16)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, keys, _ = Scan(benchEvent, keys[:0]); len(keys) == 0 {
+			b.Fatal("no keys")
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package jsonfield
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// Visitor 在Walker重新编码JSON值时调用, 用于按字段路径替换或截断值.
+// path为以.连接的字段路径, 数组元素沿用数组的路径; depth为嵌套层数, 事件的顶层字段为1.
+type Visitor interface {
+	// Enter 在值之前调用, container表示值为对象或数组. 返回ok时以字符串repl替换整个值, 不再遍历其内容.
+	Enter(path string, depth int, container bool) (repl string, ok bool)
+	// String 返回字符串值的替换值.
+	String(path string, s string) string
+	// Number 返回数字的替换文本.
+	Number(path string, n json.Number) string
+	// MaxItems 返回数组保留的最大元素数, 0表示不限制.
+	MaxItems(path string) int
+	// Dropped 数组超出MaxItems的n个元素被移除后调用, 返回ok时将字符串tail追加为数组末尾的元素.
+	Dropped(path string, n int) (tail string, ok bool)
+}
+
+// Visitors 按顺序组合多个Visitor: Enter与Dropped取第一个ok的结果, String与Number依次替换, MaxItems取最小的限制.
+type Visitors []Visitor
+
+func (vs Visitors) Enter(path string, depth int, container bool) (string, bool) {
+	for _, v := range vs {
+		if repl, ok := v.Enter(path, depth, container); ok {
+			return repl, true
+		}
+	}
+	return "", false
+}
+
+func (vs Visitors) String(path string, s string) string {
+	for _, v := range vs {
+		s = v.String(path, s)
+	}
+	return s
+}
+
+func (vs Visitors) Number(path string, n json.Number) string {
+	for _, v := range vs {
+		n = json.Number(v.Number(path, n))
+	}
+	return string(n)
+}
+
+func (vs Visitors) MaxItems(path string) int {
+	max := 0
+	for _, v := range vs {
+		if n := v.MaxItems(path); n > 0 && (max == 0 || n < max) {
+			max = n
+		}
+	}
+	return max
+}
+
+func (vs Visitors) Dropped(path string, n int) (string, bool) {
+	for _, v := range vs {
+		if tail, ok := v.Dropped(path, n); ok {
+			return tail, true
+		}
+	}
+	return "", false
+}
+
+// Field 事件的顶层字段.
+type Field struct {
+	Key string
+	Val []byte
+}
+
+// Walker 逐token重新编码JSON值. 字符串编码函数由调用方提供, 以保证输出与事件其余部分的编码一致.
+type Walker struct {
+	AppendString func(dst []byte, s string) []byte
+}
+
+// Append 按v重新编码raw并追加至dst, path与depth为raw所在的字段路径与层数.
+// raw不是合法的JSON时返回错误, 此时dst中可能已追加部分内容.
+func (w Walker) Append(dst []byte, raw []byte, path string, depth int, v Visitor) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return w.value(dst, dec, path, depth, v)
+}
+
+// Fields 解析事件p的顶层字段, v非nil时字段值按v重新编码. 整个事件只解析一次.
+func (w Walker) Fields(p []byte, v Visitor) ([]Field, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, errNotObject
+	}
+	var (
+		fields []Field
+		spans  [][2]int
+		out    []byte
+	)
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		if v == nil {
+			var val json.RawMessage
+			if err = dec.Decode(&val); err != nil {
+				return nil, err
+			}
+			fields = append(fields, Field{Key: key, Val: val})
+			continue
+		}
+		start := len(out)
+		if out, err = w.value(out, dec, key, 1, v); err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Key: key})
+		spans = append(spans, [2]int{start, len(out)})
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+	// out在编码过程中可能扩容, 结束后再切分各字段的值
+	for i, s := range spans {
+		fields[i].Val = out[s[0]:s[1]:s[1]]
+	}
+	return fields, nil
+}
+
+func (w Walker) value(dst []byte, dec *json.Decoder, path string, depth int, v Visitor) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return dst, err
+	}
+	d, container := tok.(json.Delim)
+	if repl, ok := v.Enter(path, depth, container); ok {
+		if container {
+			if err = skip(dec); err != nil {
+				return dst, err
+			}
+		}
+		return w.AppendString(dst, repl), nil
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if d == '[' {
+			dst = append(dst, '[')
+			max, n := v.MaxItems(path), 0
+			for ; dec.More(); n++ {
+				if max > 0 && n >= max {
+					var item json.RawMessage
+					if err = dec.Decode(&item); err != nil {
+						return dst, err
+					}
+					continue
+				}
+				if n > 0 {
+					dst = append(dst, ',')
+				}
+				if dst, err = w.value(dst, dec, path, depth+1, v); err != nil {
+					return dst, err
+				}
+			}
+			if max > 0 && n > max {
+				if tail, ok := v.Dropped(path, n-max); ok {
+					dst = w.AppendString(append(dst, ','), tail)
+				}
+			}
+			_, err = dec.Token()
+			return append(dst, ']'), err
+		}
+		dst = append(dst, '{')
+		for i := 0; dec.More(); i++ {
+			if tok, err = dec.Token(); err != nil {
+				return dst, err
+			}
+			name, _ := tok.(string)
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(w.AppendString(dst, name), ':')
+			sub := name
+			if len(path) > 0 {
+				sub = path + "." + name
+			}
+			if dst, err = w.value(dst, dec, sub, depth+1, v); err != nil {
+				return dst, err
+			}
+		}
+		_, err = dec.Token()
+		return append(dst, '}'), err
+	case string:
+		return w.AppendString(dst, v.String(path, t)), nil
+	case json.Number:
+		return append(dst, v.Number(path, t)...), nil
+	case bool:
+		return strconv.AppendBool(dst, t), nil
+	}
+	return append(dst, "null"...), nil
+}
+
+// skip 跳过当前对象或数组的剩余token.
+func skip(dec *json.Decoder) error {
+	for n := 1; n > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				n++
+			} else {
+				n--
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package jsonfield
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var walker = Walker{AppendString: func(dst []byte, s string) []byte {
+	return strconv.AppendQuote(dst, s)
+}}
+
+// testVisitor 记录Enter的路径与层数, 按路径替换值.
+type testVisitor struct {
+	entered []string
+}
+
+func (v *testVisitor) Enter(path string, depth int, container bool) (string, bool) {
+	v.entered = append(v.entered, path+"@"+strconv.Itoa(depth))
+	if path == "user.password" {
+		return "***", true
+	}
+	return "", false
+}
+
+func (v *testVisitor) String(path string, s string) string {
+	if path == "tags" {
+		return strings.ToUpper(s)
+	}
+	return s
+}
+
+func (v *testVisitor) Number(_ string, n json.Number) string {
+	if n == "1.50" {
+		return "1.5"
+	}
+	return string(n)
+}
+
+func (v *testVisitor) MaxItems(path string) int {
+	if path == "tags" {
+		return 2
+	}
+	return 0
+}
+
+func (v *testVisitor) Dropped(path string, n int) (string, bool) {
+	return "+" + strconv.Itoa(n), true
+}
+
+func TestFields(t *testing.T) {
+	p := []byte(`{"a":1,"user":{"name":"x","password":{"v":[1]}},"tags":["a","b","c"],"f":1.50,"a":null}`)
+	fields, err := walker.Fields(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range fields {
+		got = append(got, f.Key+"="+string(f.Val))
+	}
+	want := []string{`a=1`, `user={"name":"x","password":{"v":[1]}}`, `tags=["a","b","c"]`, `f=1.50`, `a=null`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("invalid fields:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	v := &testVisitor{}
+	if fields, err = walker.Fields(p, v); err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	for _, f := range fields {
+		got = append(got, f.Key+"="+string(f.Val))
+	}
+	want = []string{`a=1`, `user={"name":"x","password":"***"}`, `tags=["A","B","+1"]`, `f=1.5`, `a=null`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("invalid visited fields:\ngot:  %v\nwant: %v", got, want)
+	}
+	entered := []string{"a@1", "user@1", "user.name@2", "user.password@2", "tags@1", "tags@2", "tags@2", "f@1", "a@1"}
+	if strings.Join(v.entered, " ") != strings.Join(entered, " ") {
+		t.Errorf("invalid visited paths:\ngot:  %v\nwant: %v", v.entered, entered)
+	}
+}
+
+func TestFieldsInvalid(t *testing.T) {
+	for _, p := range []string{``, `[1]`, `{"a":}`, `{"a":1`, `{"a":[1,}`} {
+		if _, err := walker.Fields([]byte(p), &testVisitor{}); err == nil {
+			t.Errorf("Fields(%s) should fail", p)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	v := &testVisitor{}
+	got, err := walker.Append([]byte("x:"), []byte(`{"password":1,"n":[true,null]}`), "user", 1, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `x:{"password":"***","n":[true,null]}`; string(got) != want {
+		t.Errorf("invalid output:\ngot:  %s\nwant: %s", got, want)
+	}
+	if _, err = walker.Append(nil, []byte(`{"a":`), "", 0, v); err == nil {
+		t.Error("Append should fail on invalid JSON")
+	}
+}
+
+// limitVisitor 仅限制数组长度.
+type limitVisitor struct {
+	testVisitor
+	max int
+}
+
+func (v *limitVisitor) MaxItems(string) int { return v.max }
+
+func TestVisitors(t *testing.T) {
+	vs := Visitors{&limitVisitor{max: 3}, &testVisitor{}, &limitVisitor{max: 0}}
+	if got := vs.MaxItems("tags"); got != 2 {
+		t.Errorf("MaxItems = %d, want 2", got)
+	}
+	if got := vs.MaxItems("other"); got != 3 {
+		t.Errorf("MaxItems = %d, want 3", got)
+	}
+	if repl, ok := vs.Enter("user.password", 2, true); !ok || repl != "***" {
+		t.Errorf("Enter = %q %v, want *** true", repl, ok)
+	}
+	if got := vs.String("tags", "a"); got != "A" {
+		t.Errorf("String = %q, want A", got)
+	}
+}
+
+var benchEvent = []byte(`{"level":"info","time":"2021-01-02T03:04:05Z","user":{"id":42,"name":"gopher","roles":["admin","dev"]},` +
+	`"latency":1.234567,"path":"/api/v1/items","status":200,"message":"request done"}`)
+
+func BenchmarkFields(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := walker.Fields(benchEvent, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFieldsVisitor(b *testing.B) {
+	v := &limitVisitor{max: 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v.entered = v.entered[:0]
+		if _, err := walker.Fields(benchEvent, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Package requestid 生成cloghttp与cloggrpc共用的请求ID.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// New 返回16位十六进制的随机请求ID, 随机数不可用时以当前时间代替.
+func New() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// MaxLen 外部传入的请求ID最大长度.
+const MaxLen = 128
+
+// Valid 校验外部传入的请求ID: 非空, 不超过MaxLen, 仅包含字母 数字 与 - _ . : + / =.
+// 不合法的ID可能伪造日志内容或撑大每条日志, 应重新生成.
+func Valid(id string) bool {
+	if len(id) == 0 || len(id) > MaxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '+' || c == '/' || c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+// Package textutil 提供clog及各输出源共用的文本处理函数.
+package textutil
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Truncate 在UTF-8字符边界处截断s至不超过max字节,并追加截断标记 …(+N bytes).
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…(+" + strconv.Itoa(len(s)-n) + " bytes)"
+}
@@ -0,0 +1,48 @@
+package clog
+
+// lazyField 延迟计算的字段, fn与f二选一.
+type lazyField struct {
+	key string
+	fn  func() interface{}
+	f   func(e *Event)
+}
+
+// Func 添加延迟执行的函数, 在Msg时所有Hook执行完毕且事件未被丢弃后调用,
+// 适用于计算开销较大的字段. 事件为nil(等级未开启)或被Hook丢弃时f不会被调用.
+//
+//	Log.Debug().Func(func(e *clog.Event) { e.Interface("state", dump()) }).Msg("")
+//
+// NOTE:
+//
+//	延迟字段位于事件已有字段之后, message字段之前
+func (e *Event) Func(f func(e *Event)) *Event {
+	if e == nil || f == nil {
+		return e
+	}
+	e.lazy = append(e.lazy, lazyField{f: f})
+	return e
+}
+
+// Lazy 添加延迟计算的字段, fn的返回值在Msg时按Any编码, 调用时机与Func相同.
+//
+//	Log.Debug().Lazy("req", func() interface{} { return req.Dump() }).Msg("")
+func (e *Event) Lazy(key string, fn func() interface{}) *Event {
+	if e == nil || fn == nil {
+		return e
+	}
+	e.lazy = append(e.lazy, lazyField{key: key, fn: fn})
+	return e
+}
+
+// evalLazy 按添加顺序计算延迟字段.
+func (e *Event) evalLazy() {
+	// 延迟函数中可能继续添加延迟字段
+	for i := 0; i < len(e.lazy); i++ {
+		l := e.lazy[i]
+		if l.f != nil {
+			l.f(e)
+		} else {
+			e.Any(l.key, l.fn())
+		}
+	}
+}
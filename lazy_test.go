@@ -0,0 +1,48 @@
+package clog
+
+import (
+	"bytes"
+	"testing"
+)
+
+type discardHook struct{}
+
+func (discardHook) Run(e *Event, _ Level, msg string) {
+	if msg == "drop" {
+		e.Discard()
+	}
+}
+
+func TestEventLazy(t *testing.T) {
+	out := &bytes.Buffer{}
+	calls := 0
+	lazy := func() interface{} {
+		calls++
+		return map[string]int{"n": calls}
+	}
+	log := NewOption().WithWriter(out).WithLogLevel(InfoLevel).WithHook(discardHook{}).Logger()
+
+	log.Info().Lazy("state", lazy).Str("foo", "bar").Func(func(e *Event) {
+		e.Int("size", 3)
+	}).Msg("hi")
+	want := `{"level":"info","foo":"bar","state":{"n":1},"size":3,"message":"hi"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	out.Reset()
+	log.Debug().Lazy("state", lazy).Msg("disabled level")
+	log.Info().Lazy("state", lazy).Msg("drop")
+	if calls != 1 {
+		t.Errorf("lazy field evaluated for skipped events: %d calls", calls)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	log.Info().Dict("d", Dict().Lazy("state", lazy)).Msg("")
+	want = `{"level":"info","d":{"state":{"n":2}}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
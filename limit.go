@@ -0,0 +1,160 @@
+package clog
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cuckooemm/clog/internal/jsonfield"
+	"github.com/cuckooemm/clog/internal/textutil"
+)
+
+// truncatedFieldName 事件超出大小限制被截断时添加的标记字段.
+const truncatedFieldName = "_truncated"
+
+// OversizePolicy 事件超出MaxEventSize时的处理策略.
+type OversizePolicy uint8
+
+const (
+	// OversizeTruncate 从末尾起移除非必要字段直至满足大小限制,并添加 "_truncated":true.
+	// time level caller message 字段始终保留.
+	OversizeTruncate OversizePolicy = iota
+	// OversizeDrop 丢弃事件.
+	OversizeDrop
+	// OversizeMark 仅保留 time level caller message 字段并添加 "_truncated":true.
+	OversizeMark
+)
+
+// Limits 事件大小限制, 值为0表示不限制.
+type Limits struct {
+	MaxStringLen int // 字符串字段最大字节数,超出部分替换为 …(+N bytes)
+	MaxArrayLen  int // 数组最大元素数,超出部分替换为 …(+N items)
+	MaxDepth     int // Dict Object等嵌套对象与数组的最大层数,超出的值替换为 …(max depth)
+	MaxEventSize int // 单条事件最大字节数
+	OnOversize   OversizePolicy
+}
+
+// LimitStats 各项限制的触发次数.
+type LimitStats struct {
+	Strings  uint64 // 截断的字符串数
+	Arrays   uint64 // 截断的数组数
+	Depth    uint64 // 超出层数被替换的值数
+	Oversize uint64 // 超出MaxEventSize的事件数
+}
+
+// limiter 在Msg时按Limits处理事件, Logger未设置时为nil.
+type limiter struct {
+	Limits
+	visitor
+	strings  uint64
+	arrays   uint64
+	depth    uint64
+	oversize uint64
+}
+
+func newLimiter(l Limits) *limiter {
+	if l.MaxStringLen <= 0 && l.MaxArrayLen <= 0 && l.MaxDepth <= 0 && l.MaxEventSize <= 0 {
+		return nil
+	}
+	return &limiter{Limits: l}
+}
+
+func (l *limiter) stats() LimitStats {
+	if l == nil {
+		return LimitStats{}
+	}
+	return LimitStats{
+		Strings:  atomic.LoadUint64(&l.strings),
+		Arrays:   atomic.LoadUint64(&l.arrays),
+		Depth:    atomic.LoadUint64(&l.depth),
+		Oversize: atomic.LoadUint64(&l.oversize),
+	}
+}
+
+// walks 是否需要逐个检查字段值.
+func (l *limiter) walks() bool {
+	return l.MaxStringLen > 0 || l.MaxArrayLen > 0 || l.MaxDepth > 0
+}
+
+// exceeds 根据事件的结构概要判断是否可能超出字符串 数组或层数限制.
+func (l *limiter) exceeds(s jsonfield.Summary) bool {
+	return l.MaxStringLen > 0 && s.MaxString > l.MaxStringLen ||
+		l.MaxArrayLen > 0 && s.MaxItems > l.MaxArrayLen ||
+		l.MaxDepth > 0 && s.Depth > l.MaxDepth
+}
+
+// oversized 判断长度为n的未闭合事件是否超出MaxEventSize, 超出时计入统计.
+func (l *limiter) oversized(n int) bool {
+	// 结束符与换行符
+	if l.MaxEventSize <= 0 || n+2 <= l.MaxEventSize {
+		return false
+	}
+	atomic.AddUint64(&l.oversize, 1)
+	return true
+}
+
+// Enter 将超出MaxDepth的对象与数组替换为 …(max depth).
+func (l *limiter) Enter(_ string, depth int, container bool) (string, bool) {
+	if container && l.MaxDepth > 0 && depth > l.MaxDepth {
+		atomic.AddUint64(&l.depth, 1)
+		return "…(max depth)", true
+	}
+	return "", false
+}
+
+// String 截断超出MaxStringLen的字符串.
+func (l *limiter) String(_ string, s string) string {
+	if l.MaxStringLen > 0 && len(s) > l.MaxStringLen {
+		atomic.AddUint64(&l.strings, 1)
+		return textutil.Truncate(s, l.MaxStringLen)
+	}
+	return s
+}
+
+func (l *limiter) MaxItems(string) int {
+	return l.MaxArrayLen
+}
+
+// Dropped 以 …(+N items) 标记被移除的数组元素.
+func (l *limiter) Dropped(_ string, n int) (string, bool) {
+	atomic.AddUint64(&l.arrays, 1)
+	return "…(+" + strconv.Itoa(n) + " items)", true
+}
+
+// shrink 按OnOversize策略移除字段, 重新编码至buf.
+func (l *limiter) shrink(buf []byte, fields []jsonfield.Field, cfg *Config) []byte {
+	essential := func(key string) bool {
+		return key == cfg.TimestampFieldName || key == cfg.LevelFieldName || key == cfg.CallerFieldName || key == cfg.MessageFieldName
+	}
+	// 标记字段与结束符,换行符
+	size := len(truncatedFieldName) + 10
+	for _, f := range fields {
+		if essential(f.Key) {
+			size += len(f.Key) + len(f.Val) + 4
+		}
+	}
+	keep := make([]bool, len(fields))
+	full := l.OnOversize == OversizeMark
+	for i, f := range fields {
+		if essential(f.Key) {
+			keep[i] = true
+			continue
+		}
+		if full {
+			continue
+		}
+		if n := len(f.Key) + len(f.Val) + 4; size+n <= l.MaxEventSize {
+			keep[i] = true
+			size += n
+		} else {
+			// 保持字段顺序,超出后不再保留后续非必要字段
+			full = true
+		}
+	}
+	buf = trs.AppendBeginMarker(buf[:0])
+	for i, f := range fields {
+		if keep[i] {
+			buf = append(trs.AppendKey(buf, f.Key), f.Val...)
+		}
+	}
+	return trs.AppendBool(trs.AppendKey(buf, truncatedFieldName), true)
+}
@@ -0,0 +1,79 @@
+package clog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithLimits(Limits{MaxStringLen: 5, MaxArrayLen: 2, MaxDepth: 1}).Logger()
+	log.Log().
+		Str("s", "你好世界").
+		Ints("ids", []int{1, 2, 3, 4}).
+		Dict("d", Dict().Str("ok", "y").Dict("deep", Dict().Int("n", 1))).
+		Msg("")
+	want := `{"s":"你…(+9 bytes)","ids":[1,2,"…(+2 items)"],"d":{"ok":"y","deep":"…(max depth)"}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if got, want := log.LimitStats(), (LimitStats{Strings: 1, Arrays: 1, Depth: 1}); got != want {
+		t.Errorf("invalid limit stats: got %+v, want %+v", got, want)
+	}
+
+	// 未超出限制的事件原样输出, 转义后超出而解码后未超出的字符串不截断
+	out.Reset()
+	log.Log().Str("s", "a\tb").Ints("ids", []int{1, 2}).RawJSON("d", []byte(`{"x": 1}`)).Msg("")
+	want = `{"s":"a\tb","ids":[1,2],"d":{"x": 1}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	out.Reset()
+	log.Log().Str("s", "a\tbcd").RawJSON("d", []byte(`{"x": 1}`)).Msg("")
+	want = `{"s":"a\tbcd","d":{"x":1}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if got, want := log.LimitStats(), (LimitStats{Strings: 1, Arrays: 1, Depth: 1}); got != want {
+		t.Errorf("invalid limit stats: got %+v, want %+v", got, want)
+	}
+}
+
+func TestLimitsOversize(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	for _, tt := range []struct {
+		policy OversizePolicy
+		want   string
+	}{
+		{OversizeTruncate, `{"level":"info","a":"1","message":"hi","_truncated":true}` + "\n"},
+		{OversizeMark, `{"level":"info","message":"hi","_truncated":true}` + "\n"},
+		{OversizeDrop, ``},
+	} {
+		out := &bytes.Buffer{}
+		log := NewOption().WithWriter(out).WithLimits(Limits{MaxEventSize: 80, OnOversize: tt.policy}).Logger()
+		log.Info().Str("a", "1").Str("big", big).Str("b", "2").Msg("hi")
+		if got := out.String(); got != tt.want {
+			t.Errorf("policy %d invalid log output:\ngot:  %v\nwant: %v", tt.policy, got, tt.want)
+		}
+		if n := log.LimitStats().Oversize; n != 1 {
+			t.Errorf("invalid oversize count: %d", n)
+		}
+		// 未超出限制的事件不受影响
+		out.Reset()
+		log.Info().Msg("ok")
+		if got, want := out.String(), `{"level":"info","message":"ok"}`+"\n"; got != want {
+			t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+		}
+	}
+}
+
+func TestEventPoolLargeBuffer(t *testing.T) {
+	log := NewOption().WithWriter(&bytes.Buffer{}).Logger()
+	log.Log().Str("big", strings.Repeat("x", maxCap*2)).Msg("")
+	e := newEvent(nil, NoLevel)
+	defer putEvent(e)
+	if cap(e.buf) > maxCap {
+		t.Errorf("oversized buffer returned from pool: cap %d", cap(e.buf))
+	}
+}
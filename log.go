@@ -3,7 +3,6 @@ package clog
 import (
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 )
 
@@ -34,12 +33,20 @@ func (l Level) String() string {
 type Logger struct {
 	w       LevelWriter
 	level   Level
+	lvar    *LevelVar
 	preStr  []byte
 	preHook []Hook
 	hooks   []Hook
+	redact  *redactor
+	order   *fieldOrder
+	limit   *limiter
+	det     *determinism
+	cfg     *Config
 }
 
+// ParseLevel 按默认配置的LevelMarshalFunc解析等级字符串.
 func ParseLevel(levelStr string) (Level, error) {
+	levelFieldMarshalFunc := loadConfig().LevelMarshalFunc
 	switch levelStr {
 	case levelFieldMarshalFunc(TraceLevel):
 		return TraceLevel, nil
@@ -71,18 +78,47 @@ func (l *Logger) output(w io.Writer) {
 // ResetStrPrefix set prefix string
 func (l *Logger) ResetStrPrefix(key string, val interface{}) {
 	l.preStr = nil
-	l.preStr = trs.AppendInterface(append(trs.AppendString(l.preStr, key), ':'), val)
+	l.preStr = l.appendPrefix(l.preStr, key, val)
 }
 
 func (l *Logger) AppendStrPrefix(key string, val interface{}) {
 	if len(l.preStr) > 0 {
 		l.preStr = append(l.preStr, ',')
 	}
-	l.preStr = trs.AppendInterface(append(trs.AppendString(l.preStr, key), ':'), val)
+	l.preStr = l.appendPrefix(l.preStr, key, val)
+}
+
+func (l *Logger) appendPrefix(dst []byte, key string, val interface{}) []byte {
+	dst = append(trs.AppendString(dst, key), ':')
+	if l.redact == nil {
+		return trs.appendInterface(dst, val, l.config())
+	}
+	return l.redact.appendJSON(dst, key, trs.appendInterface(nil, val, l.config()))
+}
+
+// config 返回Logger的格式配置, 未通过options创建的Logger使用当前默认配置.
+func (l *Logger) config() *Config {
+	if l.cfg == nil {
+		return loadConfig()
+	}
+	return l.cfg
+}
+
+// Config 返回Logger的格式配置.
+func (l Logger) Config() Config {
+	return *l.config()
+}
+
+// LimitStats 返回WithLimits设置的各项限制的触发次数,由同一options创建的Logger共享计数.
+func (l Logger) LimitStats() LimitStats {
+	return l.limit.stats()
 }
 
 // GetLevel 返回当前实例的日志等级.
 func (l Logger) GetLevel() Level {
+	if l.lvar != nil {
+		return l.lvar.Level()
+	}
 	return l.level
 }
 
@@ -138,18 +174,27 @@ func (l *Logger) Err(err error) *Event {
 	return l.Info()
 }
 
-// Fatal 开启一个Fatal等级的日志事件. 调用Msg完成事件是同时调用 os.Exit(1)函数并退出程序.
+// Fatal 开启一个Fatal等级的日志事件. 调用Msg完成事件时刷新当前输出源并关闭所有已注册的输出源(最长等待
+// Set.ShutdownTimeout设置的时间), 随后调用 os.Exit(1) 退出程序. 退出函数可通过 Set.ExitFunc 替换.
 //
 // 必须调用Msg()方法完成此事件.
 func (l *Logger) Fatal() *Event {
-	return l.newEvent(FatalLevel, func(msg string) { os.Exit(1) })
+	return l.newEvent(FatalLevel, func(msg string) {
+		handle := l.config().handleError
+		flushWriter(l.w, handle)
+		shutdown(handle)
+		exit(1)
+	})
 }
 
-// Panic 开启一个Panic等级的日志事件,且在完成事件时调用panic.
+// Panic 开启一个Panic等级的日志事件,且在完成事件时刷新当前输出源并调用panic.
 //
 // 必须调用Msg()方法完成此事件.
 func (l *Logger) Panic() *Event {
-	return l.newEvent(PanicLevel, func(msg string) { panic(msg) })
+	return l.newEvent(PanicLevel, func(msg string) {
+		flushWriter(l.w, l.config().handleError)
+		panic(msg)
+	})
 }
 
 // WithLevel 根据传入的等级生成时间,如果传入panic,fatal等级,不会调用panic,os.exit等相关函数.
@@ -217,6 +262,11 @@ func (l *Logger) newEvent(level Level, done func(string)) *Event {
 		return nil
 	}
 	e := newEvent(l.w, level)
+	e.redact = l.redact
+	e.order = l.order
+	e.limit = l.limit
+	e.det = l.det
+	e.cfg = l.config()
 	if len(l.preStr) > 0 {
 		e.buf = append(e.buf, l.preStr...)
 	}
@@ -226,14 +276,14 @@ func (l *Logger) newEvent(level Level, done func(string)) *Event {
 	e.done = done
 	e.hook = l.hooks
 	if level != NoLevel {
-		e.Str(levelFieldName, levelFieldMarshalFunc(level))
+		e.Str(e.cfg.LevelFieldName, e.cfg.LevelMarshalFunc(level))
 	}
 	return e
 }
 
 // should 如果log等级小于实例等级或小于全局等级,则返回True.
 func (l Logger) should(lvl Level) bool {
-	if lvl < l.level || lvl < GlobalLevel() {
+	if lvl < l.GetLevel() || lvl < GlobalLevel() {
 		return false
 	}
 	return true
@@ -2,6 +2,7 @@ package clog
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -541,32 +542,31 @@ func TestErrorMarshalFunc(t *testing.T) {
 	out.Reset()
 
 	// test overriding the ErrorMarshalFunc
-	originalErrorMarshalFunc := errorMarshalFunc
-	defer func() {
-		errorMarshalFunc = originalErrorMarshalFunc
-	}()
-
-	errorMarshalFunc = func(err error) interface{} {
+	cfg := DefaultConfig()
+	cfg.ErrorMarshalFunc = func(err error) interface{} {
 		return err.Error() + ": marshaled string"
 	}
+	log = NewOption().WithWriter(out).WithConfig(cfg).Logger()
 	log.Log().Err(errors.New("err")).Msg("msg")
 	if got, want := out.String(), `{"error":"err: marshaled string","message":"msg"}`+"\n"; got != want {
 		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
 	}
 
 	out.Reset()
-	errorMarshalFunc = func(err error) interface{} {
+	cfg.ErrorMarshalFunc = func(err error) interface{} {
 		return errors.New(err.Error() + ": new error")
 	}
+	log = NewOption().WithWriter(out).WithConfig(cfg).Logger()
 	log.Log().Err(errors.New("err")).Msg("msg")
 	if got, want := out.String(), `{"error":"err: new error","message":"msg"}`+"\n"; got != want {
 		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
 	}
 
 	out.Reset()
-	errorMarshalFunc = func(err error) interface{} {
+	cfg.ErrorMarshalFunc = func(err error) interface{} {
 		return loggableError{err}
 	}
+	log = NewOption().WithWriter(out).WithConfig(cfg).Logger()
 	log.Log().Err(errors.New("err")).Msg("msg")
 	if got, want := out.String(), `{"error":{"message":"err: loggableError"},"message":"msg"}`+"\n"; got != want {
 		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
@@ -588,9 +588,8 @@ func TestCallerMarshalFunc(t *testing.T) {
 	out.Reset()
 
 	// test custom behavior. In this case we'll take just the last directory
-	origCallerMarshalFunc := callerMarshalFunc
-	defer func() { callerMarshalFunc = origCallerMarshalFunc }()
-	callerMarshalFunc = func(file string, line int) string {
+	cfg := DefaultConfig()
+	cfg.CallerMarshalFunc = func(file string, line int) string {
 		parts := strings.Split(file, "/")
 		if len(parts) > 1 {
 			return strings.Join(parts[len(parts)-2:], "/") + ":" + strconv.Itoa(line)
@@ -598,8 +597,9 @@ func TestCallerMarshalFunc(t *testing.T) {
 
 		return file + ":" + strconv.Itoa(line)
 	}
+	log = NewOption().WithWriter(out).WithConfig(cfg).Logger()
 	_, file, line, _ = runtime.Caller(0)
-	caller = callerMarshalFunc(file, line+2)
+	caller = cfg.CallerMarshalFunc(file, line+2)
 	log.Log().Caller().Msg("msg")
 	if got, want := out.String(), `{"caller":"`+caller+`","message":"msg"}`+"\n"; got != want {
 		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
@@ -607,15 +607,12 @@ func TestCallerMarshalFunc(t *testing.T) {
 }
 
 func TestLevelFieldMarshalFunc(t *testing.T) {
-	origLevelFieldMarshalFunc := levelFieldMarshalFunc
-	levelFieldMarshalFunc = func(l Level) string {
+	cfg := DefaultConfig()
+	cfg.LevelMarshalFunc = func(l Level) string {
 		return strings.ToUpper(l.String())
 	}
-	defer func() {
-		levelFieldMarshalFunc = origLevelFieldMarshalFunc
-	}()
 	out := &bytes.Buffer{}
-	log := NewOption().WithWriter(out).Logger()
+	log := NewOption().WithWriter(out).WithConfig(cfg).Logger()
 
 	log.Debug().Msg("test")
 	if got, want := out.String(), `{"level":"DEBUG","message":"test"}`+"\n"; got != want {
@@ -653,10 +650,11 @@ func (w errWriter) Write(_ []byte) (n int, err error) {
 func TestErrorHandler(t *testing.T) {
 	var got error
 	want := errors.New("write error")
-	errorHandler = func(err error) {
+	cfg := DefaultConfig()
+	cfg.ErrorHandler = func(err error) {
 		got = err
 	}
-	log := NewOption().WithWriter(errWriter{want}).Logger()
+	log := NewOption().WithWriter(errWriter{want}).WithConfig(cfg).Logger()
 	log.Log().Msg("test")
 	if got != want {
 		t.Errorf("ErrorHandler err = %#v, want %#v", got, want)
@@ -674,3 +672,314 @@ func (o obj) MarshalObject(e *Event) {
 		Str("Tag", o.Tag).
 		Int("priv", o.priv)
 }
+
+type ctxKey struct{}
+
+type ctxHook struct{}
+
+func (ctxHook) Run(e *Event, _ Level, _ string) {
+	if v, ok := e.GetCtx().Value(ctxKey{}).(string); ok {
+		e.Str("req", v)
+	}
+}
+
+func TestEventCtx(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithHook(ctxHook{}).Logger()
+	ctx := context.WithValue(context.Background(), ctxKey{}, "r1")
+	log.Log().Ctx(ctx).Msg("")
+	if got, want := out.String(), `{"req":"r1"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	out.Reset()
+	log.Log().Msg("")
+	if got, want := out.String(), "{}\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+type redactObj struct{}
+
+func (redactObj) MarshalObject(e *Event) {
+	e.Str("token", "t1").Str("email", "alice@example.com")
+}
+
+func TestRedact(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).
+		WithRedactKeys("password", "Authorization", "*.token").
+		WithRedactRules(RedactChinaID, RedactChinaPhone, RedactCreditCard, RedactEmail).
+		Logger()
+	log.AppendStrPrefix("user", map[string]string{"token": "t0", "phone": "13812341234"})
+	log.Log().
+		Str("password", "secret").
+		Str("authorization", "Bearer x").
+		Str("token", "visible").
+		Str("msg", "call 13812341234 or mail bob@example.com").
+		Strs("ids", []string{"110101199003071234", "4111 1111 1111 1111"}).
+		Fields(map[string]interface{}{"Password": 1, "card": "4111111111111111"}).
+		Interface("req", map[string]interface{}{"headers": map[string]string{"token": "abc"}, "n": 1}).
+		Dict("session", Dict().Str("token", "t2").Int("ttl", 60)).
+		Object("obj", redactObj{}).
+		Msg("")
+	want := `{"user":{"phone":"138****1234","token":"******"},"password":"******","authorization":"******",` +
+		`"token":"visible","msg":"call 138****1234 or mail b***@example.com",` +
+		`"ids":["110101********1234","***************1111"],"Password":"******","card":"************1111",` +
+		`"req":{"headers":{"token":"******"},"n":1},"session":{"token":"******","ttl":60},` +
+		`"obj":{"token":"******","email":"a***@example.com"}}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+type redactStringer string
+
+func (s redactStringer) String() string { return string(s) }
+
+func TestRedactAllFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).
+		WithRedactKeys("password", "secret", "*.token").
+		WithRedactRules(RedactChinaPhone).
+		Logger()
+	log.Log().
+		Stringer("s", redactStringer("13812341234")).
+		Bytes("b", []byte("13812341234")).
+		Any("password", []byte("x")).
+		Hex("secret", []byte("x")).
+		Array("arr", Arr().Str("13812341234").Object(redactObj{})).
+		RawJSON("raw", []byte(`{"password":"x","n":1}`)).
+		RawJSON("bad", []byte(`{"password":"x"`)).
+		Err(errors.New("phone 13812341234")).
+		Msg("")
+	want := `{"s":"138****1234","b":"138****1234","password":"******","secret":"******",` +
+		`"arr":["138****1234",{"token":"******","email":"alice@example.com"}],"raw":{"password":"******","n":1},"bad":"******",` +
+		`"error":"phone 138****1234"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestMaskPartial(t *testing.T) {
+	for _, tt := range []struct {
+		in, want   string
+		head, tail int
+	}{
+		{"13812341234", "138****1234", 3, 4},
+		{"abc", "***", 2, 2},
+		{"张三丰", "张*丰", 1, 1},
+	} {
+		if got := MaskPartial(tt.head, tt.tail)(tt.in); got != tt.want {
+			t.Errorf("MaskPartial(%d, %d)(%q) = %q, want %q", tt.head, tt.tail, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDupKeyPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy DupKeyPolicy
+		want   string
+	}{
+		{DupKeyAllow, `{"user":"p","user":"a","n":1,"user":"b"}`},
+		{DupKeyKeepFirst, `{"user":"p","n":1}`},
+		{DupKeyKeepLast, `{"user":"b","n":1}`},
+		{DupKeyRename, `{"user":"p","user_2":"a","n":1,"user_3":"b"}`},
+	} {
+		out := &bytes.Buffer{}
+		log := NewOption().WithWriter(out).WithDupKeyPolicy(tt.policy).Logger()
+		log.AppendStrPrefix("user", "p")
+		log.Log().Str("user", "a").Int("n", 1).Str("user", "b").Msg("")
+		if got := out.String(); got != tt.want+"\n" {
+			t.Errorf("policy %d invalid log output:\ngot:  %v\nwant: %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestDupKeyRenameCollision(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithDupKeyPolicy(DupKeyRename).Logger()
+	log.Log().Str("user", "a").Str("user_2", "x").Str("user", "b").Str("user_3", "y").Str("user", "c").Msg("")
+	want := `{"user":"a","user_2":"x","user_4":"b","user_3":"y","user_5":"c"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestLeadingKeys(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).WithLeadingKeys().Logger()
+	log.Info().Str("foo", "bar").Str("caller", "a.go:1").Str("time", "t").Msg("hi")
+	want := `{"time":"t","level":"info","caller":"a.go:1","message":"hi","foo":"bar"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	out.Reset()
+	log = NewOption().WithWriter(out).WithLeadingKeys("message", "id").WithDupKeyPolicy(DupKeyKeepLast).Logger()
+	log.Info().Int("id", 1).Str("foo", "bar").Int("id", 2).Msg("hi")
+	want = `{"message":"hi","id":2,"level":"info","foo":"bar"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	// 前置字段已在最前时不重新编码, 重复出现的前置字段仍需移动
+	out.Reset()
+	log = NewOption().WithWriter(out).WithLeadingKeys("a", "b").Logger()
+	log.Log().Int("a", 1).RawJSON("b", []byte(`{"x": 1}`)).Int("c", 3).Msg("")
+	log.Log().Int("a", 1).Int("b", 2).Int("a", 3).Msg("")
+	want = `{"a":1,"b":{"x": 1},"c":3}` + "\n" + `{"a":1,"a":3,"b":2}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+// BenchmarkRewrite Msg时按字段顺序 稳定输出与大小限制处理事件, 无需改写的事件只扫描不解析
+func BenchmarkRewrite(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		opt  *options
+	}{
+		{"none", NewOption()},
+		{"dedup", NewOption().WithDupKeyPolicy(DupKeyKeepLast)},
+		{"leading", NewOption().WithLeadingKeys()},
+		{"limits", NewOption().WithLimits(Limits{MaxStringLen: 64, MaxArrayLen: 8, MaxDepth: 4})},
+		{"limits/truncate", NewOption().WithLimits(Limits{MaxStringLen: 8})},
+		{"deterministic", NewOption().WithDeterministic(Deterministic{FloatDecimals: 2})},
+		{"deterministic/sort", NewOption().WithDeterministic(Deterministic{SortKeys: true})},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			log := bb.opt.WithWriter(discard{}).Logger()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				log.Info().Str("path", "/api/v1/items").Int("status", 200).Float64("latency", 1.234567).
+					Strs("roles", []string{"admin", "dev"}).Msg("request done")
+			}
+		})
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).Logger()
+	sub := log.With("request_id", "r1")
+	sub.Log().Msg("")
+	log.Log().Msg("")
+	if got, want := out.String(), `{"request_id":"r1"}`+"\n{}\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	out.Reset()
+	Ctx(sub.WithContext(context.Background())).Log().Msg("")
+	if got, want := out.String(), `{"request_id":"r1"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if Ctx(context.Background()) == nil {
+		t.Errorf("Ctx should never return nil")
+	}
+}
+
+func TestLevelVar(t *testing.T) {
+	out := &bytes.Buffer{}
+	lvl := NewLevelVar(WarnLevel)
+	log := NewOption().WithWriter(out).WithLevelVar(lvl).Logger()
+	child := log.With("k", "v")
+	log.Info().Msg("dropped")
+	lvl.Set(InfoLevel)
+	log.Info().Msg("a")
+	child.Info().Msg("b")
+	if got, want := out.String(), `{"level":"info","message":"a"}`+"\n"+`{"k":"v","level":"info","message":"b"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if got := log.GetLevel(); got != InfoLevel {
+		t.Errorf("GetLevel() = %v, want %v", got, InfoLevel)
+	}
+}
+
+// fieldHook 在事件中添加 key:true 字段.
+type fieldHook string
+
+func (h fieldHook) Run(e *Event, _ Level, _ string) {
+	e.Bool(string(h), true)
+}
+
+func TestOptionsPreHooks(t *testing.T) {
+	out := &bytes.Buffer{}
+	det := Deterministic{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	log := NewOption().WithWriter(out).WithDeterministic(det).
+		WithPreHook(fieldHook("a")).WithHook(fieldHook("post")).WithPreHook(fieldHook("b")).WithTimestamp().Logger()
+	log.Log().Msg("m")
+	if got, want := out.String(), `{"a":true,"b":true,"time":"2024-01-02T03:04:05Z","post":true,"message":"m"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestOptionsBuild(t *testing.T) {
+	out := &bytes.Buffer{}
+	log, err := NewOption().WithWriter(out).WithLogLevel(InfoLevel).WithRedactKeys("password").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Info().Str("password", "x").Msg("ok")
+	if got, want := out.String(), `{"level":"info","password":"******","message":"ok"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	_, err = NewOption().
+		WithLogLevel(Level(42)).
+		WithLevelVar(NewLevelVar(InfoLevel)).
+		WithRedactKeys("[").
+		WithLimits(Limits{MaxDepth: -1}).
+		WithLeadingKeys("").
+		WithHook(nil).
+		Build()
+	m, ok := err.(MultiError)
+	if !ok || len(m) != 6 {
+		t.Fatalf("expected 6 errors, got %v", err)
+	}
+	for _, want := range []string{"WithRedactKeys", "WithLimits", "WithLeadingKeys", "invalid level", "mutually exclusive", "nil hook"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	// 未设置输出源时输出至stderr
+	if log, err = NewOption().Build(); err != nil || log.w == nil {
+		t.Errorf("zero-config Build: %v", err)
+	}
+}
+
+func TestReplaceGlobals(t *testing.T) {
+	if GetLevel() != InfoLevel {
+		t.Errorf("default global level = %v, want %v", GetLevel(), InfoLevel)
+	}
+	out := &bytes.Buffer{}
+	log := NewOption().WithWriter(out).Logger()
+	undo := ReplaceGlobals(&log)
+	NewOption().WithWriter(out).WithLogLevel(WarnLevel).Default()
+	undoErr := ReplaceGlobals(nil)
+	NewOption().WithWriter(out).WithLogLevel(ErrorLevel).Default()
+	Warn().Msg("dropped")
+	Error().Msg("a")
+	undoErr()
+	Warn().Msg("b")
+	undo()
+	if got, want := out.String(), `{"level":"error","message":"a"}`+"\n"+`{"level":"warn","message":"b"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if GetLevel() != InfoLevel {
+		t.Errorf("restored global level = %v, want %v", GetLevel(), InfoLevel)
+	}
+
+	// 并发替换与输出
+	discard := NewOption().WithWriter(&bytes.Buffer{}).WithLogLevel(Disabled).Logger()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ReplaceGlobals(&discard)()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		Trace().Msg("")
+	}
+	<-done
+}
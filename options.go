@@ -1,11 +1,18 @@
 package clog
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Set 修改默认配置, 仅影响之后创建的Logger与不属于Logger的Dict Arr等.
+// 修改以原子替换的方式进行, 可与日志输出并发调用.
 var Set = new(setting)
 
 type setting struct{}
@@ -18,10 +25,18 @@ func NewOption() *options {
 type options struct {
 	w     LevelWriter
 	level Level
+	lvar  *LevelVar
 	// 回调函数
 	prefix   []byte
 	hooks    []Hook
 	preHooks []Hook
+	redact   *redactor
+	order    *fieldOrder
+	limit    *limiter
+	det      *determinism
+	cfg      *Config
+	levelSet bool
+	errs     []error // Build返回的配置错误
 }
 
 // WithHook 添加Hook函数
@@ -30,13 +45,13 @@ func (o *options) WithHook(hook ...Hook) *options {
 	return o
 }
 
-// WithPreHook 添加前置Hook函数,
+// WithPreHook 添加前置Hook函数, 在创建事件时按添加顺序执行
 func (o *options) WithPreHook(hook ...Hook) *options {
-	o.preHooks = append(o.hooks, hook...)
+	o.preHooks = append(o.preHooks, hook...)
 	return o
 }
 
-// WithWriter 为日志设置输出源
+// WithWriter 为日志设置输出源, 未设置或为nil时输出至stderr
 func (o *options) WithWriter(w io.Writer) *options {
 	if w == nil {
 		w = os.Stderr
@@ -52,34 +67,177 @@ func (o *options) WithWriter(w io.Writer) *options {
 // WithLogLevel 设置日志等级
 func (o *options) WithLogLevel(lvl Level) *options {
 	o.level = lvl
+	o.levelSet = true
+	return o
+}
+
+// WithLevelVar 使用可并发修改的日志等级, 设置后WithLogLevel无效.
+//
+//	lvl := clog.NewLevelVar(clog.InfoLevel)
+//	log := clog.NewOption().WithLevelVar(lvl).Logger()
+//	lvl.Set(clog.DebugLevel)
+func (o *options) WithLevelVar(v *LevelVar) *options {
+	o.lvar = v
+	return o
+}
+
+// WithRedactKeys 添加字段名脱敏规则,命中的字段值整体替换为 ******.
+// 规则按.分段匹配字段路径末尾(大小写不敏感),支持 * ? [] 通配符:
+//
+//	NewOption().WithRedactKeys("password", "authorization", "*.token")
+//
+// 规则作用于 Str Strs Stringer Bytes Hex RawJSON Fields Interface Dict Object Array Err 与 AppendStrPrefix 添加的字段,
+// Any按值的类型调用上述方法; Dict Object Array等复合值的字段路径为 key.子字段, 数组元素沿用数组的字段路径.
+// 数值 布尔 时间 IP等非字符串类型的字段不做脱敏. 复合值无法解析时整体替换为 ******.
+func (o *options) WithRedactKeys(patterns ...string) *options {
+	if len(patterns) == 0 {
+		return o
+	}
+	o.redact = o.redact.clone()
+	for _, p := range patterns {
+		if _, err := path.Match(strings.ToLower(p), ""); err != nil {
+			o.errs = append(o.errs, fmt.Errorf("clog: WithRedactKeys: invalid pattern %q: %w", p, err))
+		}
+		o.redact.keys = append(o.redact.keys, strings.Split(strings.ToLower(p), "."))
+	}
+	return o
+}
+
+// WithRedactRules 添加字段值脱敏规则,按添加顺序对上述字段中的字符串值依次替换, Hex字段的值不参与匹配:
+//
+//	NewOption().WithRedactRules(clog.RedactChinaID, clog.RedactChinaPhone, clog.RedactCreditCard, clog.RedactEmail)
+//
+// 未配置任何脱敏规则时不产生额外开销.
+func (o *options) WithRedactRules(rules ...RedactRule) *options {
+	if len(rules) == 0 {
+		return o
+	}
+	o.redact = o.redact.clone()
+	for _, rule := range rules {
+		if rule.Pattern == nil {
+			continue
+		}
+		if rule.Mask == nil {
+			rule.Mask = MaskAll
+		}
+		o.redact.rules = append(o.redact.rules, rule)
+	}
+	return o
+}
+
+// WithDupKeyPolicy 设置顶层字段名重复时的处理策略, 包括AppendStrPrefix添加的字段. 默认DupKeyAllow不检测
+func (o *options) WithDupKeyPolicy(p DupKeyPolicy) *options {
+	if p > DupKeyRename {
+		o.errs = append(o.errs, fmt.Errorf("clog: WithDupKeyPolicy: unknown policy %d", p))
+	}
+	o.order = o.order.clone()
+	o.order.dup = p
+	return o
+}
+
+// WithLeadingKeys 输出时将keys字段按顺序置于最前,与方法调用顺序无关. 未指定keys时为 time level caller message
+func (o *options) WithLeadingKeys(keys ...string) *options {
+	for _, key := range keys {
+		if len(key) == 0 {
+			o.errs = append(o.errs, errors.New("clog: WithLeadingKeys: empty key"))
+		}
+	}
+	o.order = o.order.clone()
+	o.order.sorted = true
+	o.order.leading = append([]string(nil), keys...)
+	return o
+}
+
+// WithLimits 设置事件大小限制, 在Msg时截断超长字符串,数组与嵌套层数, 事件超出MaxEventSize时按OnOversize处理.
+// 各项限制的触发次数通过Logger.LimitStats获取.
+//
+//	NewOption().WithLimits(clog.Limits{MaxStringLen: 4096, MaxArrayLen: 100, MaxDepth: 8, MaxEventSize: 64 << 10})
+func (o *options) WithLimits(l Limits) *options {
+	if l.MaxStringLen < 0 || l.MaxArrayLen < 0 || l.MaxDepth < 0 || l.MaxEventSize < 0 {
+		o.errs = append(o.errs, fmt.Errorf("clog: WithLimits: negative limit %+v", l))
+	}
+	if l.OnOversize > OversizeMark {
+		o.errs = append(o.errs, fmt.Errorf("clog: WithLimits: unknown OnOversize %d", l.OnOversize))
+	}
+	o.limit = newLimiter(l)
+	return o
+}
+
+// WithConfig 为Logger设置格式配置, 为空的字段名与方法使用默认配置.
+// 未设置时使用创建Logger时 clog.Set 设置的默认配置.
+func (o *options) WithConfig(c Config) *options {
+	o.cfg = c.normalize()
+	return o
+}
+
+// config 返回创建Logger时使用的格式配置.
+func (o *options) config() *Config {
+	if o.cfg != nil {
+		return o.cfg
+	}
+	return loadConfig()
+}
+
+// WithDeterministic 开启稳定输出: 固定或按序递增事件时间, Caller输出相对路径, 顶层字段排序, 浮点数舍入.
+// 用于golden文件与Example测试, 不应在生产环境使用.
+func (o *options) WithDeterministic(d Deterministic) *options {
+	o.det = newDeterminism(d)
 	return o
 }
 
 // WithTimestamp 添加前置TimestampHook函数
 func (o *options) WithTimestamp() *options {
-	o.preHooks = append(o.hooks, stp)
+	o.preHooks = append(o.preHooks, stp)
 	return o
 }
 
-// Default 生成全局实例 调用clog.Log*前需初始化全局实例
+// Default 以当前配置生成全局实例, 可重复调用, 每次调用替换之前的全局实例. 等同于 ReplaceGlobals.
+// 未调用时全局实例输出info及以上等级的日志至stderr.
 func (o *options) Default() {
-	if clog != nil {
-		panic("The default function can only be called once")
+	log := o.Logger()
+	ReplaceGlobals(&log)
+}
+
+// Build 校验配置并返回Logger, 存在错误时返回由各项错误组成的 MultiError.
+//
+//	log, err := clog.NewOption().WithWriter(w).WithLogLevel(clog.InfoLevel).Build()
+func (o *options) Build() (*Logger, error) {
+	errs := append(MultiError(nil), o.errs...)
+	if o.level < TraceLevel || o.level > Disabled {
+		errs = append(errs, fmt.Errorf("clog: WithLogLevel: invalid level %d", o.level))
+	}
+	if o.levelSet && o.lvar != nil {
+		errs = append(errs, errors.New("clog: WithLogLevel and WithLevelVar are mutually exclusive"))
+	}
+	for _, h := range append(append([]Hook(nil), o.preHooks...), o.hooks...) {
+		if h == nil {
+			errs = append(errs, errors.New("clog: nil hook"))
+			break
+		}
 	}
-	clog = new(Logger)
-	clog.hooks = append(clog.hooks, o.hooks...)
-	clog.preHook = append(clog.preHook, o.preHooks...)
-	clog.w = o.w
-	clog.level = o.level
-	clog.preStr = append(clog.preStr, o.prefix...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	log := o.Logger()
+	return &log, nil
 }
 
+// Logger 返回Logger, 不校验配置.
 func (o *options) Logger() Logger {
 	log := Logger{}
 	log.hooks = append(log.hooks, o.hooks...)
 	log.preHook = append(log.preHook, o.preHooks...)
 	log.w = o.w
+	if log.w == nil {
+		log.w = levelWriterAdapter{os.Stderr}
+	}
 	log.level = o.level
+	log.lvar = o.lvar
+	log.redact = o.redact
+	log.order = o.order.enabled()
+	log.limit = o.limit
+	log.det = o.det
+	log.cfg = o.config()
 	log.preStr = append(log.preStr, o.prefix...)
 	return log
 }
@@ -88,25 +246,25 @@ func (s setting) TimeFormat(layout string) setting {
 	if len(layout) == 0 {
 		return s
 	}
-	timeLayoutFormat = layout
+	updateConfig(func(c *Config) { c.TimeFormat = layout })
 	return s
 }
 func (s setting) ErrHandler(f func(err error)) setting {
-	errorHandler = f
+	updateConfig(func(c *Config) { c.ErrorHandler = f })
 	return s
 }
 func (s setting) ErrMarshalHandler(f func(err error) interface{}) setting {
 	if f == nil {
 		return s
 	}
-	errorMarshalFunc = f
+	updateConfig(func(c *Config) { c.ErrorMarshalFunc = f })
 	return s
 }
 func (s setting) LevelFieldToString(f func(l Level) string) setting {
 	if f == nil {
 		return s
 	}
-	levelFieldMarshalFunc = f
+	updateConfig(func(c *Config) { c.LevelMarshalFunc = f })
 	return s
 
 }
@@ -114,33 +272,67 @@ func (s setting) TimestampFunc(f func() time.Time) setting {
 	if f == nil {
 		return s
 	}
-	timestampFunc = f
+	updateConfig(func(c *Config) { c.TimestampFunc = f })
+	return s
+}
+
+// InterfaceMarshalFunc 设置Interface与Fields等方法对未命中快速路径的类型使用的序列化方法,默认json.Marshal.
+// 可替换为更快的JSON库, 如 jsoniter.ConfigCompatibleWithStandardLibrary.Marshal
+func (s setting) InterfaceMarshalFunc(f func(v interface{}) ([]byte, error)) setting {
+	if f == nil {
+		return s
+	}
+	updateConfig(func(c *Config) { c.InterfaceMarshalFunc = f })
+	return s
+}
+
+// InterfaceLogFormat 设置Interface与Fields等方法是否按日志格式编码 time.Time time.Duration error fmt.Stringer, 默认false与json.Marshal一致.
+func (s setting) InterfaceLogFormat(enable bool) setting {
+	updateConfig(func(c *Config) { c.InterfaceLogFormat = enable })
 	return s
 }
 func (s setting) ErrStackMarshal(f func(err error) interface{}) setting {
-	errorStackMarshal = f
+	updateConfig(func(c *Config) { c.ErrorStackMarshalFunc = f })
 	return s
 }
 func (s setting) BaseTimeDurationUnit(d time.Duration) setting {
 	if d == 0 {
 		return s
 	}
-	durationFieldUnit = d
+	updateConfig(func(c *Config) { c.DurationUnit = d })
 	return s
 }
 func (s setting) BaseTimeDurationInteger() setting {
-	durationFieldInteger = true
+	updateConfig(func(c *Config) { c.DurationInteger = true })
 	return s
 }
 func (s setting) CallMarshalFunc(f func(file string, line int) string) setting {
 	if f == nil {
 		return s
 	}
-	callerMarshalFunc = f
+	updateConfig(func(c *Config) { c.CallerMarshalFunc = f })
 	return s
 }
 func (s setting) CallerSkipFrameCount(n int) setting {
-	callerSkipFrameCount = n
+	updateConfig(func(c *Config) { c.CallerSkipFrameCount = n })
+	return s
+}
+
+// ExitFunc 设置Fatal与HandleSignals退出程序时调用的函数,默认os.Exit. 测试中可替换为不退出的函数
+func (s setting) ExitFunc(f func(code int)) setting {
+	if f == nil {
+		return s
+	}
+	exitFunc.Store(f)
+	return s
+}
+
+// ShutdownTimeout 设置Fatal与HandleSignals退出前等待关闭已注册输出源的最长时间,默认5s
+func (s setting) ShutdownTimeout(d time.Duration) setting {
+	if d <= 0 {
+		return s
+	}
+	atomic.StoreInt64(&shutdownTimeout, int64(d))
 	return s
 }
 
@@ -151,41 +343,41 @@ func (f field) LevelFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	levelFieldName = field
+	updateConfig(func(c *Config) { c.LevelFieldName = field })
 	return f
 }
 func (f field) TimestampFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	timestampFieldName = field
+	updateConfig(func(c *Config) { c.TimestampFieldName = field })
 	return f
 }
 func (f field) ErrorFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	errorFieldName = field
+	updateConfig(func(c *Config) { c.ErrorFieldName = field })
 	return f
 }
 func (f field) ErrStackFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	errorStackFieldName = field
+	updateConfig(func(c *Config) { c.ErrorStackFieldName = field })
 	return f
 }
 func (f field) MessageFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	messageFieldName = field
+	updateConfig(func(c *Config) { c.MessageFieldName = field })
 	return f
 }
 func (f field) CallerFieldName(field string) field {
 	if len(field) == 0 {
 		return f
 	}
-	callerFieldName = field
+	updateConfig(func(c *Config) { c.CallerFieldName = field })
 	return f
 }
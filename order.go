@@ -0,0 +1,174 @@
+package clog
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+// DupKeyPolicy 顶层字段名重复时的处理策略.
+type DupKeyPolicy uint8
+
+const (
+	// DupKeyAllow 不检测重复字段,默认策略.
+	DupKeyAllow DupKeyPolicy = iota
+	// DupKeyKeepFirst 保留第一次出现的字段.
+	DupKeyKeepFirst
+	// DupKeyKeepLast 保留最后一次出现的值,位置不变.
+	DupKeyKeepLast
+	// DupKeyRename 重复字段依次重命名为 key_2 key_3 ..., 跳过事件中已有的字段名
+	DupKeyRename
+)
+
+// fieldOrder 在Msg时重排顶层字段, Logger未开启时为nil.
+type fieldOrder struct {
+	dup     DupKeyPolicy
+	leading []string // 为空且sorted为true时使用默认的 time level caller message
+	sorted  bool
+}
+
+func (o *fieldOrder) clone() *fieldOrder {
+	if o == nil {
+		return new(fieldOrder)
+	}
+	c := *o
+	return &c
+}
+
+// enabled 未开启任何功能时返回nil,避免Msg时的额外开销.
+func (o *fieldOrder) enabled() *fieldOrder {
+	if o == nil || (o.dup == DupKeyAllow && !o.sorted) {
+		return nil
+	}
+	return o
+}
+
+// leadingKeys 返回需前置的字段名.
+func (o *fieldOrder) leadingKeys(cfg *Config) []string {
+	if len(o.leading) > 0 {
+		return o.leading
+	}
+	return []string{cfg.TimestampFieldName, cfg.LevelFieldName, cfg.CallerFieldName, cfg.MessageFieldName}
+}
+
+// changes 判断apply是否会改变事件的顶层字段, keys为未解码的字段名, escaped表示字段名包含转义字符.
+func (o *fieldOrder) changes(keys [][]byte, escaped bool, cfg *Config) bool {
+	if len(keys) < 2 {
+		return false
+	}
+	if escaped {
+		return true
+	}
+	if o.dup != DupKeyAllow {
+		// 字段较多时直接解析, 避免逐对比较
+		if len(keys) > 64 {
+			return true
+		}
+		for i := 1; i < len(keys); i++ {
+			for _, k := range keys[:i] {
+				if bytes.Equal(k, keys[i]) {
+					return true
+				}
+			}
+		}
+	}
+	if o.sorted {
+		// 前置字段需按指定顺序连续位于最前
+		leading := o.leadingKeys(cfg)
+		last, other := -1, false
+		for _, k := range keys {
+			pos := -1
+			for i, key := range leading {
+				if string(k) == key {
+					pos = i
+					break
+				}
+			}
+			if pos < 0 {
+				other = true
+				continue
+			}
+			if other || pos < last {
+				return true
+			}
+			last = pos
+		}
+	}
+	return false
+}
+
+// apply 按重复字段策略与前置字段顺序处理事件的顶层字段.
+func (o *fieldOrder) apply(fields []jsonfield.Field, cfg *Config) []jsonfield.Field {
+	if len(fields) < 2 {
+		return fields
+	}
+	if o.dup != DupKeyAllow {
+		fields = o.dedup(fields)
+	}
+	if o.sorted {
+		fields = o.reorder(fields, cfg)
+	}
+	return fields
+}
+
+func (o *fieldOrder) dedup(fields []jsonfield.Field) []jsonfield.Field {
+	index := make(map[string]int, len(fields))
+	// 重命名时跳过事件中已有的字段名, 包括之后才出现的字段
+	var taken map[string]struct{}
+	if o.dup == DupKeyRename {
+		taken = make(map[string]struct{}, len(fields))
+		for _, f := range fields {
+			taken[f.Key] = struct{}{}
+		}
+	}
+	out := fields[:0]
+	for _, f := range fields {
+		i, ok := index[f.Key]
+		if !ok {
+			index[f.Key] = len(out)
+			out = append(out, f)
+			continue
+		}
+		switch o.dup {
+		case DupKeyKeepLast:
+			out[i].Val = f.Val
+		case DupKeyRename:
+			for n := 2; ; n++ {
+				name := f.Key + "_" + strconv.Itoa(n)
+				if _, ok := taken[name]; !ok {
+					taken[name] = struct{}{}
+					out = append(out, jsonfield.Field{Key: name, Val: f.Val})
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// reorder 将前置字段按指定顺序移至最前,其余字段保持原有顺序.
+func (o *fieldOrder) reorder(fields []jsonfield.Field, cfg *Config) []jsonfield.Field {
+	leading := o.leadingKeys(cfg)
+	out := make([]jsonfield.Field, 0, len(fields))
+	for _, key := range leading {
+		for _, f := range fields {
+			if f.Key == key {
+				out = append(out, f)
+			}
+		}
+	}
+	for _, f := range fields {
+		lead := false
+		for _, key := range leading {
+			if f.Key == key {
+				lead = true
+				break
+			}
+		}
+		if !lead {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package clog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// RecoverOptions Recover配置, 零值可用.
+type RecoverOptions struct {
+	// Message 日志消息, 默认 panic recovered
+	Message string
+	// Frames 以 [{"func":"","file":"","line":0}] 形式的frames字段输出调用栈, 默认以stack字段输出 debug.Stack()
+	Frames bool
+	// RePanic 记录日志后重新panic
+	RePanic bool
+}
+
+// Recover 捕获panic并以ErrorLevel记录panic值, 调用栈, goroutine ID与panic发生位置,
+// 随后刷新Logger的输出源. 必须直接通过defer调用:
+//
+//	defer clog.Recover(&log, nil)
+//
+// l为nil时使用 clog.Ctx(nil) 返回的Logger.
+func Recover(l *Logger, opts *RecoverOptions) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	logPanic(l, opts, v)
+	if opts != nil && opts.RePanic {
+		panic(v)
+	}
+}
+
+// Go 在新的goroutine中执行fn, fn发生panic时按Recover记录日志且不再向上传递.
+//
+//	clog.Go(&log, func() { consume(ch) })
+func Go(l *Logger, fn func()) {
+	go func() {
+		defer Recover(l, nil)
+		fn()
+	}()
+}
+
+func logPanic(l *Logger, opts *RecoverOptions, v interface{}) {
+	if l == nil {
+		l = Ctx(nil)
+	}
+	if opts == nil {
+		opts = &RecoverOptions{}
+	}
+	msg := opts.Message
+	if len(msg) == 0 {
+		msg = "panic recovered"
+	}
+	stack := debug.Stack()
+	frames := panicFrames()
+	e := l.Error()
+	// runtime.Error等panic值按json.Marshal编码时丢失信息, 记录其字符串
+	switch p := v.(type) {
+	case error:
+		e = e.Str("panic", p.Error())
+	case fmt.Stringer:
+		e = e.Str("panic", p.String())
+	default:
+		e = e.Interface("panic", v)
+	}
+	e = e.Int64("goroutine", goroutineID(stack))
+	if len(frames) > 0 && e != nil {
+		e = e.appendCaller(frames[0].File, frames[0].Line)
+	}
+	if opts.Frames {
+		e = e.Array("frames", frames)
+	} else {
+		e = e.Str("stack", string(stack))
+	}
+	e.Msg(msg)
+	flushWriter(l.w, l.config().handleError)
+}
+
+// goroutineID 从 debug.Stack() 的首行 "goroutine 1 [running]:" 解析goroutine ID.
+func goroutineID(stack []byte) int64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		id, _ := strconv.ParseInt(string(stack[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
+
+type stackFrames []runtime.Frame
+
+// panicFrames 返回panic发生位置起的调用栈, 跳过Recover与runtime内部的帧.
+func panicFrames() stackFrames {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(3, pc)
+	it := runtime.CallersFrames(pc[:n])
+	var all stackFrames
+	start := -1
+	for {
+		f, more := it.Next()
+		if f.Function == "runtime.gopanic" {
+			start = len(all) + 1
+		}
+		all = append(all, f)
+		if !more {
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	all = all[start:]
+	// 跳过 runtime.panicIndex runtime.sigpanic 等
+	for len(all) > 0 && strings.HasPrefix(all[0].Function, "runtime.") {
+		all = all[1:]
+	}
+	return all
+}
+
+func (s stackFrames) MarshalArray(a *Array) {
+	for _, f := range s {
+		a.Object(stackFrame(f))
+	}
+}
+
+type stackFrame runtime.Frame
+
+func (f stackFrame) MarshalObject(e *Event) {
+	e.Str("func", f.Function).Str("file", f.File).Int("line", f.Line)
+}
+
+// flushWriter 刷新实现了Flusher的输出源, 包括SyncWriter MultiLevelWriter FilteredLevelWriter与LevelRouter包装的输出源, 错误交由handle处理.
+func flushWriter(w io.Writer, handle func(error)) {
+	switch w := w.(type) {
+	case levelWriterAdapter:
+		flushWriter(w.Writer, handle)
+	case *syncWriter:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		flushWriter(w.lw, handle)
+	case multiLevelWriter:
+		for _, lw := range w.writers {
+			flushWriter(lw, handle)
+		}
+	case filteredLevelWriter:
+		flushWriter(w.w, handle)
+	case *LevelRouter:
+		for _, route := range w.routes {
+			flushWriter(route.w, handle)
+		}
+	case Flusher:
+		if err := w.Flush(); err != nil {
+			handle(err)
+		}
+	}
+}
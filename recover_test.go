@@ -0,0 +1,132 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flushBuffer 记录Flush调用次数.
+type flushBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	flushed int
+}
+
+func (b *flushBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *flushBuffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushed++
+	return nil
+}
+
+func (b *flushBuffer) decode(t *testing.T) map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid log output %q: %v", b.buf.String(), err)
+	}
+	return m
+}
+
+func panicIndex(s []int) int {
+	return s[len(s)]
+}
+
+func TestRecover(t *testing.T) {
+	out := &flushBuffer{}
+	log := NewOption().WithWriter(out).Logger()
+	func() {
+		defer Recover(&log, &RecoverOptions{Message: "worker crashed", Frames: true})
+		panicIndex(nil)
+	}()
+
+	m := out.decode(t)
+	if m["level"] != "error" || m["message"] != "worker crashed" || !strings.Contains(m["panic"].(string), "index out of range") {
+		t.Errorf("invalid log output: %v", m)
+	}
+	if id, _ := m["goroutine"].(float64); id <= 0 {
+		t.Errorf("invalid goroutine id: %v", m["goroutine"])
+	}
+	if caller, _ := m["caller"].(string); !strings.Contains(caller, "recover_test.go") {
+		t.Errorf("invalid caller: %v", m["caller"])
+	}
+	frames, _ := m["frames"].([]interface{})
+	if len(frames) == 0 {
+		t.Fatalf("missing frames: %v", m)
+	}
+	if fn := frames[0].(map[string]interface{})["func"]; fn != "github.com/cuckooemm/clog.panicIndex" {
+		t.Errorf("invalid first frame:\ngot:  %v\nwant: %v", fn, "github.com/cuckooemm/clog.panicIndex")
+	}
+	if out.flushed != 1 {
+		t.Errorf("writer should be flushed once, got %d", out.flushed)
+	}
+}
+
+func TestRecoverRePanic(t *testing.T) {
+	out := &flushBuffer{}
+	log := NewOption().WithWriter(out).Logger()
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("invalid re-panic value:\ngot:  %v\nwant: %v", v, "boom")
+		}
+		m := out.decode(t)
+		if m["panic"] != "boom" || !strings.Contains(m["stack"].(string), "goroutine ") {
+			t.Errorf("invalid log output: %v", m)
+		}
+	}()
+	defer Recover(&log, &RecoverOptions{RePanic: true})
+	panic("boom")
+}
+
+func TestGo(t *testing.T) {
+	out := &flushBuffer{}
+	tee := NewTeeWriter().Sink("async", out).Async(16).Finish()
+	defer tee.Close()
+	log := NewOption().WithWriter(tee).Logger()
+	done := make(chan struct{})
+	Go(&log, func() {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+	// Recover在close(done)之后执行, 等待其刷新输出源
+	for i := 0; i < 1000; i++ {
+		out.mu.Lock()
+		flushed := out.flushed
+		out.mu.Unlock()
+		if flushed > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if m := out.decode(t); m["panic"] != "boom" {
+		t.Errorf("invalid log output: %v", m)
+	}
+}
+
+func TestRecoverFlushRouted(t *testing.T) {
+	api, debug, filtered := &flushBuffer{}, &flushBuffer{}, &flushBuffer{}
+	router := NewLevelRouter().Route(InfoLevel, PanicLevel, api).Route(TraceLevel, DebugLevel, debug).Finish()
+	log := NewOption().WithWriter(MultiLevelWriter(router, FilteredLevelWriter(ErrorLevel, PanicLevel, filtered))).Logger()
+	func() {
+		defer Recover(&log, nil)
+		panic("boom")
+	}()
+	for name, b := range map[string]*flushBuffer{"api": api, "debug": debug, "filtered": filtered} {
+		if b.flushed != 1 {
+			t.Errorf("%s writer should be flushed once, got %d", name, b.flushed)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package clog
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// redactedValue 命中字段名规则时替换的值.
+const redactedValue = "******"
+
+// RedactRule 值脱敏规则, 字符串值中匹配Pattern的部分替换为Mask的返回值.
+type RedactRule struct {
+	Pattern *regexp.Regexp
+	Mask    func(match string) string
+}
+
+var (
+	// RedactChinaID 18位居民身份证号,保留前6位与后4位
+	RedactChinaID = RedactRule{Pattern: regexp.MustCompile(`\b\d{17}[\dXx]\b`), Mask: MaskPartial(6, 4)}
+	// RedactChinaPhone 11位手机号,保留前3位与后4位: 138****1234
+	RedactChinaPhone = RedactRule{Pattern: regexp.MustCompile(`\b1[3-9]\d{9}\b`), Mask: MaskPartial(3, 4)}
+	// RedactCreditCard 13~19位银行卡号(允许空格或-分隔),保留后4位.
+	// 与RedactChinaID同时使用时应置于其后,避免身份证号被识别为卡号
+	RedactCreditCard = RedactRule{Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Mask: MaskPartial(0, 4)}
+	// RedactEmail 邮箱地址,保留用户名首字符与域名: a***@example.com
+	RedactEmail = RedactRule{Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), Mask: MaskEmail}
+)
+
+// MaskAll 将值整体替换为 ******
+func MaskAll(string) string {
+	return redactedValue
+}
+
+// MaskPartial 返回保留前head个与后tail个字符,其余字符替换为*的脱敏函数.
+// 值的长度不大于head+tail时整体替换.
+func MaskPartial(head, tail int) func(string) string {
+	return func(s string) string {
+		n := utf8.RuneCountInString(s)
+		if n <= head+tail {
+			return strings.Repeat("*", n)
+		}
+		var b strings.Builder
+		b.Grow(len(s))
+		i := 0
+		for _, r := range s {
+			if i < head || i >= n-tail {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('*')
+			}
+			i++
+		}
+		return b.String()
+	}
+}
+
+// MaskEmail 保留邮箱用户名首字符与域名.
+func MaskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return MaskAll(s)
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size] + "***" + s[at:]
+}
+
+// redactor 按字段名与字段值脱敏, Logger未配置规则时为nil.
+type redactor struct {
+	visitor
+	keys  [][]string // 小写的字段名规则,按.分段
+	rules []RedactRule
+}
+
+func (r *redactor) clone() *redactor {
+	if r == nil {
+		return new(redactor)
+	}
+	return &redactor{
+		keys:  append([][]string(nil), r.keys...),
+		rules: append([]RedactRule(nil), r.rules...),
+	}
+}
+
+// matchKey 判断字段路径是否命中字段名规则. 规则按.分段与路径末尾的同等段数逐段匹配(大小写不敏感),
+// 因此 password 命中任意层级的password字段, *.token 命中任意对象下的token字段.
+func (r *redactor) matchKey(key string) bool {
+	if len(r.keys) == 0 {
+		return false
+	}
+	segs := strings.Split(strings.ToLower(key), ".")
+	for _, pattern := range r.keys {
+		if len(pattern) > len(segs) {
+			continue
+		}
+		tail := segs[len(segs)-len(pattern):]
+		matched := true
+		for i, p := range pattern {
+			if ok, _ := path.Match(p, tail[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// value 按值规则脱敏字符串.
+func (r *redactor) value(s string) string {
+	for _, rule := range r.rules {
+		s = rule.Pattern.ReplaceAllStringFunc(s, rule.Mask)
+	}
+	return s
+}
+
+// str 返回key字段字符串值的脱敏结果.
+func (r *redactor) str(key, s string) string {
+	if r.matchKey(key) {
+		return redactedValue
+	}
+	return r.value(s)
+}
+
+// Enter 将命中字段名规则的值整体替换为 ******.
+func (r *redactor) Enter(path string, _ int, _ bool) (string, bool) {
+	if len(path) > 0 && r.matchKey(path) {
+		return redactedValue, true
+	}
+	return "", false
+}
+
+// String 按值规则脱敏字符串值.
+func (r *redactor) String(_ string, s string) string {
+	return r.value(s)
+}
+
+// appendJSON 将key字段的JSON值脱敏后追加至dst, key为空表示顶层对象.
+// raw不是合法的JSON时整体替换为 ******, 避免原值泄露.
+func (r *redactor) appendJSON(dst []byte, key string, raw []byte) []byte {
+	out, err := walker.Append(dst, raw, key, 0, r)
+	if err != nil {
+		return trs.AppendString(dst, redactedValue)
+	}
+	return out
+}
+
+// appendFields 将 {"k":v,... 形式的未闭合对象脱敏后,以字段列表的形式追加至dst, 解析失败时丢弃全部字段.
+func (r *redactor) appendFields(dst []byte, fields []byte) []byte {
+	obj := r.appendJSON(nil, "", trs.AppendEndMarker(fields))
+	if len(obj) <= 2 || obj[0] != '{' {
+		return dst
+	}
+	if dst[len(dst)-1] != '{' {
+		dst = append(dst, ',')
+	}
+	return append(dst, obj[1:len(obj)-1]...)
+}
+
+// RedactJSON 按Logger的脱敏规则处理key字段的JSON值, 未配置脱敏规则时原样返回raw.
+// 用于在截断等处理前先行脱敏, 如cloggrpc记录的请求与响应内容.
+func (l *Logger) RedactJSON(key string, raw []byte) []byte {
+	if l.redact == nil {
+		return raw
+	}
+	return l.redact.appendJSON(nil, key, raw)
+}
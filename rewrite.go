@@ -0,0 +1,90 @@
+package clog
+
+import (
+	"encoding/json"
+
+	"github.com/cuckooemm/clog/internal/jsonfield"
+)
+
+// walker 重新编码字段值, 字符串编码与事件其余部分一致.
+var walker = jsonfield.Walker{AppendString: trs.AppendString}
+
+// visitor jsonfield.Visitor的默认实现, 不做任何替换. 嵌入redactor limiter determinism后按需覆盖.
+type visitor struct{}
+
+func (visitor) Enter(string, int, bool) (string, bool) { return "", false }
+func (visitor) String(_ string, s string) string       { return s }
+func (visitor) Number(_ string, n json.Number) string  { return string(n) }
+func (visitor) MaxItems(string) int                    { return 0 }
+func (visitor) Dropped(string, int) (string, bool)     { return "", false }
+
+// rewrite 按字段顺序 稳定输出与大小限制重新编码未闭合的事件, 事件只解析一次. 返回false表示丢弃事件.
+func (e *Event) rewrite() bool {
+	var (
+		rounds = e.det != nil && e.det.FloatDecimals > 0
+		sorted = e.det != nil && e.det.SortKeys
+		limits = e.limit != nil && e.limit.walks()
+		fields []jsonfield.Field
+		parsed bool
+	)
+	if (e.order != nil || sorted || rounds || limits) && e.rewrites() {
+		var (
+			vs  jsonfield.Visitors
+			v   jsonfield.Visitor
+			err error
+		)
+		if rounds {
+			vs = append(vs, e.det)
+		}
+		if limits {
+			vs = append(vs, e.limit)
+		}
+		if len(vs) > 0 {
+			v = vs
+		}
+		// 解析失败时保留原事件
+		if fields, err = walker.Fields(trs.AppendEndMarker(e.buf), v); err == nil {
+			parsed = true
+			if e.order != nil {
+				fields = e.order.apply(fields, e.cfg)
+			}
+			if sorted {
+				e.det.sort(fields)
+			}
+			e.buf = trs.AppendBeginMarker(e.buf[:0])
+			for _, f := range fields {
+				e.buf = append(trs.AppendKey(e.buf, f.Key), f.Val...)
+			}
+		}
+	}
+	if e.limit == nil || !e.limit.oversized(len(e.buf)) {
+		return true
+	}
+	if e.limit.OnOversize == OversizeDrop {
+		return false
+	}
+	if !parsed {
+		var err error
+		if fields, err = walker.Fields(trs.AppendEndMarker(e.buf), nil); err != nil {
+			return true
+		}
+	}
+	e.buf = e.limit.shrink(e.buf, fields, e.cfg)
+	return true
+}
+
+// rewrites 不解码地扫描事件, 判断字段顺序 稳定输出与大小限制是否需要重新编码事件.
+func (e *Event) rewrites() bool {
+	s, keys, ok := jsonfield.Scan(trs.AppendEndMarker(e.buf), e.keys[:0])
+	e.keys = keys
+	if !ok {
+		return true
+	}
+	if e.order != nil && e.order.changes(keys, s.Escaped, e.cfg) {
+		return true
+	}
+	if e.det != nil && e.det.changes(keys, s) {
+		return true
+	}
+	return e.limit != nil && e.limit.exceeds(s)
+}
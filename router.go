@@ -0,0 +1,99 @@
+package clog
+
+import "io"
+
+// FilteredLevelWriter 返回仅写入等级在[min,max]区间内日志的LevelWriter,区间外的日志被丢弃且不返回错误.
+// 可与MultiLevelWriter组合使用:
+//
+//	clog.MultiLevelWriter(
+//		clog.FilteredLevelWriter(clog.InfoLevel, clog.PanicLevel, all),
+//		clog.FilteredLevelWriter(clog.ErrorLevel, clog.PanicLevel, errs),
+//	)
+//
+// NOTE:
+//
+//	Write()方法视为NoLevel等级写入
+func FilteredLevelWriter(min, max Level, w io.Writer) LevelWriter {
+	return filteredLevelWriter{min: min, max: max, w: toLevelWriter(w)}
+}
+
+type filteredLevelWriter struct {
+	min, max Level
+	w        LevelWriter
+}
+
+func (f filteredLevelWriter) Write(p []byte) (n int, err error) {
+	return f.WriteLevel(NoLevel, p)
+}
+
+func (f filteredLevelWriter) WriteLevel(l Level, p []byte) (n int, err error) {
+	if l < f.min || l > f.max {
+		return len(p), nil
+	}
+	return f.w.WriteLevel(l, p)
+}
+
+// LevelRouter 根据日志等级将日志分发至不同的输出源,一条日志会写入所有等级区间匹配的输出源.
+// 通过NewLevelRouter()构建.
+type LevelRouter struct {
+	routes []filteredLevelWriter
+}
+
+type levelRouterOption struct {
+	router *LevelRouter
+}
+
+// NewLevelRouter 构建LevelRouter
+//
+//	router := clog.NewLevelRouter().
+//		Route(clog.InfoLevel, clog.PanicLevel, api).
+//		Route(clog.ErrorLevel, clog.PanicLevel, apiErr).
+//		Route(clog.TraceLevel, clog.DebugLevel, debug).
+//		Finish()
+func NewLevelRouter() *levelRouterOption {
+	return &levelRouterOption{router: new(LevelRouter)}
+}
+
+// Route 添加等级区间为[min,max]的输出源
+func (o *levelRouterOption) Route(min, max Level, w io.Writer) *levelRouterOption {
+	if w == nil {
+		return o
+	}
+	o.router.routes = append(o.router.routes, filteredLevelWriter{min: min, max: max, w: toLevelWriter(w)})
+	return o
+}
+
+// Above 添加等级不低于min的输出源,等同于Route(min, PanicLevel, w)
+func (o *levelRouterOption) Above(min Level, w io.Writer) *levelRouterOption {
+	return o.Route(min, PanicLevel, w)
+}
+
+// Finish 返回LevelRouter实例
+func (o *levelRouterOption) Finish() *LevelRouter {
+	return o.router
+}
+
+// Write 实现 io.Writer 接口,视为NoLevel等级写入.
+func (r *LevelRouter) Write(p []byte) (n int, err error) {
+	return r.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel 实现 LevelWriter 接口. 单个输出源写入失败不影响其余输出源,返回第一个遇到的错误.
+func (r *LevelRouter) WriteLevel(l Level, p []byte) (n int, err error) {
+	for _, route := range r.routes {
+		if l < route.min || l > route.max {
+			continue
+		}
+		wn, werr := route.w.WriteLevel(l, p)
+		if werr == nil && wn != len(p) {
+			werr = io.ErrShortWrite
+		}
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
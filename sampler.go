@@ -1,48 +1,83 @@
 package clog
 
-var clog *Logger
+import (
+	"os"
+	"sync/atomic"
+)
+
+// global 全局实例, 未调用Default或ReplaceGlobals时输出info及以上等级的日志至stderr.
+var global atomic.Value // *Logger
+
+func init() {
+	global.Store(newStdLogger())
+}
+
+// newStdLogger 返回零配置的全局实例, 格式配置跟随 clog.Set.
+func newStdLogger() *Logger {
+	return &Logger{w: levelWriterAdapter{os.Stderr}, level: InfoLevel}
+}
+
+// std 返回全局实例.
+func std() *Logger {
+	return global.Load().(*Logger)
+}
+
+// ReplaceGlobals 将l设置为全局实例, l为nil时恢复为零配置的全局实例. 返回恢复为替换前全局实例的函数.
+// 可与日志输出并发调用, 也可多次调用.
+//
+//	undo := clog.ReplaceGlobals(&log)
+//	defer undo()
+func ReplaceGlobals(l *Logger) (undo func()) {
+	if l == nil {
+		l = newStdLogger()
+	}
+	prev := global.Swap(l)
+	return func() {
+		global.Store(prev)
+	}
+}
 
 // Err starts a new message with error level with err as a field if not nil or
 // with info level if err is nil.
 //
 // You must call Msg on the returned event in order to send the event.
 func Err(err error) *Event {
-	return clog.Err(err)
+	return std().Err(err)
 }
 
 // Trace starts a new message with trace level.
 //
 // You must call Msg on the returned event in order to send the event.
 func Trace() *Event {
-	return clog.Trace()
+	return std().Trace()
 }
 
 // Debug starts a new message with debug level.
 //
 // You must call Msg on the returned event in order to send the event.
 func Debug() *Event {
-	return clog.Debug()
+	return std().Debug()
 }
 
 // Info starts a new message with info level.
 //
 // You must call Msg on the returned event in order to send the event.
 func Info() *Event {
-	return clog.Info()
+	return std().Info()
 }
 
 // Warn starts a new message with warn level.
 //
 // You must call Msg on the returned event in order to send the event.
 func Warn() *Event {
-	return clog.Warn()
+	return std().Warn()
 }
 
 // Error starts a new message with error level.
 //
 // You must call Msg on the returned event in order to send the event.
 func Error() *Event {
-	return clog.Error()
+	return std().Error()
 }
 
 // Fatal starts a new message with fatal level. The os.Exit(1) function
@@ -50,7 +85,7 @@ func Error() *Event {
 //
 // You must call Msg on the returned event in order to send the event.
 func Fatal() *Event {
-	return clog.Fatal()
+	return std().Fatal()
 }
 
 // Panic starts a new message with panic level. The message is also sent
@@ -58,7 +93,7 @@ func Fatal() *Event {
 //
 // You must call Msg on the returned event in order to send the event.
 func Panic() *Event {
-	return clog.Panic()
+	return std().Panic()
 }
 
 // Log starts a new message with no level. Setting clog.GlobalLevel to
@@ -66,43 +101,26 @@ func Panic() *Event {
 //
 // You must call Msg on the returned event in order to send the event.
 func Log() *Event {
-	return clog.Log()
+	return std().Log()
 }
 
 // Print sends a log event using debug level and no extra field.
 // Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
-	clog.Print(v...)
+	std().Print(v...)
 }
 
 // Printf sends a log event using debug level and no extra field.
 // Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, v ...interface{}) {
-	clog.Printf(format, v...)
+	std().Printf(format, v...)
 }
 
 func GetLevel() Level {
-	return clog.level
+	return std().GetLevel()
 }
 
 // 获取副本 继承默认Logger 的配置
 func CopyDefault() *Logger {
-	l := &Logger{
-		w:     clog.w,
-		level: clog.level,
-	}
-	if len(clog.preStr) > 0 {
-		l.preStr = make([]byte, len(clog.preStr))
-		copy(l.preStr, clog.preStr)
-	}
-	if len(clog.preHook) > 0 {
-		l.preHook = make([]Hook, len(clog.preHook))
-		copy(l.preHook, clog.preHook)
-	}
-	if len(clog.hooks) > 0 {
-		l.hooks = make([]Hook, len(clog.hooks))
-		copy(l.hooks, clog.hooks)
-	}
-
-	return l
+	return std().clone()
 }
@@ -0,0 +1,194 @@
+package clog
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Flusher 由缓冲或异步输出源实现, 将已写入的日志提交至最终目的地.
+type Flusher interface {
+	Flush() error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []io.Writer
+
+	// exitFunc Fatal与HandleSignals退出程序时调用的func(code int), 未设置时为os.Exit
+	exitFunc atomic.Value
+	// shutdownTimeout Fatal与HandleSignals退出前等待Close的最长时间, 单位纳秒
+	shutdownTimeout = int64(5 * time.Second)
+)
+
+// exit 调用Set.ExitFunc设置的退出函数.
+func exit(code int) {
+	if f, ok := exitFunc.Load().(func(int)); ok {
+		f(code)
+		return
+	}
+	os.Exit(code)
+}
+
+// closer 由Close方法无返回值的输出源实现, 如 storage.SizeRotate 与 storage.TimeRotate.
+type closer interface {
+	Close()
+}
+
+// Register 注册实现了 io.Closer Close() 或 Flusher 的输出源, 由Flush Close及Fatal统一处理, 返回取消注册的函数.
+// 输出源不会自动注册, 需要在退出前刷新或关闭的输出源应在创建后显式注册. 重复注册同一输出源
+// 及注册标准输出与标准错误无效.
+//
+//	w := storage.NewSizeSplitFile("app.log").Finish()
+//	defer clog.Register(w)()
+func Register(w io.Writer) (unregister func()) {
+	_, ioCloser := w.(io.Closer)
+	_, noErrCloser := w.(closer)
+	_, flusher := w.(Flusher)
+	if !ioCloser && !noErrCloser && !flusher || w == os.Stdout || w == os.Stderr {
+		return func() {}
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if !registered(w) {
+		registry = append(registry, w)
+	}
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		for i, r := range registry {
+			if sameWriter(r, w) {
+				registry = append(registry[:i], registry[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func registered(w io.Writer) bool {
+	for _, r := range registry {
+		if sameWriter(r, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameWriter 比较输出源, 不可比较的类型视为不同.
+func sameWriter(a, b io.Writer) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// Flush 刷新所有已注册的 Flusher, ctx结束时返回ctx.Err(), 未完成的刷新在后台继续执行.
+func Flush(ctx context.Context) error {
+	return runWithContext(ctx, flushAll)
+}
+
+// Close 刷新并关闭所有已注册的输出源, 随后清空注册列表. 仅实现了Flusher的输出源只刷新.
+//
+//	defer clog.Close()
+func Close() error {
+	registryMu.Lock()
+	writers := registry
+	registry = nil
+	registryMu.Unlock()
+	var errs MultiError
+	for _, w := range writers {
+		if f, ok := w.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		switch c := w.(type) {
+		case io.Closer:
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		case closer:
+			c.Close()
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func flushAll() error {
+	registryMu.Lock()
+	writers := append([]io.Writer(nil), registry...)
+	registryMu.Unlock()
+	var errs MultiError
+	for _, w := range writers {
+		if f, ok := w.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func runWithContext(ctx context.Context, f func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown 在shutdownTimeout内关闭所有已注册的输出源, 错误交由handle处理.
+func shutdown(handle func(error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(atomic.LoadInt64(&shutdownTimeout)))
+	defer cancel()
+	if err := runWithContext(ctx, Close); err != nil {
+		handle(err)
+	}
+}
+
+// HandleSignals 收到指定信号(默认 SIGTERM 与 os.Interrupt)时关闭所有已注册的输出源并以 128+信号值 退出程序,
+// 返回停止监听的函数.
+//
+//	defer clog.HandleSignals()()
+func HandleSignals(sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	ch := make(chan os.Signal, 1)
+	quit := make(chan struct{})
+	signal.Notify(ch, sig...)
+	go func() {
+		select {
+		case s := <-ch:
+			shutdown(HandleError)
+			code := 1
+			if n, ok := s.(syscall.Signal); ok {
+				code = 128 + int(n)
+			}
+			exit(code)
+		case <-quit:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(quit)
+		})
+	}
+}
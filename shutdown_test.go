@@ -0,0 +1,156 @@
+package clog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// closeBuffer 记录Flush与Close调用.
+type closeBuffer struct {
+	flushBuffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+type blockFlusher struct {
+	flushBuffer
+	release chan struct{}
+}
+
+func (b *blockFlusher) Flush() error {
+	<-b.release
+	return nil
+}
+
+func resetShutdown(t *testing.T) {
+	registryMu.Lock()
+	registry = nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = nil
+		registryMu.Unlock()
+		exitFunc.Store(os.Exit)
+	})
+}
+
+// plainCloser 的Close方法无返回值, 同storage.SizeRotate.
+type plainCloser struct {
+	flushBuffer
+	closed bool
+}
+
+func (b *plainCloser) Close() {
+	b.closed = true
+}
+
+func TestRegistryClose(t *testing.T) {
+	resetShutdown(t)
+	a, b, c := &closeBuffer{}, &flushBuffer{}, &plainCloser{}
+	NewOption().WithWriter(a).Logger()
+	if len(registry) != 0 {
+		t.Fatal("WithWriter should not register the writer")
+	}
+	Register(a)
+	Register(a)
+	Register(b)
+	Register(c)
+	Register(os.Stderr)
+	if len(registry) != 3 {
+		t.Fatalf("invalid registry size:\ngot:  %v\nwant: %v", len(registry), 3)
+	}
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.flushed != 1 || b.flushed != 1 {
+		t.Errorf("invalid flush count: %d %d", a.flushed, b.flushed)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.closed || !c.closed || a.flushed != 2 || b.flushed != 2 || len(registry) != 0 {
+		t.Errorf("invalid close state: closed=%v,%v flushed=%d,%d registry=%d", a.closed, c.closed, a.flushed, b.flushed, len(registry))
+	}
+
+	unregister := Register(a)
+	unregister()
+	if len(registry) != 0 {
+		t.Errorf("writer should be unregistered")
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	resetShutdown(t)
+	w := &blockFlusher{release: make(chan struct{})}
+	defer close(w.release)
+	Register(w)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("invalid flush error:\ngot:  %v\nwant: %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFatalShutdown(t *testing.T) {
+	resetShutdown(t)
+	code := -1
+	Set.ExitFunc(func(c int) { code = c })
+	out, other := &closeBuffer{}, &closeBuffer{}
+	Register(out)
+	log := NewOption().WithWriter(out).Logger()
+	NewOption().WithWriter(other).Logger()
+	log.Fatal().Msg("bye")
+	if code != 1 {
+		t.Errorf("invalid exit code:\ngot:  %v\nwant: %v", code, 1)
+	}
+	if got, want := out.buf.String(), `{"level":"fatal","message":"bye"}`+"\n"; got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if !out.closed || out.flushed == 0 {
+		t.Errorf("writer should be flushed and closed before exit")
+	}
+	if other.closed {
+		t.Errorf("unregistered writer of another logger should not be closed")
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported on windows")
+	}
+	resetShutdown(t)
+	exited := make(chan int, 1)
+	Set.ExitFunc(func(c int) { exited <- c })
+	out := &closeBuffer{}
+	Register(out)
+	stop := HandleSignals(syscall.SIGHUP)
+	defer stop()
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exited:
+		if code != 128+int(syscall.SIGHUP) {
+			t.Errorf("invalid exit code: %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("signal not handled")
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if !out.closed {
+		t.Errorf("writer should be closed")
+	}
+}
@@ -73,6 +73,7 @@ func (t *timeRotateOption) Finish() *TimeRotate {
 		panic(err)
 	}
 	t.timeRotate.ch = make(chan struct{}, 1)
+	t.timeRotate.done = make(chan struct{})
 	go t.timeRotate.whileRun()
 	return t.timeRotate
 }
@@ -132,6 +133,7 @@ func (o *sizeRotateOption) Backups(total int) *sizeRotateOption {
 func (o *sizeRotateOption) Finish() *SizeRotate {
 	if o.sizeRotate.saveDay > 0 || o.sizeRotate.compress || o.sizeRotate.maxBackups > 0 {
 		o.sizeRotate.millCh = make(chan struct{}, 1)
+		o.sizeRotate.millDone = make(chan struct{})
 		go o.sizeRotate.millRun()
 		o.sizeRotate.mill()
 	}
@@ -0,0 +1,358 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrQueueFull 磁盘队列达到容量上限时返回.
+var ErrQueueFull = errors.New("clog: disk queue is full")
+
+// firstSegment 新建队列的首个分段序号, 更小的序号留给WriteFront写入队列头部.
+const firstSegment uint64 = 1 << 32
+
+// Queue 按行存储的磁盘队列,用于输出源不可用时暂存日志,恢复后按写入顺序回放.
+// 数据按序号分段存储为 name.00000000000000000001 形式的文件,已回放的分段文件会被删除.
+//
+// NOTE:
+//
+//	读取位置仅保存在内存中,进程重启后未删除的分段会从头回放,因此可能重复投递
+type Queue struct {
+	dirPath     string
+	name        string
+	segmentSize int64 // 分段文件大小上限
+	maxSize     int64 // 队列总大小上限
+	size        int64 // 未回放的完整行大小
+
+	mu       sync.Mutex
+	segments []segment // 现存分段,按序号升序
+	fd       *os.File  // 当前写入分段
+	tailSize int64
+	head     *os.File // 当前读取分段
+	reader   *bufio.Reader
+}
+
+type segment struct {
+	seq    uint64
+	size   int64 // 未回放的完整行大小
+	offset int64 // 已回放的字节数
+}
+
+type queueOption struct {
+	queue *Queue
+}
+
+// NewQueue 构建磁盘队列, path为分段文件路径前缀. 目录下已存在的分段会在Finish时加载并等待回放.
+func NewQueue(path string) *queueOption {
+	o := &queueOption{queue: &Queue{
+		dirPath:     filepath.Dir(path),
+		name:        filepath.Base(path),
+		segmentSize: 16 << 20,
+	}}
+	if err := os.MkdirAll(o.queue.dirPath, 0755); err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// SegmentSize 设置单个分段文件大小上限,单位Mb,默认16Mb
+func (o *queueOption) SegmentSize(m int) *queueOption {
+	if m > 0 {
+		o.queue.segmentSize = int64(m) << 20
+	}
+	return o
+}
+
+// MaxSize 设置队列总大小上限,单位Mb,达到上限后写入返回ErrQueueFull. 默认不限制
+func (o *queueOption) MaxSize(m int) *queueOption {
+	o.queue.maxSize = int64(m) << 20
+	return o
+}
+
+// Finish 返回Queue实例
+func (o *queueOption) Finish() *Queue {
+	if err := o.queue.load(); err != nil {
+		panic(err)
+	}
+	return o.queue
+}
+
+// Write 追加一行数据到队列尾部, p未以换行符结尾时自动添加.
+func (q *Queue) Write(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	line := p
+	if p[len(p)-1] != '\n' {
+		line = append(p[:len(p):len(p)], '\n')
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxSize > 0 && q.size+int64(len(line)) > q.maxSize {
+		return 0, ErrQueueFull
+	}
+	if q.fd == nil || q.tailSize+int64(len(line)) > q.segmentSize {
+		if err = q.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	if n, err = q.fd.Write(line); err != nil {
+		// 丢弃写入了一部分的行, 避免之后写入的数据与其拼接
+		if n > 0 {
+			_ = q.fd.Truncate(q.tailSize)
+		}
+		return 0, err
+	}
+	q.tailSize += int64(n)
+	q.size += int64(n)
+	q.segments[len(q.segments)-1].size += int64(n)
+	return len(p), nil
+}
+
+// WriteFront 将lines写入新的分段并置于队列头部, 在已有数据之前回放. lines未以换行符结尾时自动添加.
+func (q *Queue) WriteFront(lines [][]byte) error {
+	var buf []byte
+	for _, p := range lines {
+		if len(p) == 0 {
+			continue
+		}
+		buf = append(buf, p...)
+		if p[len(p)-1] != '\n' {
+			buf = append(buf, '\n')
+		}
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxSize > 0 && q.size+int64(len(buf)) > q.maxSize {
+		return ErrQueueFull
+	}
+	seq := firstSegment
+	if len(q.segments) > 0 {
+		if seq = q.segments[0].seq - 1; seq == 0 {
+			return ErrQueueFull
+		}
+	}
+	path := q.segmentPath(seq)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("can't write queue segment: %s", err)
+	}
+	// 读取分段改变, 下次读取时打开新的头部分段
+	if q.head != nil {
+		_ = q.head.Close()
+		q.head, q.reader = nil, nil
+	}
+	q.segments = append([]segment{{seq: seq, size: int64(len(buf))}}, q.segments...)
+	q.size += int64(len(buf))
+	return nil
+}
+
+// Len 返回队列中未回放的数据大小.
+func (q *Queue) Len() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Drain 按写入顺序回放队列中的数据, fn返回错误时停止回放且该行保留在队列中.
+// 回放期间可并发写入,新写入的数据会在同一次调用中回放.
+func (q *Queue) Drain(fn func(line []byte) error) error {
+	for {
+		line, err := q.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(line); err != nil {
+			q.rewind()
+			return err
+		}
+		q.commit(int64(len(line)))
+	}
+}
+
+// Close 关闭队列,未回放的数据保留在磁盘中.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var err error
+	if q.head != nil {
+		err = q.head.Close()
+		q.head, q.reader = nil, nil
+	}
+	if q.fd != nil {
+		if serr := q.fd.Sync(); serr != nil && err == nil {
+			err = serr
+		}
+		if cerr := q.fd.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		q.fd = nil
+	}
+	return err
+}
+
+// next 读取下一行数据,不移动已回放位置.
+func (q *Queue) next() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.segments) > 0 {
+		if q.head == nil {
+			f, err := os.Open(q.segmentPath(q.segments[0].seq))
+			if err != nil {
+				return nil, err
+			}
+			if _, err = f.Seek(q.segments[0].offset, io.SeekStart); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			q.head, q.reader = f, bufio.NewReader(f)
+		}
+		line, err := q.reader.ReadBytes('\n')
+		if err == nil {
+			return line, nil
+		}
+		if err != io.EOF {
+			return nil, err
+		}
+		// 写入在持有锁时完成, 读到末尾时分段中的完整行均已回放, 不完整的行不会再被补全
+		q.removeHead()
+	}
+	return nil, io.EOF
+}
+
+func (q *Queue) commit(n int64) {
+	q.mu.Lock()
+	q.segments[0].offset += n
+	q.segments[0].size -= n
+	q.size -= n
+	q.mu.Unlock()
+}
+
+// rewind 将读取位置重置到最后一次回放成功的位置.
+func (q *Queue) rewind() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.head == nil {
+		return
+	}
+	if _, err := q.head.Seek(q.segments[0].offset, io.SeekStart); err != nil {
+		_ = q.head.Close()
+		q.head, q.reader = nil, nil
+		return
+	}
+	q.reader.Reset(q.head)
+}
+
+// removeHead 删除已回放完毕的读取分段, 未回放的计数一并扣除.
+func (q *Queue) removeHead() {
+	if q.head != nil {
+		_ = q.head.Close()
+	}
+	path := q.segmentPath(q.segments[0].seq)
+	if len(q.segments) == 1 && q.fd != nil {
+		_ = q.fd.Close()
+		q.fd = nil
+	}
+	if err := os.Remove(path); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "clog: remove queue segment %s err %s\n", path, err.Error())
+	}
+	q.size -= q.segments[0].size
+	q.segments = q.segments[1:]
+	q.head, q.reader = nil, nil
+}
+
+func (q *Queue) openSegment() error {
+	seq := firstSegment
+	if n := len(q.segments); n > 0 {
+		seq = q.segments[n-1].seq + 1
+	}
+	if q.fd != nil {
+		if err := q.fd.Close(); err != nil {
+			return err
+		}
+	}
+	fd, err := os.OpenFile(q.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open queue segment: %s", err)
+	}
+	q.fd, q.tailSize = fd, 0
+	q.segments = append(q.segments, segment{seq: seq})
+	return nil
+}
+
+func (q *Queue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dirPath, fmt.Sprintf("%s.%020d", q.name, seq))
+}
+
+// load 加载目录下已存在的分段, 截断进程异常退出时遗留的不完整的行.
+func (q *Queue) load() error {
+	files, err := ioutil.ReadDir(q.dirPath)
+	if err != nil {
+		return fmt.Errorf("can't read queue directory: %s", err)
+	}
+	prefix := q.name + "."
+	for _, f := range files {
+		if f.IsDir() || !strings.HasPrefix(f.Name(), prefix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(f.Name()[len(prefix):], 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := truncateTail(filepath.Join(q.dirPath, f.Name()), f.Size())
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, segment{seq: seq, size: size})
+		q.size += size
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].seq < q.segments[j].seq })
+	return nil
+}
+
+// truncateTail 截断分段末尾不以换行符结尾的数据, 返回完整行的大小.
+func truncateTail(path string, size int64) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, fmt.Errorf("can't open queue segment: %s", err)
+	}
+	defer f.Close()
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+		if _, err = f.ReadAt(buf[:n], end-n); err != nil {
+			return 0, fmt.Errorf("can't read queue segment: %s", err)
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = end - n + int64(i) + 1
+			break
+		}
+		end -= n
+	}
+	if end < size {
+		if err = f.Truncate(end); err != nil {
+			return 0, fmt.Errorf("can't truncate queue segment: %s", err)
+		}
+	}
+	return end, nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool")
+
+	q := NewQueue(path).Finish()
+	q.segmentSize = 64
+	for i := 0; i < 10; i++ {
+		if _, err := q.Write([]byte(fmt.Sprintf("line %d\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 回放失败的行保留在队列中
+	var got []string
+	errSend := errors.New("send")
+	err = q.Drain(func(line []byte) error {
+		if len(got) == 3 {
+			return errSend
+		}
+		got = append(got, string(line))
+		return nil
+	})
+	if err != errSend {
+		t.Fatalf("Drain err = %v, want %v", err, errSend)
+	}
+	_ = q.Close()
+
+	// 重新加载后从未回放的分段继续
+	q = NewQueue(path).Finish()
+	if err = q.Drain(func(line []byte) error {
+		got = append(got, string(line))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Close()
+	var want []string
+	for i := 0; i < 10; i++ {
+		want = append(want, fmt.Sprintf("line %d\n", i))
+	}
+	// 第一个分段中已回放的行在重启后会重复投递
+	if len(got) != 13 || fmt.Sprint(got[:3]) != fmt.Sprint(want[:3]) || fmt.Sprint(got[3:]) != fmt.Sprint(want) {
+		t.Errorf("invalid drain order:\ngot:  %q\nwant: %q", got, want)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("segments not removed: %d", len(files))
+	}
+}
+
+func TestQueue_PartialTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool")
+
+	// 模拟进程在写入过程中退出, 分段末尾遗留不完整的行
+	if err = ioutil.WriteFile(path+".00000000000000000001", []byte("a\nparti"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path+".00000000000000000002", []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q := NewQueue(path).Finish()
+	if n := q.Len(); n != 4 {
+		t.Errorf("Len = %d, want 4", n)
+	}
+	if _, err = q.Write([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err = q.Drain(func(line []byte) error {
+		got = append(got, string(line))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a\n", "b\n", "c\n"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid drain order:\ngot:  %q\nwant: %q", got, want)
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("Len = %d after Drain, want 0", n)
+	}
+	_ = q.Close()
+}
+
+func TestQueue_WriteFront(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool")
+
+	q := NewQueue(path).Finish()
+	for _, line := range []string{"c\n", "d\n"} {
+		if _, err = q.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已回放一部分的头部分段保留回放位置
+	var got []string
+	errSend := errors.New("send")
+	err = q.Drain(func(line []byte) error {
+		if len(got) == 1 {
+			return errSend
+		}
+		got = append(got, string(line))
+		return nil
+	})
+	if err != errSend {
+		t.Fatalf("Drain err = %v, want %v", err, errSend)
+	}
+	if err = q.WriteFront([][]byte{[]byte("a\n"), []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = q.Write([]byte("e\n"))
+	if err = q.Drain(func(line []byte) error {
+		got = append(got, string(line))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c\n", "a\n", "b\n", "d\n", "e\n"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("invalid drain order:\ngot:  %q\nwant: %q", got, want)
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("Len = %d after Drain, want 0", n)
+	}
+	_ = q.Close()
+}
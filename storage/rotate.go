@@ -38,17 +38,39 @@ type SizeRotate struct {
 	fd            *os.File
 	mu            *sync.Mutex
 	millCh        chan struct{}
+	millDone      chan struct{} // 后台清理任务退出时关闭
+	closed        bool
 }
 
-func (r *SizeRotate) Close() {
+// Flush 将文件内容同步至磁盘, 实现 clog.Flusher 接口.
+func (r *SizeRotate) Flush() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.fd.Sync()
+}
+
+// Close 等待后台的压缩与清理任务结束后关闭文件, 重复调用无效.
+func (r *SizeRotate) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
 	if r.millCh != nil {
 		close(r.millCh)
 	}
+	r.mu.Unlock()
+	if r.millDone != nil {
+		<-r.millDone
+	}
 	_ = r.fd.Sync()
 	_ = r.fd.Close()
 }
+
 func (r *SizeRotate) WriteLevel(level clog.Level, p []byte) (n int, err error) {
 	return r.Write(p)
 }
@@ -56,6 +78,9 @@ func (r *SizeRotate) WriteLevel(level clog.Level, p []byte) (n int, err error) {
 func (r *SizeRotate) Write(p []byte) (n int, err error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
 	if r.maxSize > 0 {
 		if len(p) > r.lastSize {
 			if err = r.rotate(); err != nil {
@@ -101,9 +126,17 @@ func (r *SizeRotate) firstOpenExistOrNew() error {
 		}
 	}
 	if r.maxLine > 0 {
-		// 得到文件当前行数
-		var curLine int
-		if curLine, err = lineCounter(r.fd); err != nil {
+		// 得到文件当前行数, r.fd以只写方式打开, 需单独读取
+		var (
+			curLine int
+			rd      *os.File
+		)
+		if rd, err = os.Open(r.path); err != nil {
+			return err
+		}
+		curLine, err = lineCounter(rd)
+		rd.Close()
+		if err != nil {
 			return err
 		}
 		if curLine >= r.maxLine {
@@ -131,6 +164,7 @@ func (r *SizeRotate) mill() {
 }
 
 func (r *SizeRotate) millRun() {
+	defer close(r.millDone)
 	for range r.millCh {
 		r.millRunOnce()
 	}
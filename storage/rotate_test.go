@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"errors"
+	"os"
 	"sync"
 	"testing"
 )
@@ -24,4 +26,8 @@ func TestFileWrite_Write(t *testing.T) {
 	}
 	wg.Wait()
 	storage.Close()
+	storage.Close()
+	if _, err := storage.Write([]byte("x\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close err = %v, want %v", err, os.ErrClosed)
+	}
 }
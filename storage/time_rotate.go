@@ -26,6 +26,8 @@ type TimeRotate struct {
 	fd            *os.File
 	mu            *sync.Mutex
 	ch            chan struct{}
+	done          chan struct{} // 后台切分任务退出时关闭
+	closed        bool
 }
 
 func (r *TimeRotate) WriteLevel(level clog.Level, p []byte) (int, error) {
@@ -35,26 +37,51 @@ func (r *TimeRotate) WriteLevel(level clog.Level, p []byte) (int, error) {
 func (r *TimeRotate) Write(p []byte) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
 	return r.fd.Write(p)
 }
 
-func (r *TimeRotate) Close() {
+// Flush 将文件内容同步至磁盘, 实现 clog.Flusher 接口.
+func (r *TimeRotate) Flush() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.fd.Sync()
+}
+
+// Close 等待后台的切分与压缩任务结束后关闭文件, 重复调用无效.
+func (r *TimeRotate) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
 	close(r.ch)
+	r.mu.Unlock()
+	<-r.done
 	_ = r.fd.Sync()
 	_ = r.fd.Close()
 }
 
 func (r *TimeRotate) whileRun() {
+	defer close(r.done)
 	r.processCompress()
 	for {
 		splitTIme := time.Unix(time.Now().Unix()/r.interval*r.interval+r.interval, 0)
 		select {
 		case <-r.ch:
-			break
+			return
 		case <-time.After(splitTIme.Sub(time.Now())):
 			r.mu.Lock()
+			if r.closed {
+				r.mu.Unlock()
+				return
+			}
 			_ = r.openNew(splitTIme)
 			r.mu.Unlock()
 			r.processCompress()
@@ -0,0 +1,358 @@
+package clog
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSinkClosed 向已关闭的TeeWriter写入数据时返回.
+var ErrSinkClosed = errors.New("clog: sink closed")
+
+// MultiError 聚合多个输出源的写入错误.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap 返回聚合的错误列表, Go 1.20起errors.Is与errors.As通过此方法遍历.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// Is 任一聚合的错误匹配target时返回true, 使Go 1.20以前的errors.Is同样可遍历聚合的错误.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As 查找第一个可赋值给target的聚合错误, 使Go 1.20以前的errors.As同样可遍历聚合的错误.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// SinkError 描述某个命名输出源的写入错误.
+type SinkError struct {
+	Name string
+	Err  error
+}
+
+func (e *SinkError) Error() string {
+	return "clog: sink " + e.Name + ": " + e.Err.Error()
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// SinkStats 输出源的健康状态与计数.
+type SinkStats struct {
+	Name    string
+	Writes  uint64 // 成功写入次数
+	Errors  uint64 // 写入失败次数
+	Dropped uint64 // 队列已满或熔断期间丢弃的日志数
+	Healthy bool   // 熔断器是否处于闭合状态
+	LastErr error  // 最近一次写入错误
+}
+
+// TeeWriter 将日志写入所有输出源,单个输出源失败不影响其余输出源.
+// 每个输出源可单独开启异步队列与熔断器,避免某个阻塞或故障的输出源拖慢其余输出源.
+// 通过NewTeeWriter()构建.
+type TeeWriter struct {
+	sinks  []*teeSink
+	handle func(error)
+}
+
+// handleFunc 返回异步写入错误的处理函数, 未设置时为HandleError.
+func handleFunc(f func(error)) func(error) {
+	if f == nil {
+		return HandleError
+	}
+	return f
+}
+
+type teeOption struct {
+	tee *TeeWriter
+}
+
+// NewTeeWriter 构建TeeWriter. Async与Breaker作用于最后添加的输出源.
+//
+//	tee := clog.NewTeeWriter().
+//		Sink("file", file).
+//		Sink("net", conn).Async(1024).Breaker(5, 30*time.Second).
+//		Finish()
+//	defer tee.Close()
+func NewTeeWriter() *teeOption {
+	return &teeOption{tee: new(TeeWriter)}
+}
+
+// Sink 添加名称为name的输出源
+func (o *teeOption) Sink(name string, w io.Writer) *teeOption {
+	if w == nil {
+		return o
+	}
+	o.tee.sinks = append(o.tee.sinks, &teeSink{name: name, w: toLevelWriter(w)})
+	return o
+}
+
+// Async 为最后添加的输出源开启容量为size的异步队列,队列已满时丢弃日志并计入Dropped.
+// 异步写入的错误交由ErrorHandler设置的处理函数处理.
+func (o *teeOption) Async(size int) *teeOption {
+	if s := o.last(); s != nil && size > 0 {
+		s.queue = make(chan teeEntry, size)
+	}
+	return o
+}
+
+// Breaker 为最后添加的输出源开启熔断器,连续失败threshold次后在cooldown时间内跳过此输出源,
+// 冷却结束后尝试写入一次,成功则恢复.
+func (o *teeOption) Breaker(threshold int, cooldown time.Duration) *teeOption {
+	if s := o.last(); s != nil && threshold > 0 {
+		s.threshold = uint32(threshold)
+		s.cooldown = int64(cooldown)
+	}
+	return o
+}
+
+// ErrorHandler 设置异步写入与Flush的错误处理函数, 作用于全部输出源. 默认为HandleError,
+// 即 clog.Set.ErrHandler 设置的处理函数; Logger通过WithConfig设置的ErrorHandler不作用于异步输出源.
+func (o *teeOption) ErrorHandler(f func(error)) *teeOption {
+	o.tee.handle = f
+	return o
+}
+
+func (o *teeOption) last() *teeSink {
+	if len(o.tee.sinks) == 0 {
+		return nil
+	}
+	return o.tee.sinks[len(o.tee.sinks)-1]
+}
+
+// Finish 返回TeeWriter实例
+func (o *teeOption) Finish() *TeeWriter {
+	for _, s := range o.tee.sinks {
+		s.handle = handleFunc(o.tee.handle)
+		if s.queue != nil {
+			s.wg.Add(1)
+			go s.run()
+		}
+	}
+	return o.tee
+}
+
+// Write 实现 io.Writer 接口,视为NoLevel等级写入.
+func (t *TeeWriter) Write(p []byte) (n int, err error) {
+	return t.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel 实现 LevelWriter 接口. 所有同步输出源的错误聚合为MultiError返回.
+func (t *TeeWriter) WriteLevel(l Level, p []byte) (n int, err error) {
+	var errs MultiError
+	for _, s := range t.sinks {
+		if werr := s.writeLevel(l, p); werr != nil {
+			errs = append(errs, werr)
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	return len(p), nil
+}
+
+// Stats 返回各输出源的健康状态与计数.
+func (t *TeeWriter) Stats() []SinkStats {
+	stats := make([]SinkStats, 0, len(t.sinks))
+	for _, s := range t.sinks {
+		stats = append(stats, s.stats())
+	}
+	return stats
+}
+
+// Flush 等待异步队列中已有的日志写入完成,并刷新实现了Flush() error的输出源.
+func (t *TeeWriter) Flush() error {
+	for _, s := range t.sinks {
+		s.flush()
+	}
+	return nil
+}
+
+// Close 关闭异步队列并等待队列中的日志写入完成,不会关闭输出源本身.
+func (t *TeeWriter) Close() error {
+	for _, s := range t.sinks {
+		s.close()
+	}
+	return nil
+}
+
+type teeEntry struct {
+	level Level
+	p     []byte
+	done  chan struct{} // 非nil时为Flush标记,写入前的日志均已处理
+}
+
+type teeSink struct {
+	writes    uint64
+	errors    uint64
+	dropped   uint64
+	openUntil int64 // 熔断截止时间 UnixNano
+	cooldown  int64
+	failures  uint32 // 连续失败次数
+	threshold uint32
+	name      string
+	w         LevelWriter
+	queue     chan teeEntry
+	handle    func(error)
+	lastErr   atomic.Value
+	mu        sync.RWMutex
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func (s *teeSink) writeLevel(l Level, p []byte) error {
+	if s.queue == nil {
+		if !s.allow() {
+			atomic.AddUint64(&s.dropped, 1)
+			return nil
+		}
+		return s.do(l, p)
+	}
+	// 异步输出源在run中判断熔断, 避免入队时占用冷却结束后唯一的试探写入
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return &SinkError{Name: s.name, Err: ErrSinkClosed}
+	}
+	// p 在写入完成后会被放回eventPool,异步写入需拷贝
+	entry := teeEntry{level: l, p: append([]byte(nil), p...)}
+	select {
+	case s.queue <- entry:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+func (s *teeSink) run() {
+	defer s.wg.Done()
+	for entry := range s.queue {
+		if entry.done != nil {
+			close(entry.done)
+			continue
+		}
+		if !s.allow() {
+			atomic.AddUint64(&s.dropped, 1)
+			continue
+		}
+		if err := s.do(entry.level, entry.p); err != nil {
+			s.handle(err)
+		}
+	}
+}
+
+func (s *teeSink) flush() {
+	if s.queue != nil {
+		var done chan struct{}
+		s.mu.RLock()
+		if !s.closed {
+			done = make(chan struct{})
+			s.queue <- teeEntry{done: done}
+		}
+		s.mu.RUnlock()
+		if done != nil {
+			<-done
+		}
+	}
+	flushWriter(s.w, s.handle)
+}
+
+func (s *teeSink) do(l Level, p []byte) error {
+	n, err := s.w.WriteLevel(l, p)
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		err = &SinkError{Name: s.name, Err: err}
+		atomic.AddUint64(&s.errors, 1)
+		s.lastErr.Store(errHolder{err})
+		if s.threshold > 0 && atomic.AddUint32(&s.failures, 1) >= s.threshold {
+			atomic.StoreInt64(&s.openUntil, time.Now().UnixNano()+s.cooldown)
+		}
+		return err
+	}
+	atomic.AddUint64(&s.writes, 1)
+	if s.threshold > 0 && atomic.LoadUint32(&s.failures) > 0 {
+		atomic.StoreUint32(&s.failures, 0)
+		atomic.StoreInt64(&s.openUntil, 0)
+	}
+	return nil
+}
+
+// allow 熔断期间返回false; 冷却结束后仅放行一次写入,由写入结果决定是否恢复.
+func (s *teeSink) allow() bool {
+	if s.threshold == 0 {
+		return true
+	}
+	until := atomic.LoadInt64(&s.openUntil)
+	if until == 0 {
+		return true
+	}
+	now := time.Now().UnixNano()
+	if now < until {
+		return false
+	}
+	return atomic.CompareAndSwapInt64(&s.openUntil, until, now+s.cooldown)
+}
+
+func (s *teeSink) stats() SinkStats {
+	st := SinkStats{
+		Name:    s.name,
+		Writes:  atomic.LoadUint64(&s.writes),
+		Errors:  atomic.LoadUint64(&s.errors),
+		Dropped: atomic.LoadUint64(&s.dropped),
+		Healthy: s.threshold == 0 || atomic.LoadUint32(&s.failures) < s.threshold,
+	}
+	if h, ok := s.lastErr.Load().(errHolder); ok {
+		st.LastErr = h.err
+	}
+	return st
+}
+
+func (s *teeSink) close() {
+	if s.queue == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// errHolder atomic.Value 要求存储的类型一致.
+type errHolder struct {
+	err error
+}
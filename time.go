@@ -7,7 +7,7 @@ import (
 
 // TimeFormatUnixSec, TimeFormatUnixMs or TimeFormatUnixMicro, 格式化时间为秒,毫秒,微妙时间戳
 const (
-	TimeFormatUnixSec   = ""
+	TimeFormatUnixSec   = "UNIX"
 	TimeFormatUnixMs    = "UNIXMS"
 	TimeFormatUnixMicro = "UNIXMICRO"
 )
@@ -24,22 +24,32 @@ func (s transform) AppendTime(dst []byte, t time.Time, format string) []byte {
 	return append(t.AppendFormat(append(dst, '"'), format), '"')
 }
 
+// AppendDuration 按默认配置的DurationUnit编码time.Duration.
 func (s transform) AppendDuration(dst []byte, d time.Duration) []byte {
-	if durationFieldInteger {
-		return strconv.AppendInt(dst, int64(d/durationFieldUnit), 10)
+	return s.appendDuration(dst, d, loadConfig())
+}
+
+func (s transform) appendDuration(dst []byte, d time.Duration, cfg *Config) []byte {
+	if cfg.DurationInteger {
+		return strconv.AppendInt(dst, int64(d/cfg.DurationUnit), 10)
 	}
-	return s.AppendFloat64(dst, float64(d)/float64(durationFieldUnit))
+	return s.AppendFloat64(dst, float64(d)/float64(cfg.DurationUnit))
 }
 
+// AppendDurations 按默认配置的DurationUnit编码[]time.Duration.
 func (s transform) AppendDurations(dst []byte, vals []time.Duration) []byte {
+	return s.appendDurations(dst, vals, loadConfig())
+}
+
+func (s transform) appendDurations(dst []byte, vals []time.Duration, cfg *Config) []byte {
 	if len(vals) == 0 {
 		return append(dst, '[', ']')
 	}
 	dst = append(dst, '[')
-	dst = s.AppendDuration(dst, vals[0])
+	dst = s.appendDuration(dst, vals[0], cfg)
 	if len(vals) > 1 {
 		for _, d := range vals[1:] {
-			dst = s.AppendDuration(append(dst, ','), d)
+			dst = s.appendDuration(append(dst, ','), d, cfg)
 		}
 	}
 	dst = append(dst, ']')
@@ -1,12 +1,17 @@
 package clog
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"math"
 	"net"
+	"reflect"
+	"sort"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 type transform struct{}
@@ -362,14 +367,250 @@ func (transform) AppendFloats64(dst []byte, vals []float64) []byte {
 }
 
 // AppendInterface 添加interface{}类型到bytes.
+// 常用类型直接编码, 不经过反射, 输出与json.Marshal一致; 其余类型使用默认配置的 InterfaceMarshalFunc 序列化.
 func (s transform) AppendInterface(dst []byte, i interface{}) []byte {
-	marshaled, err := json.Marshal(i)
+	return s.appendInterface(dst, i, loadConfig())
+}
+
+func (s transform) appendInterface(dst []byte, i interface{}, cfg *Config) []byte {
+	if cfg.InterfaceLogFormat {
+		switch v := i.(type) {
+		case time.Time:
+			return s.AppendTime(dst, v, cfg.TimeFormat)
+		case time.Duration:
+			return s.appendDuration(dst, v, cfg)
+		}
+	}
+	switch v := i.(type) {
+	case nil:
+		return s.AppendNil(dst)
+	case string:
+		return s.appendMarshalString(dst, v)
+	case bool:
+		return s.AppendBool(dst, v)
+	case int:
+		return s.AppendInt(dst, v)
+	case int8:
+		return s.AppendInt8(dst, v)
+	case int16:
+		return s.AppendInt16(dst, v)
+	case int32:
+		return s.AppendInt32(dst, v)
+	case int64:
+		return s.AppendInt64(dst, v)
+	case uint:
+		return s.AppendUint(dst, v)
+	case uint8:
+		return s.AppendUint8(dst, v)
+	case uint16:
+		return s.AppendUint16(dst, v)
+	case uint32:
+		return s.AppendUint32(dst, v)
+	case uint64:
+		return s.AppendUint64(dst, v)
+	case float32:
+		if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+			return appendMarshalFloat(dst, float64(v), 32)
+		}
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return appendMarshalFloat(dst, v, 64)
+		}
+	case time.Time:
+		// 与time.Time.MarshalJSON一致, 超出范围的年份由InterfaceMarshalFunc返回错误
+		if y := v.Year(); y >= 0 && y <= 9999 {
+			return append(v.AppendFormat(append(dst, '"'), time.RFC3339Nano), '"')
+		}
+	case time.Duration:
+		return s.AppendInt64(dst, int64(v))
+	case []string:
+		if v == nil {
+			return s.AppendNil(dst)
+		}
+		dst = append(dst, '[')
+		for n, str := range v {
+			if n > 0 {
+				dst = append(dst, ',')
+			}
+			dst = s.appendMarshalString(dst, str)
+		}
+		return append(dst, ']')
+	case map[string]string:
+		return s.appendStringMap(dst, v)
+	case map[string]interface{}:
+		return s.appendInterfaceMap(dst, v, cfg)
+	case json.Marshaler:
+		if isNilPointer(i) {
+			return s.AppendNil(dst)
+		}
+		if b, err := v.MarshalJSON(); err == nil {
+			if out, ok := appendCompact(dst, b); ok {
+				return out
+			}
+		}
+	case encoding.TextMarshaler:
+		if isNilPointer(i) {
+			return s.AppendNil(dst)
+		}
+		if b, err := v.MarshalText(); err == nil {
+			return s.appendMarshalString(dst, string(b))
+		}
+	case error:
+		if cfg.InterfaceLogFormat {
+			if isNilValue(i) {
+				return s.AppendNil(dst)
+			}
+			return s.AppendString(dst, v.Error())
+		}
+	case fmt.Stringer:
+		if cfg.InterfaceLogFormat {
+			if isNilValue(i) {
+				return s.AppendNil(dst)
+			}
+			return s.AppendString(dst, v.String())
+		}
+	}
+	// 未命中快速路径或编码失败时由InterfaceMarshalFunc处理, 错误信息与json.Marshal一致
+	marshaled, err := cfg.InterfaceMarshalFunc(i)
 	if err != nil {
 		return s.AppendString(dst, fmt.Sprintf("marshaling error: %v", err))
 	}
 	return append(dst, marshaled...)
 }
 
+// appendMarshalString 按json.Marshal的规则编码字符串. 与AppendString不同, 额外转义 < > & U+2028 U+2029,
+// 非法的UTF-8替换为U+FFFD字符而非\ufffd.
+func (transform) appendMarshalString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(append(dst, s[start:i]...), "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(append(dst, s[start:i]...), '\\', 'u', '2', '0', '2', hex[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	return append(append(dst, s[start:]...), '"')
+}
+
+// appendMarshalFloat 按json.Marshal的规则编码有限的浮点数, 极大与极小的值使用科学计数法.
+func appendMarshalFloat(dst []byte, f float64, bits int) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// e-09 转换为 e-9
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst
+}
+
+// appendCompact 校验json.Marshaler的输出, 去除空白并转义HTML字符后追加至dst.
+func appendCompact(dst, b []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return dst, false
+	}
+	start := len(dst)
+	dst = append(dst, buf.Bytes()...)
+	for _, c := range dst[start:] {
+		if c == '<' || c == '>' || c == '&' || c == 0xE2 {
+			buf.Reset()
+			json.HTMLEscape(&buf, dst[start:])
+			return append(dst[:start], buf.Bytes()...), true
+		}
+	}
+	return dst, true
+}
+
+// isNilPointer 与json.Marshal一致, 仅nil指针编码为null.
+func isNilPointer(i interface{}) bool {
+	v := reflect.ValueOf(i)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// appendStringMap 按key排序编码map[string]string, 与json.Marshal输出一致.
+func (s transform) appendStringMap(dst []byte, m map[string]string) []byte {
+	if m == nil {
+		return s.AppendNil(dst)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dst = s.AppendBeginMarker(dst)
+	for _, k := range keys {
+		dst = s.appendMarshalString(s.appendMarshalKey(dst, k), m[k])
+	}
+	return s.AppendEndMarker(dst)
+}
+
+// appendInterfaceMap 按key排序编码map[string]interface{}, 与json.Marshal输出一致.
+func (s transform) appendInterfaceMap(dst []byte, m map[string]interface{}, cfg *Config) []byte {
+	if m == nil {
+		return s.AppendNil(dst)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dst = s.AppendBeginMarker(dst)
+	for _, k := range keys {
+		dst = s.appendInterface(s.appendMarshalKey(dst, k), m[k], cfg)
+	}
+	return s.AppendEndMarker(dst)
+}
+
+// appendMarshalKey 按json.Marshal的规则编码对象的key.
+func (s transform) appendMarshalKey(dst []byte, key string) []byte {
+	if dst[len(dst)-1] != '{' {
+		dst = append(dst, ',')
+	}
+	return append(s.appendMarshalString(dst, key), ':')
+}
+
 // AppendIPAddr 添加 IPv4 or IPv6地址到Slice.
 func (s transform) AppendIPAddr(dst []byte, ip net.IP) []byte {
 	return s.AppendString(dst, ip.String())
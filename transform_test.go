@@ -0,0 +1,183 @@
+package clog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom":true}`), nil
+}
+
+type stringer struct{ v int }
+
+func (s stringer) String() string {
+	return "stringer"
+}
+
+type plain struct {
+	A int    `json:"a"`
+	B string `json:"b"`
+}
+
+type textMarshaler struct{}
+
+func (textMarshaler) MarshalText() ([]byte, error) {
+	return []byte("<text>"), nil
+}
+
+type spacedMarshaler struct{}
+
+func (spacedMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "a" : "<b>" }`), nil
+}
+
+func TestAppendInterface(t *testing.T) {
+	var (
+		nilErr  *net.OpError
+		nilJSON *jsonMarshaler
+	)
+	tm := time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC)
+	// 快速路径的输出与json.Marshal一致
+	for _, in := range []interface{}{
+		nil,
+		"a\"b",
+		"<a href=\"x\">&</a>\u2028\u2029\xff\b\f\x01\x7f\t€",
+		true,
+		int8(-8),
+		uint64(18446744073709551615),
+		float32(1.5),
+		float32(1e-7),
+		1e21,
+		0.000001,
+		-1e-9,
+		math.NaN(),
+		tm,
+		1500 * time.Microsecond,
+		[]string{"a", "<b>"},
+		[]string(nil),
+		map[string]string{"b": "2", "a": "1", "<": ">"},
+		map[string]string(nil),
+		map[string]interface{}{"n": 1, "m": map[string]interface{}{"x": nil}, "d": time.Second, "e": errors.New("boom")},
+		jsonMarshaler{},
+		nilJSON,
+		spacedMarshaler{},
+		textMarshaler{},
+		net.ParseIP("127.0.0.1"),
+		errors.New("boom"),
+		nilErr,
+		stringer{},
+		plain{A: 1, B: "x"},
+	} {
+		want, err := json.Marshal(in)
+		if err != nil {
+			want = []byte(strconv.Quote(fmt.Sprintf("marshaling error: %v", err)))
+		}
+		if got := trs.AppendInterface(nil, in); string(got) != string(want) {
+			t.Errorf("AppendInterface(%#v):\ngot:  %s\nwant: %s", in, got, want)
+		}
+	}
+}
+
+func TestInterfaceLogFormat(t *testing.T) {
+	c := DefaultConfig()
+	c.InterfaceLogFormat = true
+	cfg := c.normalize()
+	tm := time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, tt := range []struct {
+		in   interface{}
+		want string
+	}{
+		{tm, `"2021-01-02T03:04:05Z"`},
+		{1500 * time.Microsecond, `1.5`},
+		{errors.New("boom"), `"boom"`},
+		{stringer{}, `"stringer"`},
+		{jsonMarshaler{}, `{"custom":true}`},
+		{plain{A: 1, B: "x"}, `{"a":1,"b":"x"}`},
+	} {
+		if got := string(trs.appendInterface(nil, tt.in, cfg)); got != tt.want {
+			t.Errorf("appendInterface(%#v):\ngot:  %v\nwant: %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceMarshalFunc(t *testing.T) {
+	defer Set.InterfaceMarshalFunc(json.Marshal)
+	Set.InterfaceMarshalFunc(func(interface{}) ([]byte, error) {
+		return []byte(`"custom"`), nil
+	})
+	if got, want := string(trs.AppendInterface(nil, plain{})), `"custom"`; got != want {
+		t.Errorf("invalid output:\ngot:  %v\nwant: %v", got, want)
+	}
+	// 快速路径不经过interfaceMarshalFunc
+	if got, want := string(trs.AppendInterface(nil, 1)), `1`; got != want {
+		t.Errorf("invalid output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+var benchInterfaces = []struct {
+	name string
+	val  interface{}
+}{
+	{"int", 42},
+	{"float64", 3.14},
+	{"string", "hello world"},
+	{"time", time.Unix(1600000000, 0)},
+	{"duration", time.Second},
+	{"strings", []string{"a", "b", "c"}},
+	{"map_string", map[string]string{"a": "1", "b": "2"}},
+	{"map_interface", map[string]interface{}{"a": 1, "b": "2", "c": true}},
+	{"error", errors.New("boom")},
+	{"stringer", stringer{}},
+	{"struct", plain{A: 1, B: "x"}},
+}
+
+// BenchmarkAppendInterface 快速路径
+func BenchmarkAppendInterface(b *testing.B) {
+	for _, bb := range benchInterfaces {
+		b.Run(bb.name, func(b *testing.B) {
+			buf := make([]byte, 0, 256)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = trs.AppendInterface(buf[:0], bb.val)
+			}
+		})
+	}
+}
+
+// BenchmarkAppendInterfaceJSON 原json.Marshal实现,作为对照
+func BenchmarkAppendInterfaceJSON(b *testing.B) {
+	for _, bb := range benchInterfaces {
+		b.Run(bb.name, func(b *testing.B) {
+			buf := make([]byte, 0, 256)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				marshaled, _ := json.Marshal(bb.val)
+				buf = append(buf[:0], marshaled...)
+			}
+		})
+	}
+}
+
+func BenchmarkEventInterface(b *testing.B) {
+	log := NewOption().WithWriter(discard{}).Logger()
+	m := map[string]interface{}{"user": "u1", "n": 3}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Info().Interface("n", 1).Interface("m", m).Interface("d", time.Second).Msg("")
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -26,10 +26,14 @@ type syncWriter struct {
 }
 
 func SyncWriter(w io.Writer) io.Writer {
+	return &syncWriter{lw: toLevelWriter(w)}
+}
+
+func toLevelWriter(w io.Writer) LevelWriter {
 	if lw, ok := w.(LevelWriter); ok {
-		return &syncWriter{lw: lw}
+		return lw
 	}
-	return &syncWriter{lw: levelWriterAdapter{w}}
+	return levelWriterAdapter{w}
 }
 
 // Write implements the io.Writer interface.
@@ -50,44 +54,45 @@ type multiLevelWriter struct {
 }
 
 func (t multiLevelWriter) Write(p []byte) (n int, err error) {
-	for _, w := range t.writers {
-		n, err = w.Write(p)
-		if err != nil {
-			return
-		}
-		if n != len(p) {
-			err = io.ErrShortWrite
-			return
-		}
-	}
-	return len(p), nil
+	return t.each(p, func(w LevelWriter) (int, error) {
+		return w.Write(p)
+	})
 }
 
 func (t multiLevelWriter) WriteLevel(l Level, p []byte) (n int, err error) {
+	return t.each(p, func(w LevelWriter) (int, error) {
+		return w.WriteLevel(l, p)
+	})
+}
+
+// each 依次写入所有输出源,单个输出源失败不中断其余输出源.
+func (t multiLevelWriter) each(p []byte, write func(w LevelWriter) (int, error)) (n int, err error) {
+	var errs MultiError
 	for _, w := range t.writers {
-		n, err = w.WriteLevel(l, p)
-		if err != nil {
-			return
+		wn, werr := write(w)
+		if werr == nil && wn != len(p) {
+			werr = io.ErrShortWrite
 		}
-		if n != len(p) {
-			err = io.ErrShortWrite
-			return
+		if werr != nil {
+			errs = append(errs, werr)
 		}
 	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
 	return len(p), nil
 }
 
 // MultiLevelWriter creates a writer that duplicates its writes to all the
 // provided writers, similar to the Unix tee(1) command. If some writers
 // implement LevelWriter, their WriteLevel method will be used instead of Write.
+//
+// 单个输出源写入失败不会中断其余输出源的写入,所有错误聚合为MultiError返回并交由errorHandler处理.
+// 需要异步队列,熔断及健康统计时使用NewTeeWriter().
 func MultiLevelWriter(writers ...io.Writer) LevelWriter {
 	lwriters := make([]LevelWriter, 0, len(writers))
 	for _, w := range writers {
-		if lw, ok := w.(LevelWriter); ok {
-			lwriters = append(lwriters, lw)
-		} else {
-			lwriters = append(lwriters, levelWriterAdapter{w})
-		}
+		lwriters = append(lwriters, toLevelWriter(w))
 	}
 	return multiLevelWriter{lwriters}
 }
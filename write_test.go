@@ -0,0 +1,171 @@
+package clog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilteredLevelWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := NewOption().WithLogLevel(TraceLevel).WithWriter(FilteredLevelWriter(WarnLevel, ErrorLevel, out)).Logger()
+	log.Debug().Msg("debug")
+	log.Warn().Msg("warn")
+	log.Error().Msg("error")
+	log.Log().Msg("nolevel")
+	want := `{"level":"warn","message":"warn"}` + "\n" + `{"level":"error","message":"error"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("invalid log output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestLevelRouter(t *testing.T) {
+	all, errs, debug := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	router := NewLevelRouter().
+		Above(InfoLevel, all).
+		Above(ErrorLevel, errs).
+		Route(TraceLevel, DebugLevel, debug).
+		Finish()
+	log := NewOption().WithLogLevel(TraceLevel).WithWriter(router).Logger()
+	log.Trace().Msg("trace")
+	log.Info().Msg("info")
+	log.Error().Msg("error")
+
+	if got, want := all.String(), `{"level":"info","message":"info"}`+"\n"+`{"level":"error","message":"error"}`+"\n"; got != want {
+		t.Errorf("invalid all output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if got, want := errs.String(), `{"level":"error","message":"error"}`+"\n"; got != want {
+		t.Errorf("invalid error output:\ngot:  %v\nwant: %v", got, want)
+	}
+	if got, want := debug.String(), `{"level":"trace","message":"trace"}`+"\n"; got != want {
+		t.Errorf("invalid debug output:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestLevelRouterContinueOnError(t *testing.T) {
+	want := errors.New("write error")
+	out := &bytes.Buffer{}
+	router := NewLevelRouter().Above(InfoLevel, errWriter{want}).Above(InfoLevel, out).Finish()
+	if _, err := router.WriteLevel(InfoLevel, []byte("foo\n")); err != want {
+		t.Errorf("WriteLevel err = %v, want %v", err, want)
+	}
+	if got := out.String(); got != "foo\n" {
+		t.Errorf("invalid output: %q", got)
+	}
+}
+
+func TestMultiLevelWriterContinueOnError(t *testing.T) {
+	want := errors.New("write error")
+	out := &bytes.Buffer{}
+	w := MultiLevelWriter(errWriter{want}, out, errWriter{want})
+	_, err := w.WriteLevel(InfoLevel, []byte("foo\n"))
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 || !errors.Is(err, want) {
+		t.Errorf("WriteLevel err = %#v, want MultiError of 2", err)
+	}
+	if got := out.String(); got != "foo\n" {
+		t.Errorf("invalid output: %q", got)
+	}
+}
+
+func TestTeeWriter(t *testing.T) {
+	want := errors.New("write error")
+	out, async := &bytes.Buffer{}, &bytes.Buffer{}
+	tee := NewTeeWriter().
+		Sink("broken", errWriter{want}).Breaker(2, time.Hour).
+		Sink("file", out).
+		Sink("async", async).Async(16).
+		Finish()
+	for i := 0; i < 4; i++ {
+		_, _ = tee.WriteLevel(InfoLevel, []byte("foo\n"))
+	}
+	_ = tee.Close()
+	if _, err := tee.WriteLevel(InfoLevel, []byte("foo\n")); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("WriteLevel after Close err = %v, want %v", err, ErrSinkClosed)
+	}
+
+	stats := tee.Stats()
+	if got := stats[0]; got.Errors != 2 || got.Dropped != 3 || got.Healthy || !errors.Is(got.LastErr, want) {
+		t.Errorf("invalid broken sink stats: %+v", got)
+	}
+	if got := stats[1]; got.Writes != 5 || got.Errors != 0 || !got.Healthy {
+		t.Errorf("invalid file sink stats: %+v", got)
+	}
+	if got := stats[2]; got.Writes != 4 || async.String() != strings.Repeat("foo\n", 4) {
+		t.Errorf("invalid async sink stats: %+v, output %q", got, async.String())
+	}
+}
+
+// flakyWriter 前fail次写入失败, 之后写入buf.
+type flakyWriter struct {
+	mu   sync.Mutex
+	fail int
+	buf  bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail > 0 {
+		w.fail--
+		return 0, errors.New("unavailable")
+	}
+	return w.buf.Write(p)
+}
+
+func TestTeeWriterAsyncBreakerRecover(t *testing.T) {
+	fw := &flakyWriter{fail: 2}
+	var failed int
+	tee := NewTeeWriter().Sink("flaky", fw).Async(16).Breaker(2, 10*time.Millisecond).
+		ErrorHandler(func(error) { failed++ }).Finish()
+
+	_, _ = tee.WriteLevel(InfoLevel, []byte("a\n"))
+	_, _ = tee.WriteLevel(InfoLevel, []byte("b\n"))
+	_ = tee.Flush()
+	if tee.Stats()[0].Healthy {
+		t.Fatal("breaker should be open")
+	}
+	time.Sleep(20 * time.Millisecond)
+	_, _ = tee.WriteLevel(InfoLevel, []byte("c\n"))
+	_, _ = tee.WriteLevel(InfoLevel, []byte("d\n"))
+	_ = tee.Close()
+	if st := tee.Stats()[0]; !st.Healthy || st.Writes != 2 || fw.buf.String() != "c\nd\n" {
+		t.Errorf("breaker did not recover: %+v, output %q", st, fw.buf.String())
+	}
+	if failed != 2 {
+		t.Errorf("ErrorHandler called %d times, want 2", failed)
+	}
+}
+
+// noLevelFilter 丢弃以NoLevel等级写入的日志, Write不受影响.
+type noLevelFilter struct {
+	bytes.Buffer
+}
+
+func (f *noLevelFilter) WriteLevel(l Level, p []byte) (int, error) {
+	if l == NoLevel {
+		return len(p), nil
+	}
+	return f.Write(p)
+}
+
+func TestMultiLevelWriterWrite(t *testing.T) {
+	f := &noLevelFilter{}
+	w := MultiLevelWriter(f)
+	_, _ = w.Write([]byte("a\n"))
+	_, _ = w.WriteLevel(NoLevel, []byte("b\n"))
+	if got := f.String(); got != "a\n" {
+		t.Errorf("Write should use each writer's Write, got %q", got)
+	}
+}
+
+func TestMultiErrorAs(t *testing.T) {
+	err := error(MultiError{errors.New("a"), &SinkError{Name: "s", Err: ErrSinkClosed}})
+	var se *SinkError
+	if !errors.As(err, &se) || se.Name != "s" || !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("errors.As/Is should traverse MultiError: %v", err)
+	}
+}